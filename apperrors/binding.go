@@ -0,0 +1,81 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// 校验错误使用 JSON 字段名，避免暴露 Go 结构体字段
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// FromBinding 将 ShouldBindJSON 返回的错误转换为校验错误，不泄露校验器内部信息
+func FromBinding(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, FieldError{
+				Field:   fieldPath(fe.Namespace()),
+				Message: validationMessage(fe),
+			})
+		}
+		return Validation("validation_failed", "Request validation failed", fields...)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return Validation("validation_failed", "Request validation failed", FieldError{
+			Field:   typeErr.Field,
+			Message: "must be of type " + typeErr.Type.String(),
+		})
+	}
+
+	if errors.Is(err, io.EOF) {
+		return BadRequest("empty_body", "Request body is required")
+	}
+	return BadRequest("malformed_body", "Request body is not valid JSON").Wrap(err)
+}
+
+// fieldPath 去掉顶层结构体名，如 Order.items[2].quantity -> items[2].quantity
+func fieldPath(namespace string) string {
+	if _, rest, found := strings.Cut(namespace, "."); found {
+		return rest
+	}
+	return namespace
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	case "min", "gte":
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	default:
+		return "is invalid"
+	}
+}
@@ -0,0 +1,137 @@
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Kind 错误类别，决定 HTTP 状态码
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindBadRequest
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindTooManyRequests
+	KindUnavailable
+	KindPreconditionFailed
+	KindPreconditionRequired
+	KindNotAcceptable
+)
+
+var kindStatus = map[Kind]int{
+	KindInternal:             http.StatusInternalServerError,
+	KindBadRequest:           http.StatusBadRequest,
+	KindValidation:           http.StatusBadRequest,
+	KindUnauthorized:         http.StatusUnauthorized,
+	KindForbidden:            http.StatusForbidden,
+	KindNotFound:             http.StatusNotFound,
+	KindConflict:             http.StatusConflict,
+	KindTooManyRequests:      http.StatusTooManyRequests,
+	KindUnavailable:          http.StatusServiceUnavailable,
+	KindPreconditionFailed:   http.StatusPreconditionFailed,
+	KindPreconditionRequired: http.StatusPreconditionRequired,
+	KindNotAcceptable:        http.StatusNotAcceptable,
+}
+
+// FieldError 单个字段的校验错误，Field 为 JSON 路径，如 items[2].quantity
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error 领域错误。Code 是对外稳定的错误码，Message 可以直接返回给客户端，
+// Err 为内部原因，只记录日志不对外暴露
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Message + ": " + e.Err.Error()
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status 返回对应的 HTTP 状态码
+func (e *Error) Status() int {
+	if status, ok := kindStatus[e.Kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Wrap 附加内部原因
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+func BadRequest(code, message string) *Error {
+	return &Error{Kind: KindBadRequest, Code: code, Message: message}
+}
+
+func Validation(code, message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message, Fields: fields}
+}
+
+func Unauthorized(code, message string) *Error {
+	return &Error{Kind: KindUnauthorized, Code: code, Message: message}
+}
+
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+func NotFound(code, message string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message}
+}
+
+func Conflict(code, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+func TooManyRequests(code, message string) *Error {
+	return &Error{Kind: KindTooManyRequests, Code: code, Message: message}
+}
+
+func Unavailable(code, message string) *Error {
+	return &Error{Kind: KindUnavailable, Code: code, Message: message}
+}
+
+func PreconditionFailed(code, message string) *Error {
+	return &Error{Kind: KindPreconditionFailed, Code: code, Message: message}
+}
+
+func PreconditionRequired(code, message string) *Error {
+	return &Error{Kind: KindPreconditionRequired, Code: code, Message: message}
+}
+
+func NotAcceptable(code, message string) *Error {
+	return &Error{Kind: KindNotAcceptable, Code: code, Message: message}
+}
+
+func Internal(code, message string, err error) *Error {
+	return &Error{Kind: KindInternal, Code: code, Message: message, Err: err}
+}
+
+// From 将任意错误转换为领域错误，未知错误按内部错误处理
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal("internal_error", "Internal server error", err)
+}
@@ -0,0 +1,136 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  *Error
+		want int
+	}{
+		{BadRequest("c", "m"), http.StatusBadRequest},
+		{Validation("c", "m"), http.StatusBadRequest},
+		{Unauthorized("c", "m"), http.StatusUnauthorized},
+		{Forbidden("c", "m"), http.StatusForbidden},
+		{NotFound("c", "m"), http.StatusNotFound},
+		{Conflict("c", "m"), http.StatusConflict},
+		{TooManyRequests("c", "m"), http.StatusTooManyRequests},
+		{Unavailable("c", "m"), http.StatusServiceUnavailable},
+		{PreconditionFailed("c", "m"), http.StatusPreconditionFailed},
+		{PreconditionRequired("c", "m"), http.StatusPreconditionRequired},
+		{NotAcceptable("c", "m"), http.StatusNotAcceptable},
+		{Internal("c", "m", nil), http.StatusInternalServerError},
+		{&Error{Kind: Kind(999)}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Status(); got != tt.want {
+			t.Errorf("kind %d: Status() = %d, want %d", tt.err.Kind, got, tt.want)
+		}
+	}
+}
+
+func TestFrom(t *testing.T) {
+	cause := errors.New("db down")
+	notFound := NotFound("order_not_found", "Order not found")
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode string
+		wantKind Kind
+	}{
+		{"domain error", notFound, "order_not_found", KindNotFound},
+		{"wrapped domain error", fmt.Errorf("load: %w", notFound), "order_not_found", KindNotFound},
+		{"plain error", cause, "internal_error", KindInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := From(tt.err)
+			if got.Code != tt.wantCode || got.Kind != tt.wantKind {
+				t.Errorf("From() = %s/%d, want %s/%d", got.Code, got.Kind, tt.wantCode, tt.wantKind)
+			}
+		})
+	}
+
+	// 内部原因只通过 Unwrap 暴露
+	if got := From(cause); !errors.Is(got, cause) || got.Message != "Internal server error" {
+		t.Errorf("From(plain) = %+v, want wrapped cause with generic message", got)
+	}
+}
+
+type bindingRequest struct {
+	Status   string `json:"status" binding:"required,oneof=pending cancelled"`
+	Quantity int    `json:"quantity" binding:"gt=0"`
+	Items    []struct {
+		Name string `json:"name" binding:"max=3"`
+	} `json:"items" binding:"dive"`
+}
+
+func TestFromBinding(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   string
+		wantFields []FieldError
+	}{
+		{
+			name:     "validation errors use json paths",
+			body:     `{"status":"done","quantity":0,"items":[{"name":"ok"},{"name":"toolong"}]}`,
+			wantCode: "validation_failed",
+			wantFields: []FieldError{
+				{Field: "status", Message: "must be one of: pending cancelled"},
+				{Field: "quantity", Message: "must be greater than 0"},
+				{Field: "items[1].name", Message: "must be at most 3"},
+			},
+		},
+		{
+			name:       "missing required field",
+			body:       `{"quantity":1}`,
+			wantCode:   "validation_failed",
+			wantFields: []FieldError{{Field: "status", Message: "is required"}},
+		},
+		{
+			name:       "wrong type",
+			body:       `{"status":"pending","quantity":"one"}`,
+			wantCode:   "validation_failed",
+			wantFields: []FieldError{{Field: "quantity", Message: "must be of type int"}},
+		},
+		{
+			name:     "malformed json",
+			body:     `{"status":`,
+			wantCode: "malformed_body",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req bindingRequest
+			err := binding.JSON.BindBody([]byte(tt.body), &req)
+			if err == nil {
+				t.Fatal("expected binding error")
+			}
+			got := FromBinding(err)
+			if got.Code != tt.wantCode {
+				t.Fatalf("code = %s, want %s (%v)", got.Code, tt.wantCode, err)
+			}
+			if len(got.Fields) != len(tt.wantFields) {
+				t.Fatalf("fields = %+v, want %+v", got.Fields, tt.wantFields)
+			}
+			for i, field := range tt.wantFields {
+				if got.Fields[i] != field {
+					t.Errorf("fields[%d] = %+v, want %+v", i, got.Fields[i], field)
+				}
+			}
+		})
+	}
+
+	if got := FromBinding(io.EOF); got.Code != "empty_body" {
+		t.Errorf("FromBinding(EOF) code = %s, want empty_body", got.Code)
+	}
+}
@@ -1,41 +1,276 @@
 package config
 
 import (
-	"io/ioutil"
+	"errors"
+	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// 内置的开发环境默认值，生产环境不允许使用
+const (
+	defaultDBPassword  = "xxxxx"
+	defaultJWTSecret   = "G9mCQ19ogTkuWQY9jH2wGZASuGi/JrhstQaZy4k/01o="
+	defaultRabbitMQURL = "amqp://admin:rabbitmq@IP:5672/"
+)
+
+// RateLimitSetting 单个路由的限流配置，速率为每秒令牌数，0 表示不限流
+type RateLimitSetting struct {
+	UserRate  float64 `yaml:"user_rate" toml:"user_rate"`
+	UserBurst int     `yaml:"user_burst" toml:"user_burst"`
+	IPRate    float64 `yaml:"ip_rate" toml:"ip_rate"`
+	IPBurst   int     `yaml:"ip_burst" toml:"ip_burst"`
+}
+
+// Config 服务配置。配置文件中的键为对应环境变量名的小写形式，如 DB_HOST 对应 db_host
 type Config struct {
-	DBUser          string
-	DBPassword      string
-	DBHost          string
-	DBPort          string
-	DBName          string
-	JWTSecret       string
-	RabbitMQURL     string
-	OrderExchange   string
-	OrderQueue      string
-	DeadLetterQueue string
-	DelayExchange   string
-	MaxPriority     int
-}
-
-func LoadConfig() *Config {
+	Environment     string `yaml:"app_env" toml:"app_env"` // development、staging 或 production
+	DBUser          string `yaml:"db_user" toml:"db_user"`
+	DBPassword      string `yaml:"db_password" toml:"db_password"`
+	DBHost          string `yaml:"db_host" toml:"db_host"`
+	DBPort          string `yaml:"db_port" toml:"db_port"`
+	DBName          string `yaml:"db_name" toml:"db_name"`
+	JWTSecret       string `yaml:"jwt_secret" toml:"jwt_secret"`
+	RabbitMQURL     string `yaml:"rabbitmq_url" toml:"rabbitmq_url"`
+	OrderExchange   string `yaml:"order_exchange" toml:"order_exchange"`
+	OrderQueue      string `yaml:"order_queue" toml:"order_queue"`
+	DeadLetterQueue string `yaml:"dead_letter_queue" toml:"dead_letter_queue"`
+	DelayExchange   string `yaml:"delay_exchange" toml:"delay_exchange"`
+	MaxPriority     int    `yaml:"max_priority" toml:"max_priority"` // 优先级队列最大优先级，修改后需要重建队列
+
+	// 读写分离：DBPrimaryDSN 设置时替代上面的单项配置，只读查询路由到健康的从库
+	DBPrimaryDSN          string   `yaml:"db_primary_dsn" toml:"db_primary_dsn"`
+	DBReplicaDSNs         []string `yaml:"db_replica_dsns" toml:"db_replica_dsns"`
+	DBReplicaCheckSeconds int      `yaml:"db_replica_check_seconds" toml:"db_replica_check_seconds"` // 从库健康检查间隔
+	ReadYourWritesSeconds int      `yaml:"read_your_writes_seconds" toml:"read_your_writes_seconds"` // 用户写入后其读请求固定走主库的时间窗口
+
+	// 数据库连接池、超时（秒）和 TLS，主库和从库共用；DSN 中已设置的超时和 tls 参数优先
+	DBMaxOpenConns        int    `yaml:"db_max_open_conns" toml:"db_max_open_conns"`
+	DBMaxIdleConns        int    `yaml:"db_max_idle_conns" toml:"db_max_idle_conns"`
+	DBMaxLifetimeSeconds  int    `yaml:"db_max_lifetime_seconds" toml:"db_max_lifetime_seconds"`
+	DBMaxIdleTimeSeconds  int    `yaml:"db_max_idle_time_seconds" toml:"db_max_idle_time_seconds"`
+	DBDialTimeoutSeconds  int    `yaml:"db_dial_timeout_seconds" toml:"db_dial_timeout_seconds"`
+	DBReadTimeoutSeconds  int    `yaml:"db_read_timeout_seconds" toml:"db_read_timeout_seconds"`
+	DBWriteTimeoutSeconds int    `yaml:"db_write_timeout_seconds" toml:"db_write_timeout_seconds"`
+	DBTLS                 string `yaml:"db_tls" toml:"db_tls"`                           // false、true、skip-verify 或 preferred
+	DBTLSCAFile           string `yaml:"db_tls_ca_file" toml:"db_tls_ca_file"`           // 设置后启用 TLS 并用该 CA 校验服务端证书
+	DBConnectAttempts     int    `yaml:"db_connect_attempts" toml:"db_connect_attempts"` // 启动时连接主库的最大尝试次数，失败后指数退避
+
+	// HTTP 服务端口和超时（秒），管理端口使用相同的超时。写超时默认为 0，
+	// 否则会截断 SSE 长连接
+	HTTPPort                string `yaml:"http_port" toml:"http_port"`
+	HTTPReadTimeoutSeconds  int    `yaml:"http_read_timeout_seconds" toml:"http_read_timeout_seconds"`
+	HTTPWriteTimeoutSeconds int    `yaml:"http_write_timeout_seconds" toml:"http_write_timeout_seconds"`
+	HTTPIdleTimeoutSeconds  int    `yaml:"http_idle_timeout_seconds" toml:"http_idle_timeout_seconds"`
+
+	// 可信反向代理的 IP 或 CIDR，只有来自这些地址的 X-Forwarded-For 才用于确定客户端 IP。
+	// 为空时不信任任何代理，直接使用连接的对端地址
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+
+	PaymentTimeoutMinutes int `yaml:"payment_timeout_minutes" toml:"payment_timeout_minutes"` // 下单后未支付的检查时间
+
+	// 订单校验规则
+	MaxOrderLines        int  `yaml:"max_order_lines" toml:"max_order_lines"`
+	MaxLineQuantity      int  `yaml:"max_line_quantity" toml:"max_line_quantity"`
+	MaxProductNameLength int  `yaml:"max_product_name_length" toml:"max_product_name_length"`
+	MergeDuplicateLines  bool `yaml:"merge_duplicate_lines" toml:"merge_duplicate_lines"`
+
+	RateLimits map[string]RateLimitSetting `yaml:"rate_limits" toml:"rate_limits"` // 按路由名配置的限流规则
+
+	RestockingFeePercent float64 `yaml:"restocking_fee_percent" toml:"restocking_fee_percent"` // 退货默认手续费比例（0-100）
+	TaxRulesFile         string  `yaml:"tax_rules_file" toml:"tax_rules_file"`                 // 税率规则表 JSON 文件，为空时使用内置规则
+	ShippingRulesFile    string  `yaml:"shipping_rules_file" toml:"shipping_rules_file"`       // 运费规则表 JSON 文件，为空时使用内置规则
+	DefaultWeightGrams   int     `yaml:"default_weight_grams" toml:"default_weight_grams"`     // 未配置重量商品的默认单件重量（克）
+
+	// 自提点所在地区，自提订单按该地区计税
+	PickupCountry string `yaml:"pickup_country" toml:"pickup_country"`
+	PickupState   string `yaml:"pickup_state" toml:"pickup_state"`
+
+	// 多币种：汇率文件中的汇率为 1 单位各货币折合基准货币的数量
+	BaseCurrency        string   `yaml:"base_currency" toml:"base_currency"`
+	SupportedCurrencies []string `yaml:"supported_currencies" toml:"supported_currencies"`
+	ExchangeRatesFile   string   `yaml:"exchange_rates_file" toml:"exchange_rates_file"`
+
+	// webhook 投递：队列绑定到订单交换机
+	WebhookQueue          string `yaml:"webhook_queue" toml:"webhook_queue"`
+	WebhookMaxAttempts    int    `yaml:"webhook_max_attempts" toml:"webhook_max_attempts"`
+	WebhookTimeoutSeconds int    `yaml:"webhook_timeout_seconds" toml:"webhook_timeout_seconds"`
+	WebhookDisableAfter   int    `yaml:"webhook_disable_after" toml:"webhook_disable_after"` // 连续失败次数达到后停用订阅
+
+	StreamHeartbeatSeconds int `yaml:"stream_heartbeat_seconds" toml:"stream_heartbeat_seconds"` // SSE 心跳间隔
+
+	GRPCPort        string `yaml:"grpc_port" toml:"grpc_port"`                   // gRPC 服务端口，为空时不启动
+	GRPCTLSCertFile string `yaml:"grpc_tls_cert_file" toml:"grpc_tls_cert_file"` // 证书和私钥都配置后 gRPC 使用 TLS
+	GRPCTLSKeyFile  string `yaml:"grpc_tls_key_file" toml:"grpc_tls_key_file"`
+
+	OpenAPIValidation bool `yaml:"openapi_validation" toml:"openapi_validation"` // 按 OpenAPI 规范校验请求和响应，生产环境忽略
+
+	// v1 接口的弃用和下线时间，设置后 v1 响应带 Deprecation/Sunset 头
+	APIV1DeprecatedAt time.Time `yaml:"api_v1_deprecated_at" toml:"api_v1_deprecated_at"`
+	APIV1SunsetAt     time.Time `yaml:"api_v1_sunset_at" toml:"api_v1_sunset_at"`
+
+	// 管理端口：内部端点（死信等）只在该端口暴露
+	AdminPort                   string `yaml:"admin_port" toml:"admin_port"`
+	AdminHMACSecret             string `yaml:"admin_hmac_secret" toml:"admin_hmac_secret"`
+	AdminSignatureMaxAgeSeconds int    `yaml:"admin_signature_max_age_seconds" toml:"admin_signature_max_age_seconds"` // HMAC 签名时间戳允许的偏差
+	AdminTLSCertFile            string `yaml:"admin_tls_cert_file" toml:"admin_tls_cert_file"`
+	AdminTLSKeyFile             string `yaml:"admin_tls_key_file" toml:"admin_tls_key_file"`
+	AdminClientCAFile           string `yaml:"admin_client_ca_file" toml:"admin_client_ca_file"` // 配置后启用 mTLS，替代 HMAC 签名校验
+	MetricsAdminOnly            bool   `yaml:"metrics_admin_only" toml:"metrics_admin_only"`     // 为 true 时 /metrics 只在管理端口暴露
+
+	PrintConfig bool `yaml:"-" toml:"-"` // 命令行 -print-config：输出脱敏后的生效配置后退出
+}
+
+// Default 返回内置默认配置，密钥类默认值只适用于开发环境
+func Default() *Config {
 	return &Config{
-		DBUser:          getEnv("DB_USER", "root"),
-		DBPassword:      getEnvFromFile("DB_PASSWORD_FILE", "DB_PASSWORD", "xxxxx"),
-		DBHost:          getEnv("DB_HOST", "localhost"),
-		DBPort:          getEnv("DB_PORT", "3306"),
-		DBName:          getEnv("DB_NAME", "ecommerce"),
-		JWTSecret:       getEnvFromFile("JWT_SECRET_FILE", "JWT_SECRET", "G9mCQ19ogTkuWQY9jH2wGZASuGi/JrhstQaZy4k/01o="),
-		RabbitMQURL:     getEnv("RABBITMQ_URL", "amqp://admin:rabbitmq@IP:5672/"),
-		OrderExchange:   getEnv("ORDER_EXCHANGE", "orders_exchange"),
-		OrderQueue:      getEnv("ORDER_QUEUE", "orders_queue"),
-		DeadLetterQueue: getEnv("DEAD_LETTER_QUEUE", "dead_letter_queue"),
-		DelayExchange:   getEnv("DELAY_EXCHANGE", "delay_exchange"),
-		MaxPriority:     10, // 优先级队列最大优先级
+		Environment:                 "development",
+		DBUser:                      "root",
+		DBPassword:                  defaultDBPassword,
+		DBHost:                      "localhost",
+		DBPort:                      "3306",
+		DBName:                      "ecommerce",
+		DBReplicaCheckSeconds:       2,
+		ReadYourWritesSeconds:       5,
+		DBMaxOpenConns:              25,
+		DBMaxIdleConns:              10,
+		DBMaxLifetimeSeconds:        300,
+		DBMaxIdleTimeSeconds:        60,
+		DBDialTimeoutSeconds:        5,
+		DBReadTimeoutSeconds:        30,
+		DBWriteTimeoutSeconds:       30,
+		DBTLS:                       "false",
+		DBConnectAttempts:           5,
+		JWTSecret:                   defaultJWTSecret,
+		RabbitMQURL:                 defaultRabbitMQURL,
+		OrderExchange:               "orders_exchange",
+		OrderQueue:                  "orders_queue",
+		DeadLetterQueue:             "dead_letter_queue",
+		DelayExchange:               "delay_exchange",
+		MaxPriority:                 10,
+		HTTPPort:                    "8080",
+		HTTPReadTimeoutSeconds:      30,
+		HTTPIdleTimeoutSeconds:      120,
+		PaymentTimeoutMinutes:       15,
+		MaxOrderLines:               50,
+		MaxLineQuantity:             100,
+		MaxProductNameLength:        200,
+		MergeDuplicateLines:         true,
+		DefaultWeightGrams:          500,
+		PickupCountry:               "CN",
+		BaseCurrency:                "CNY",
+		SupportedCurrencies:         []string{"CNY"},
+		WebhookQueue:                "order_webhooks_queue",
+		WebhookMaxAttempts:          8,
+		WebhookTimeoutSeconds:       10,
+		WebhookDisableAfter:         20,
+		StreamHeartbeatSeconds:      15,
+		AdminPort:                   "9090",
+		AdminSignatureMaxAgeSeconds: 300,
+		RateLimits: map[string]RateLimitSetting{
+			"create_order":     {UserRate: 1, UserBurst: 5, IPRate: 5, IPBurst: 20},
+			"query_orders":     {UserRate: 10, UserBurst: 30, IPRate: 30, IPBurst: 60},
+			"update_status":    {UserRate: 2, UserBurst: 10, IPRate: 10, IPBurst: 30},
+			"manage_addresses": {UserRate: 1, UserBurst: 10, IPRate: 5, IPBurst: 20},
+			"dead_letter":      {IPRate: 1, IPBurst: 10},
+		},
+	}
+}
+
+// applyEnv 用环境变量覆盖配置，未设置的保持原值。格式错误的值和无法读取的 *_FILE 一并返回
+func applyEnv(cfg *Config) error {
+	env := &envReader{}
+	cfg.Environment = getEnv("APP_ENV", cfg.Environment)
+	cfg.DBUser = getEnv("DB_USER", cfg.DBUser)
+	cfg.DBPassword = env.getFromFile("DB_PASSWORD_FILE", "DB_PASSWORD", cfg.DBPassword)
+	cfg.DBHost = getEnv("DB_HOST", cfg.DBHost)
+	cfg.DBPort = getEnv("DB_PORT", cfg.DBPort)
+	cfg.DBName = getEnv("DB_NAME", cfg.DBName)
+	cfg.DBPrimaryDSN = env.getFromFile("DB_PRIMARY_DSN_FILE", "DB_PRIMARY_DSN", cfg.DBPrimaryDSN)
+	if dsns := env.getFromFile("DB_REPLICA_DSNS_FILE", "DB_REPLICA_DSNS", ""); dsns != "" {
+		cfg.DBReplicaDSNs = splitDSNs(dsns)
+	}
+	cfg.DBReplicaCheckSeconds = env.getInt("DB_REPLICA_CHECK_SECONDS", cfg.DBReplicaCheckSeconds)
+	cfg.ReadYourWritesSeconds = env.getInt("READ_YOUR_WRITES_SECONDS", cfg.ReadYourWritesSeconds)
+	cfg.DBMaxOpenConns = env.getInt("DB_MAX_OPEN_CONNS", cfg.DBMaxOpenConns)
+	cfg.DBMaxIdleConns = env.getInt("DB_MAX_IDLE_CONNS", cfg.DBMaxIdleConns)
+	cfg.DBMaxLifetimeSeconds = env.getInt("DB_MAX_LIFETIME_SECONDS", cfg.DBMaxLifetimeSeconds)
+	cfg.DBMaxIdleTimeSeconds = env.getInt("DB_MAX_IDLE_TIME_SECONDS", cfg.DBMaxIdleTimeSeconds)
+	cfg.DBDialTimeoutSeconds = env.getInt("DB_DIAL_TIMEOUT_SECONDS", cfg.DBDialTimeoutSeconds)
+	cfg.DBReadTimeoutSeconds = env.getInt("DB_READ_TIMEOUT_SECONDS", cfg.DBReadTimeoutSeconds)
+	cfg.DBWriteTimeoutSeconds = env.getInt("DB_WRITE_TIMEOUT_SECONDS", cfg.DBWriteTimeoutSeconds)
+	cfg.DBTLS = getEnv("DB_TLS", cfg.DBTLS)
+	cfg.DBTLSCAFile = getEnv("DB_TLS_CA_FILE", cfg.DBTLSCAFile)
+	cfg.DBConnectAttempts = env.getInt("DB_CONNECT_ATTEMPTS", cfg.DBConnectAttempts)
+	cfg.JWTSecret = env.getFromFile("JWT_SECRET_FILE", "JWT_SECRET", cfg.JWTSecret)
+	cfg.RabbitMQURL = env.getFromFile("RABBITMQ_URL_FILE", "RABBITMQ_URL", cfg.RabbitMQURL)
+	cfg.OrderExchange = getEnv("ORDER_EXCHANGE", cfg.OrderExchange)
+	cfg.OrderQueue = getEnv("ORDER_QUEUE", cfg.OrderQueue)
+	cfg.DeadLetterQueue = getEnv("DEAD_LETTER_QUEUE", cfg.DeadLetterQueue)
+	cfg.DelayExchange = getEnv("DELAY_EXCHANGE", cfg.DelayExchange)
+	cfg.MaxPriority = env.getInt("MAX_PRIORITY", cfg.MaxPriority)
+	cfg.HTTPPort = getEnv("HTTP_PORT", cfg.HTTPPort)
+	cfg.HTTPReadTimeoutSeconds = env.getInt("HTTP_READ_TIMEOUT_SECONDS", cfg.HTTPReadTimeoutSeconds)
+	cfg.HTTPWriteTimeoutSeconds = env.getInt("HTTP_WRITE_TIMEOUT_SECONDS", cfg.HTTPWriteTimeoutSeconds)
+	cfg.HTTPIdleTimeoutSeconds = env.getInt("HTTP_IDLE_TIMEOUT_SECONDS", cfg.HTTPIdleTimeoutSeconds)
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		cfg.TrustedProxies = strings.FieldsFunc(proxies, func(r rune) bool { return r == ',' || r == ' ' })
 	}
+	cfg.PaymentTimeoutMinutes = env.getInt("PAYMENT_TIMEOUT_MINUTES", cfg.PaymentTimeoutMinutes)
+	cfg.MaxOrderLines = env.getInt("MAX_ORDER_LINES", cfg.MaxOrderLines)
+	cfg.MaxLineQuantity = env.getInt("MAX_LINE_QUANTITY", cfg.MaxLineQuantity)
+	cfg.MaxProductNameLength = env.getInt("MAX_PRODUCT_NAME_LENGTH", cfg.MaxProductNameLength)
+	cfg.MergeDuplicateLines = env.getBool("MERGE_DUPLICATE_LINES", cfg.MergeDuplicateLines)
+	cfg.RestockingFeePercent = env.getFloat("RESTOCKING_FEE_PERCENT", cfg.RestockingFeePercent)
+	cfg.TaxRulesFile = getEnv("TAX_RULES_FILE", cfg.TaxRulesFile)
+	cfg.ShippingRulesFile = getEnv("SHIPPING_RULES_FILE", cfg.ShippingRulesFile)
+	cfg.DefaultWeightGrams = env.getInt("DEFAULT_WEIGHT_GRAMS", cfg.DefaultWeightGrams)
+	cfg.PickupCountry = getEnv("PICKUP_COUNTRY", cfg.PickupCountry)
+	cfg.PickupState = getEnv("PICKUP_STATE", cfg.PickupState)
+	cfg.BaseCurrency = getEnv("BASE_CURRENCY", cfg.BaseCurrency)
+	cfg.SupportedCurrencies = getEnvList("SUPPORTED_CURRENCIES", cfg.SupportedCurrencies)
+	cfg.ExchangeRatesFile = getEnv("EXCHANGE_RATES_FILE", cfg.ExchangeRatesFile)
+	cfg.WebhookQueue = getEnv("WEBHOOK_QUEUE", cfg.WebhookQueue)
+	cfg.WebhookMaxAttempts = env.getInt("WEBHOOK_MAX_ATTEMPTS", cfg.WebhookMaxAttempts)
+	cfg.WebhookTimeoutSeconds = env.getInt("WEBHOOK_TIMEOUT_SECONDS", cfg.WebhookTimeoutSeconds)
+	cfg.WebhookDisableAfter = env.getInt("WEBHOOK_DISABLE_AFTER", cfg.WebhookDisableAfter)
+	cfg.StreamHeartbeatSeconds = env.getInt("STREAM_HEARTBEAT_SECONDS", cfg.StreamHeartbeatSeconds)
+	cfg.GRPCPort = getEnv("GRPC_PORT", cfg.GRPCPort)
+	cfg.GRPCTLSCertFile = getEnv("GRPC_TLS_CERT_FILE", cfg.GRPCTLSCertFile)
+	cfg.GRPCTLSKeyFile = getEnv("GRPC_TLS_KEY_FILE", cfg.GRPCTLSKeyFile)
+	cfg.OpenAPIValidation = env.getBool("OPENAPI_VALIDATION", cfg.OpenAPIValidation)
+	cfg.APIV1DeprecatedAt = env.getTime("API_V1_DEPRECATED_AT", cfg.APIV1DeprecatedAt)
+	cfg.APIV1SunsetAt = env.getTime("API_V1_SUNSET_AT", cfg.APIV1SunsetAt)
+	cfg.AdminPort = getEnv("ADMIN_PORT", cfg.AdminPort)
+	cfg.AdminHMACSecret = env.getFromFile("ADMIN_HMAC_SECRET_FILE", "ADMIN_HMAC_SECRET", cfg.AdminHMACSecret)
+	cfg.AdminSignatureMaxAgeSeconds = env.getInt("ADMIN_SIGNATURE_MAX_AGE_SECONDS", cfg.AdminSignatureMaxAgeSeconds)
+	cfg.AdminTLSCertFile = getEnv("ADMIN_TLS_CERT_FILE", cfg.AdminTLSCertFile)
+	cfg.AdminTLSKeyFile = getEnv("ADMIN_TLS_KEY_FILE", cfg.AdminTLSKeyFile)
+	cfg.AdminClientCAFile = getEnv("ADMIN_CLIENT_CA_FILE", cfg.AdminClientCAFile)
+	cfg.MetricsAdminOnly = env.getBool("METRICS_ADMIN_ONLY", cfg.MetricsAdminOnly)
+
+	// RATE_LIMIT_<ROUTE>_USER 和 RATE_LIMIT_<ROUTE>_IP 覆盖已知路由的限流规则
+	for route, setting := range cfg.RateLimits {
+		cfg.RateLimits[route] = env.getRateLimit(strings.ToUpper(route), setting)
+	}
+
+	if len(env.problems) == 0 {
+		return nil
+	}
+	sort.Strings(env.problems)
+	return errors.New("invalid environment:\n  - " + strings.Join(env.problems, "\n  - "))
+}
+
+// envReader 读取需要解析的环境变量，记录遇到的问题而不是静默使用默认值
+type envReader struct {
+	problems []string
+}
+
+func (e *envReader) add(format string, args ...interface{}) {
+	e.problems = append(e.problems, fmt.Sprintf(format, args...))
 }
 
 func getEnv(key, defaultValue string) string {
@@ -45,11 +280,127 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func getEnvFromFile(fileKey, envKey, defaultValue string) string {
+func (e *envReader) getInt(key string, defaultValue int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		e.add("%s must be an integer, got %q", key, raw)
+		return defaultValue
+	}
+	return value
+}
+
+func (e *envReader) getFloat(key string, defaultValue float64) float64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		e.add("%s must be a number, got %q", key, raw)
+		return defaultValue
+	}
+	return value
+}
+
+// getBool 读取 true/false（也接受 1/0），未设置时返回默认值
+func (e *envReader) getBool(key string, defaultValue bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		e.add("%s must be true or false, got %q", key, raw)
+		return defaultValue
+	}
+	return value
+}
+
+// getTime 读取日期（2006-01-02）或 RFC 3339 时间，未设置时返回默认值
+func (e *envReader) getTime(key string, defaultValue time.Time) time.Time {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t
+		}
+	}
+	e.add("%s must be a date (2006-01-02) or RFC 3339 time, got %q", key, raw)
+	return defaultValue
+}
+
+// getEnvList 读取逗号分隔的列表，统一转为大写
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToUpper(strings.TrimSpace(item)); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// splitDSNs 拆分逗号或换行分隔的 DSN 列表，保留大小写
+func splitDSNs(value string) []string {
+	var dsns []string
+	for _, dsn := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			dsns = append(dsns, dsn)
+		}
+	}
+	return dsns
+}
+
+// getFromFile 优先读取 fileKey 指向的文件。文件无法读取时报错，不回退到 envKey 或默认值
+func (e *envReader) getFromFile(fileKey, envKey, defaultValue string) string {
 	if filePath := os.Getenv(fileKey); filePath != "" {
-		if content, err := ioutil.ReadFile(filePath); err == nil {
-			return strings.TrimSpace(string(content))
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			e.add("%s could not be read: %v", fileKey, err)
+			return defaultValue
 		}
+		return strings.TrimSpace(string(content))
 	}
 	return getEnv(envKey, defaultValue)
 }
+
+// getRateLimit 读取 RATE_LIMIT_<ROUTE>_USER 和 RATE_LIMIT_<ROUTE>_IP，格式为 "速率:容量"
+func (e *envReader) getRateLimit(route string, defaultValue RateLimitSetting) RateLimitSetting {
+	setting := defaultValue
+	if rate, burst, ok := e.parseRateLimit("RATE_LIMIT_" + route + "_USER"); ok {
+		setting.UserRate, setting.UserBurst = rate, burst
+	}
+	if rate, burst, ok := e.parseRateLimit("RATE_LIMIT_" + route + "_IP"); ok {
+		setting.IPRate, setting.IPBurst = rate, burst
+	}
+	return setting
+}
+
+// parseRateLimit 解析 key 的值，未设置时 ok 为 false，格式错误时记录问题
+func (e *envReader) parseRateLimit(key string) (float64, int, bool) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, 0, false
+	}
+	if value == "0" {
+		return 0, 0, true
+	}
+	rateStr, burstStr, found := strings.Cut(value, ":")
+	rate, rateErr := strconv.ParseFloat(rateStr, 64)
+	burst, burstErr := strconv.Atoi(burstStr)
+	if !found || rateErr != nil || burstErr != nil {
+		e.add("%s must be \"rate:burst\" or 0, got %q", key, value)
+		return 0, 0, false
+	}
+	return rate, burst, true
+}
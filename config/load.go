@@ -0,0 +1,314 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted 脱敏后的密钥占位符
+const redacted = "[REDACTED]"
+
+// Load 按 内置默认值 → 配置文件 → 环境变量 → 命令行参数 的顺序加载配置，后者覆盖前者。
+// 配置文件由 -config 或 CONFIG_FILE 指定，按扩展名解析为 YAML 或 TOML，未知的键视为错误。
+// 格式错误的环境变量和无法读取的 *_FILE 同样返回错误，不回退到默认值。
+// 只加载不校验，启动前需要调用 Validate
+func Load(args []string) (*Config, error) {
+	// 第一遍只取配置文件路径并检查参数格式
+	probe := flag.NewFlagSet("order-service", flag.ContinueOnError)
+	file := bindFlags(probe, Default())
+	if err := probe.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if *file != "" {
+		if err := loadFile(*file, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	// 第二遍以文件和环境变量的结果为默认值，只有命令行中出现的参数会覆盖
+	flags := flag.NewFlagSet("order-service", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	bindFlags(flags, cfg)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg.normalize()
+	return cfg, nil
+}
+
+// bindFlags 注册命令行参数，参数直接写入 cfg，返回配置文件路径
+func bindFlags(fs *flag.FlagSet, cfg *Config) *string {
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print the effective config with secrets redacted and exit")
+	fs.StringVar(&cfg.Environment, "env", cfg.Environment, "environment: development, staging or production")
+	fs.StringVar(&cfg.HTTPPort, "http-port", cfg.HTTPPort, "HTTP port")
+	fs.StringVar(&cfg.AdminPort, "admin-port", cfg.AdminPort, "admin port")
+	fs.StringVar(&cfg.GRPCPort, "grpc-port", cfg.GRPCPort, "gRPC port, empty to disable")
+	fs.StringVar(&cfg.DBHost, "db-host", cfg.DBHost, "database host")
+	fs.StringVar(&cfg.DBPort, "db-port", cfg.DBPort, "database port")
+	fs.StringVar(&cfg.DBName, "db-name", cfg.DBName, "database name")
+	fs.IntVar(&cfg.MaxPriority, "max-priority", cfg.MaxPriority, "maximum RabbitMQ message priority")
+	fs.BoolVar(&cfg.OpenAPIValidation, "openapi-validation", cfg.OpenAPIValidation, "validate requests and responses against the OpenAPI spec")
+	return file
+}
+
+// loadFile 把配置文件中出现的键覆盖到 cfg 上
+func loadFile(path string, cfg *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// 限流规则按字段合并：文件中的路由只覆盖写出的字段
+	limits := cfg.RateLimits
+	cfg.RateLimits = nil
+	var raw map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+		if err := yaml.Unmarshal(content, &raw); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(cfg); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+		if err := toml.Unmarshal(content, &raw); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file %s, use .yaml, .yml or .toml", path)
+	}
+	cfg.RateLimits = mergeRateLimits(limits, cfg.RateLimits, raw["rate_limits"])
+	return nil
+}
+
+// mergeRateLimits 以 base 为基础，用 file 中在原始文件里出现过的字段覆盖
+func mergeRateLimits(base, file map[string]RateLimitSetting, raw interface{}) map[string]RateLimitSetting {
+	merged := make(map[string]RateLimitSetting, len(base)+len(file))
+	for route, setting := range base {
+		merged[route] = setting
+	}
+	routes, _ := raw.(map[string]interface{})
+	for route, value := range file {
+		fields, _ := routes[route].(map[string]interface{})
+		setting := merged[route]
+		if _, ok := fields["user_rate"]; ok {
+			setting.UserRate = value.UserRate
+		}
+		if _, ok := fields["user_burst"]; ok {
+			setting.UserBurst = value.UserBurst
+		}
+		if _, ok := fields["ip_rate"]; ok {
+			setting.IPRate = value.IPRate
+		}
+		if _, ok := fields["ip_burst"]; ok {
+			setting.IPBurst = value.IPBurst
+		}
+		merged[route] = setting
+	}
+	return merged
+}
+
+// normalize 统一货币和地区代码大小写
+func (c *Config) normalize() {
+	c.PickupCountry = strings.ToUpper(strings.TrimSpace(c.PickupCountry))
+	c.BaseCurrency = strings.ToUpper(strings.TrimSpace(c.BaseCurrency))
+	for i, code := range c.SupportedCurrencies {
+		c.SupportedCurrencies[i] = strings.ToUpper(strings.TrimSpace(code))
+	}
+}
+
+// Validate 检查配置并一次返回全部问题。生产环境不允许使用内置的默认密钥
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch c.Environment {
+	case "development", "staging", "production":
+	default:
+		add("app_env must be development, staging or production, got %q", c.Environment)
+	}
+
+	for name, port := range map[string]string{"http_port": c.HTTPPort, "admin_port": c.AdminPort, "grpc_port": c.GRPCPort} {
+		if port == "" && name == "grpc_port" {
+			continue
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			add("%s must be a port number, got %q", name, port)
+		}
+	}
+	if c.HTTPPort == c.AdminPort {
+		add("http_port and admin_port must differ")
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			add("trusted_proxies must contain IP addresses or CIDRs, got %q", proxy)
+		}
+	}
+
+	if c.MaxPriority < 1 || c.MaxPriority > 255 {
+		add("max_priority must be between 1 and 255")
+	}
+	for name, value := range map[string]int{
+		"db_max_open_conns":               c.DBMaxOpenConns,
+		"db_max_idle_conns":               c.DBMaxIdleConns,
+		"db_max_lifetime_seconds":         c.DBMaxLifetimeSeconds,
+		"db_max_idle_time_seconds":        c.DBMaxIdleTimeSeconds,
+		"db_dial_timeout_seconds":         c.DBDialTimeoutSeconds,
+		"db_read_timeout_seconds":         c.DBReadTimeoutSeconds,
+		"db_write_timeout_seconds":        c.DBWriteTimeoutSeconds,
+		"read_your_writes_seconds":        c.ReadYourWritesSeconds,
+		"http_read_timeout_seconds":       c.HTTPReadTimeoutSeconds,
+		"http_write_timeout_seconds":      c.HTTPWriteTimeoutSeconds,
+		"http_idle_timeout_seconds":       c.HTTPIdleTimeoutSeconds,
+		"webhook_timeout_seconds":         c.WebhookTimeoutSeconds,
+		"admin_signature_max_age_seconds": c.AdminSignatureMaxAgeSeconds,
+	} {
+		if value < 0 {
+			add("%s must not be negative", name)
+		}
+	}
+	for name, value := range map[string]int{
+		"db_connect_attempts":      c.DBConnectAttempts,
+		"db_replica_check_seconds": c.DBReplicaCheckSeconds,
+		"payment_timeout_minutes":  c.PaymentTimeoutMinutes,
+		"max_order_lines":          c.MaxOrderLines,
+		"max_line_quantity":        c.MaxLineQuantity,
+		"max_product_name_length":  c.MaxProductNameLength,
+		"stream_heartbeat_seconds": c.StreamHeartbeatSeconds,
+	} {
+		if value < 1 {
+			add("%s must be at least 1", name)
+		}
+	}
+	switch c.DBTLS {
+	case "false", "true", "skip-verify", "preferred":
+	default:
+		add("db_tls must be false, true, skip-verify or preferred, got %q", c.DBTLS)
+	}
+	if c.RestockingFeePercent < 0 || c.RestockingFeePercent > 100 {
+		add("restocking_fee_percent must be between 0 and 100")
+	}
+	if c.AdminClientCAFile != "" && (c.AdminTLSCertFile == "" || c.AdminTLSKeyFile == "") {
+		add("admin_tls_cert_file and admin_tls_key_file must be set when admin_client_ca_file is set")
+	}
+	if (c.GRPCTLSCertFile == "") != (c.GRPCTLSKeyFile == "") {
+		add("grpc_tls_cert_file and grpc_tls_key_file must be set together")
+	}
+	if c.PickupCountry == "" {
+		add("pickup_country must be set")
+	}
+	if !containsString(c.SupportedCurrencies, c.BaseCurrency) {
+		add("supported_currencies must include base_currency %s", c.BaseCurrency)
+	}
+
+	if c.Environment == "production" {
+		if c.JWTSecret == "" || c.JWTSecret == defaultJWTSecret {
+			add("jwt_secret must be set in production")
+		}
+		if c.RabbitMQURL == defaultRabbitMQURL {
+			add("rabbitmq_url must be set in production")
+		}
+		if c.DBPrimaryDSN == "" && (c.DBPassword == "" || c.DBPassword == defaultDBPassword) {
+			add("db_password must be set in production")
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+}
+
+// Redacted 返回隐藏了密钥和连接串密码的副本，用于输出生效配置
+func (c *Config) Redacted() *Config {
+	copied := *c
+	copied.DBPassword = redact(c.DBPassword)
+	copied.JWTSecret = redact(c.JWTSecret)
+	copied.AdminHMACSecret = redact(c.AdminHMACSecret)
+	copied.RabbitMQURL = redactURL(c.RabbitMQURL)
+	copied.DBPrimaryDSN = redactDSN(c.DBPrimaryDSN)
+	copied.DBReplicaDSNs = make([]string, len(c.DBReplicaDSNs))
+	for i, dsn := range c.DBReplicaDSNs {
+		copied.DBReplicaDSNs[i] = redactDSN(dsn)
+	}
+	return &copied
+}
+
+// Dump 以 YAML 输出脱敏后的配置，键与配置文件一致
+func (c *Config) Dump(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redact(raw)
+	}
+	return u.Redacted()
+}
+
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+	mc, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return redacted
+	}
+	if mc.Passwd != "" {
+		mc.Passwd = redacted
+	}
+	return mc.FormatDSN()
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateTLSFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*Config)
+		wantErr   string
+	}{
+		{"defaults", func(*Config) {}, ""},
+		{"admin mTLS with certificate", func(c *Config) {
+			c.AdminClientCAFile, c.AdminTLSCertFile, c.AdminTLSKeyFile = "ca.pem", "cert.pem", "key.pem"
+		}, ""},
+		{"admin client CA without certificate", func(c *Config) {
+			c.AdminClientCAFile = "ca.pem"
+		}, "admin_tls_cert_file and admin_tls_key_file must be set"},
+		{"admin client CA without key", func(c *Config) {
+			c.AdminClientCAFile, c.AdminTLSCertFile = "ca.pem", "cert.pem"
+		}, "admin_tls_cert_file and admin_tls_key_file must be set"},
+		{"grpc certificate without key", func(c *Config) {
+			c.GRPCTLSCertFile = "cert.pem"
+		}, "grpc_tls_cert_file and grpc_tls_key_file must be set together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.configure(cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsMalformedEnv(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"valid values", map[string]string{"MAX_PRIORITY": "5", "MERGE_DUPLICATE_LINES": "true", "RATE_LIMIT_CREATE_ORDER_USER": "2:4"}, ""},
+		{"malformed integer", map[string]string{"MAX_PRIORITY": "ten"}, "MAX_PRIORITY must be an integer"},
+		{"malformed float", map[string]string{"RESTOCKING_FEE_PERCENT": "5%"}, "RESTOCKING_FEE_PERCENT must be a number"},
+		{"malformed bool", map[string]string{"OPENAPI_VALIDATION": "yes"}, "OPENAPI_VALIDATION must be true or false"},
+		{"malformed time", map[string]string{"API_V1_SUNSET_AT": "next year"}, "API_V1_SUNSET_AT must be a date"},
+		{"malformed rate limit", map[string]string{"RATE_LIMIT_CREATE_ORDER_IP": "10"}, `RATE_LIMIT_CREATE_ORDER_IP must be "rate:burst"`},
+		{"unreadable secret file", map[string]string{"JWT_SECRET_FILE": missing, "JWT_SECRET": "from-env"}, "JWT_SECRET_FILE could not be read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := Load(nil)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() = %v, want error containing %q", err, tt.wantErr)
+			}
+			if cfg != nil {
+				t.Fatalf("Load() returned a config alongside error %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadReadsSecretFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwt_secret")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JWT_SECRET_FILE", path)
+	t.Setenv("JWT_SECRET", "from-env")
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if cfg.JWTSecret != "from-file" {
+		t.Fatalf("JWTSecret = %q, want %q", cfg.JWTSecret, "from-file")
+	}
+}
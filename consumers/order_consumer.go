@@ -1,12 +1,16 @@
 package consumers
 
 import (
+	"encoding/json"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"log"
 	"order-service/config"
 	"order-service/database"
-	"strconv"
+	"order-service/history"
+	"order-service/middlewares"
+	"order-service/models"
 	"strings"
+	"time"
 )
 
 func StartOrderConsumer(ch *amqp.Channel, cfg *config.Config) {
@@ -52,56 +56,96 @@ func StartOrderConsumer(ch *amqp.Channel, cfg *config.Config) {
 }
 
 func processOrderMessage(msg amqp.Delivery) {
+	start := time.Now()
+	eventType := "unknown"
+	outcome := "success"
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("Recovered from panic in message processing: %v", r)
+			outcome = "panic"
 		}
+		middlewares.ObserveEventProcessing(eventType, outcome, time.Since(start))
 	}()
 
-	parts := strings.Split(string(msg.Body), "|")
-	if len(parts) < 2 {
-		log.Printf("Invalid message format: %s", msg.Body)
+	var event models.OrderEvent
+	if err := json.Unmarshal(msg.Body, &event); err != nil || event.OrderID <= 0 || event.Type == "" {
+		// 消息体可能包含地址等个人信息，只记录长度
+		log.Printf("Invalid message format (%d bytes): %v", len(msg.Body), err)
+		outcome = "invalid"
 		err := msg.Nack(false, false)
 		if err != nil {
 			return
 		} // 拒绝消息，不重新入队
 		return
 	}
+	eventType = metricEventType(event.Type)
+	orderID := event.OrderID
 
-	orderID, err := strconv.Atoi(parts[0])
-	if err != nil {
-		log.Printf("Invalid order ID: %s", parts[0])
-		err := msg.Nack(false, false)
-		if err != nil {
-			return
-		}
-		return
+	if msg.Redelivered {
+		middlewares.RecordEventRedelivery(eventType)
 	}
 
-	eventType := parts[1]
 	log.Printf("Processing order event: ID=%d, Type=%s", orderID, eventType)
 
 	// 根据事件类型处理
-	switch eventType {
+	var handleErr error
+	switch event.Type {
 	case "created":
-		handleOrderCreated(orderID)
+		handleErr = handleOrderCreated(event)
 	case "status_updated":
-		handleStatusUpdated(orderID)
+		handleErr = handleStatusUpdated(orderID)
+	case "items_changed":
+		handleErr = handleItemsChanged(event)
+	case "shipment_created":
+		handleErr = handleShipmentCreated(event)
 	case "payment_check":
-		handlePaymentCheck(orderID)
+		handleErr = handlePaymentCheck(orderID)
 	default:
-		log.Printf("Unknown event type: %s", eventType)
+		if strings.HasPrefix(event.Type, "return_") {
+			handleErr = handleReturnEvent(event)
+			break
+		}
+		log.Printf("Unknown event type: %q", event.Type)
+		outcome = "unknown"
+	}
+	if handleErr != nil {
+		outcome = "error"
 	}
 
 	// 处理成功后确认消息
-	err = msg.Ack(false)
+	err := msg.Ack(false)
 	if err != nil {
 		return
 	}
 }
 
+// knownEventTypes 消费者识别的事件类型。指标标签只使用这些值，
+// 消息体中的其他类型统一记为 unknown，避免标签基数无限增长
+var knownEventTypes = map[string]bool{
+	"created":                                   true,
+	"status_updated":                            true,
+	"items_changed":                             true,
+	"shipment_created":                          true,
+	"payment_check":                             true,
+	"return_" + models.ReturnStatusRequested:    true,
+	"return_" + models.ReturnStatusApproved:     true,
+	"return_" + models.ReturnStatusRejected:     true,
+	"return_" + models.ReturnStatusReceived:     true,
+	"return_" + models.ReturnStatusRefunded:     true,
+	"return_" + models.ReturnStatusRefundFailed: true,
+}
+
+// metricEventType 返回可以作为指标标签的事件类型
+func metricEventType(eventType string) string {
+	if knownEventTypes[eventType] {
+		return eventType
+	}
+	return "unknown"
+}
+
 func processDeadLetterMessage(msg amqp.Delivery) {
-	log.Printf("Received dead letter: %s", msg.Body)
+	log.Printf("Received dead letter (%d bytes, type=%s)", len(msg.Body), msg.Type)
+	middlewares.RecordDeadLetter("queue")
 	// 实际处理：记录到数据库、通知管理员等
 	err := msg.Ack(false)
 	if err != nil {
@@ -109,49 +153,148 @@ func processDeadLetterMessage(msg amqp.Delivery) {
 	}
 }
 
-func handleOrderCreated(orderID int) {
+func handleOrderCreated(event models.OrderEvent) error {
 	// 实际业务逻辑：通知其他服务、更新缓存等
-	log.Printf("Handling order created: %d", orderID)
+	if event.ShippingAddress != nil {
+		log.Printf("Handling order created: %d, ship to %s", event.OrderID, event.ShippingAddress)
+	} else {
+		log.Printf("Handling order created: %d", event.OrderID)
+	}
+	return nil
 }
 
-func handleStatusUpdated(orderID int) {
+func handleStatusUpdated(orderID int) error {
 	// 获取订单最新状态
 	var status string
 	err := database.DB.QueryRow("SELECT status FROM orders WHERE id = ?", orderID).Scan(&status)
 	if err != nil {
 		log.Printf("Failed to get order status: %v", err)
-		return
+		return err
 	}
 
 	// 根据状态处理
 	switch status {
-	case "shipped":
+	case models.OrderStatusPartiallyShipped, models.OrderStatusShipped:
 		// 发送发货通知
+		if err := sendShippingNotification(orderID); err != nil {
+			log.Printf("Failed to send shipping notification for order %d: %v", orderID, err)
+			return err
+		}
 	case "cancelled":
 		// 处理取消逻辑
 	}
 	log.Printf("Handling status update for order %d: %s", orderID, status)
+	return nil
+}
+
+func handleItemsChanged(event models.OrderEvent) error {
+	// 实际业务逻辑：同步库存预占、通知其他服务等
+	for _, change := range event.ItemChanges {
+		log.Printf("Order %d item %d changed: %d -> %d",
+			event.OrderID, change.ProductID, change.OldQuantity, change.NewQuantity)
+	}
+	return nil
+}
+
+func handleShipmentCreated(event models.OrderEvent) error {
+	if event.Shipment == nil {
+		return nil
+	}
+	log.Printf("Handling shipment %d created for order %d: %s %s",
+		event.Shipment.ID, event.OrderID, event.Shipment.Carrier, event.Shipment.TrackingNumber)
+	return nil
+}
+
+// sendShippingNotification 向用户发送发货通知，包含各发货单的承运商和运单号
+func sendShippingNotification(orderID int) error {
+	rows, err := database.DB.Query(`
+		SELECT s.carrier, s.tracking_number, o.user_id
+		FROM shipments s
+		JOIN orders o ON o.id = s.order_id
+		WHERE s.order_id = ?
+		ORDER BY s.id
+	`, orderID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var carrier, trackingNumber string
+		var userID int
+		if err := rows.Scan(&carrier, &trackingNumber, &userID); err != nil {
+			return err
+		}
+		// 实际发送：推送、短信或邮件
+		log.Printf("Shipping notification to user %d: order %d shipped via %s, tracking number %s",
+			userID, orderID, carrier, trackingNumber)
+	}
+	return rows.Err()
 }
 
-func handlePaymentCheck(orderID int) {
+func handleReturnEvent(event models.OrderEvent) error {
+	if event.Return == nil {
+		return nil
+	}
+	log.Printf("Handling %s for order %d: return %d, status %s, refund %.2f",
+		event.Type, event.OrderID, event.Return.ID, event.Return.Status, event.Return.RefundAmount)
+	return nil
+}
+
+// handlePaymentCheck 超时未支付自动取消。使用带状态和版本号条件的更新，
+// 与用户或管理端的并发修改冲突时放弃取消
+func handlePaymentCheck(orderID int) error {
 	// 检查订单支付状态
 	var status string
-	err := database.DB.QueryRow("SELECT status FROM orders WHERE id = ?", orderID).Scan(&status)
+	var version int
+	err := database.DB.QueryRow("SELECT status, version FROM orders WHERE id = ?", orderID).Scan(&status, &version)
 	if err != nil {
 		log.Printf("Failed to get order status: %v", err)
-		return
+		return err
 	}
 
 	// 如果订单仍未支付，自动取消
-	if status == "pending" {
-		_, err := database.DB.Exec(
-			"UPDATE orders SET status = 'cancelled', updated_at = NOW() WHERE id = ?",
-			orderID,
-		)
-		if err != nil {
-			log.Printf("Failed to auto-cancel order %d: %v", orderID, err)
-		} else {
-			log.Printf("Auto-cancelled order %d due to non-payment", orderID)
-		}
+	if status != models.OrderStatusPending {
+		return nil
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"UPDATE orders SET status = ?, version = version + 1, updated_at = NOW() WHERE id = ? AND status = ? AND version = ?",
+		models.OrderStatusCancelled, orderID, status, version,
+	)
+	if err != nil {
+		log.Printf("Failed to auto-cancel order %d: %v", orderID, err)
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		log.Printf("Skipped auto-cancel of order %d: modified concurrently", orderID)
+		return nil
+	}
+
+	if err := history.Record(tx, models.OrderHistoryEntry{
+		OrderID:   orderID,
+		OldStatus: status,
+		NewStatus: models.OrderStatusCancelled,
+		Actor:     history.SystemActor,
+		Source:    history.SourceConsumer,
+		Reason:    "payment timeout",
+	}); err != nil {
+		log.Printf("Failed to record auto-cancel history for order %d: %v", orderID, err)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to auto-cancel order %d: %v", orderID, err)
+		return err
 	}
+	middlewares.RecordAutoCancellation()
+	log.Printf("Auto-cancelled order %d due to non-payment", orderID)
+	return nil
 }
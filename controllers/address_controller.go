@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"order-service/apperrors"
+	"order-service/database"
+	"order-service/models"
+	"order-service/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	addressTypeShipping = "shipping"
+	addressTypeBilling  = "billing"
+)
+
+// queryRower 由 *sql.DB 和 *sql.Tx 实现
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// CreateAddress 保存地址到用户地址簿
+func CreateAddress(c *gin.Context) {
+	defer recordOperation(c, "create_address")
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var request struct {
+		Label   string         `json:"label"`
+		Address models.Address `json:"address"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(apperrors.FromBinding(err))
+		return
+	}
+	if fields := validation.ValidateAddress("address", &request.Address); len(fields) > 0 {
+		_ = c.Error(apperrors.Validation("validation_failed", "Address validation failed", fields...))
+		return
+	}
+
+	a := request.Address
+	result, err := database.DB.Exec(`
+		INSERT INTO user_addresses (user_id, label, recipient_name, phone, line1, line2, city, state, postal_code, country)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, userID, request.Label, a.RecipientName, a.Phone, a.Line1, a.Line2, a.City, a.State, a.PostalCode, a.Country)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Failed to save address", err))
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Failed to save address", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.UserAddress{ID: int(id), UserID: userID, Label: request.Label, Address: a})
+}
+
+// ListAddresses 获取用户地址簿
+func ListAddresses(c *gin.Context) {
+	defer recordOperation(c, "list_addresses")
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, label, recipient_name, phone, line1, line2, city, state, postal_code, country
+		FROM user_addresses
+		WHERE user_id = ?
+		ORDER BY id
+	`, userID)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+	defer rows.Close()
+
+	addresses := []models.UserAddress{}
+	for rows.Next() {
+		ua := models.UserAddress{UserID: userID}
+		a := &ua.Address
+		if err := rows.Scan(&ua.ID, &ua.Label, &a.RecipientName, &a.Phone, &a.Line1, &a.Line2,
+			&a.City, &a.State, &a.PostalCode, &a.Country); err != nil {
+			_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+			return
+		}
+		addresses = append(addresses, ua)
+	}
+	if err := rows.Err(); err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, addresses)
+}
+
+// loadUserAddress 读取用户地址簿中的地址，不属于该用户时返回校验错误
+func loadUserAddress(q queryRower, userID, addressID int, field string) (*models.Address, error) {
+	var a models.Address
+	err := q.QueryRow(`
+		SELECT recipient_name, phone, line1, line2, city, state, postal_code, country
+		FROM user_addresses
+		WHERE id = ? AND user_id = ?
+	`, addressID, userID).Scan(&a.RecipientName, &a.Phone, &a.Line1, &a.Line2, &a.City, &a.State, &a.PostalCode, &a.Country)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperrors.Validation("validation_failed", "Order validation failed",
+			apperrors.FieldError{Field: field, Message: "does not refer to a saved address"})
+	}
+	if err != nil {
+		return nil, apperrors.Internal("database_error", "Failed to load address", err)
+	}
+	return &a, nil
+}
+
+// resolveOrderAddresses 将地址簿引用解析为地址快照，账单地址默认与收货地址相同
+func resolveOrderAddresses(q queryRower, order *models.Order) error {
+	if order.ShippingAddress == nil && order.ShippingAddressID != nil {
+		addr, err := loadUserAddress(q, order.UserID, *order.ShippingAddressID, "shipping_address_id")
+		if err != nil {
+			return err
+		}
+		order.ShippingAddress = addr
+	}
+	if order.BillingAddress == nil && order.BillingAddressID != nil {
+		addr, err := loadUserAddress(q, order.UserID, *order.BillingAddressID, "billing_address_id")
+		if err != nil {
+			return err
+		}
+		order.BillingAddress = addr
+	}
+	if order.BillingAddress == nil {
+		order.BillingAddress = order.ShippingAddress
+	}
+	return nil
+}
+
+// insertOrderAddress 保存订单地址快照，没有地址（无收货地址的自提订单）时不保存
+func insertOrderAddress(tx *sql.Tx, orderID int64, addressType string, a *models.Address, sourceID *int) error {
+	if a == nil {
+		return nil
+	}
+	_, err := tx.Exec(`
+		INSERT INTO order_addresses (order_id, address_type, source_address_id, recipient_name, phone,
+		                             line1, line2, city, state, postal_code, country)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, orderID, addressType, sourceID, a.RecipientName, a.Phone, a.Line1, a.Line2, a.City, a.State, a.PostalCode, a.Country)
+	return err
+}
+
+// loadOrderAddresses 读取订单的收货和账单地址快照
+func loadOrderAddresses(q querier, orderID int) (shipping, billing *models.Address, err error) {
+	rows, err := q.Query(`
+		SELECT address_type, recipient_name, phone, line1, line2, city, state, postal_code, country
+		FROM order_addresses
+		WHERE order_id = ?
+	`, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var addressType string
+		var a models.Address
+		if err := rows.Scan(&addressType, &a.RecipientName, &a.Phone, &a.Line1, &a.Line2,
+			&a.City, &a.State, &a.PostalCode, &a.Country); err != nil {
+			return nil, nil, err
+		}
+		switch addressType {
+		case addressTypeShipping:
+			shipping = &a
+		case addressTypeBilling:
+			billing = &a
+		}
+	}
+	return shipping, billing, rows.Err()
+}
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"order-service/apperrors"
+	"order-service/database"
+	"order-service/history"
+	"order-service/models"
+	"order-service/orderquery"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchSize 批量接口单次请求的订单数上限
+const maxBatchSize = 500
+
+// BatchGetOrders 批量查询当前用户的订单，订单头和商品行各用一次 IN 查询读取
+func BatchGetOrders(c *gin.Context) {
+	defer recordOperation(c, "batch_get")
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var request struct {
+		IDs []int `json:"ids" binding:"required,min=1,max=500,dive,min=1"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(apperrors.FromBinding(err))
+		return
+	}
+
+	result, err := LoadOrdersByIDs(sessionContext(c), userID, request.IDs)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	respondVersioned(c, http.StatusOK, result)
+}
+
+// LoadOrdersByIDs 读取用户的多个订单及其商品行，按请求顺序返回，重复的ID只返回一次。
+// 不包含地址快照和税行，需要时使用 LoadOrderDetails。读从库，用户刚写入过或 ctx 要求时读主库
+func LoadOrdersByIDs(ctx context.Context, userID int, orderIDs []int) (*models.BatchOrdersResult, error) {
+	ids := make([]int, 0, len(orderIDs))
+	seen := make(map[int]bool, len(orderIDs))
+	for _, id := range orderIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > maxBatchSize {
+		return nil, apperrors.Validation("batch_too_large", "Too many orders in one request",
+			apperrors.FieldError{Field: "ids", Message: fmt.Sprintf("must contain at most %d items", maxBatchSize)})
+	}
+
+	var orders []models.OrderResponse
+	err := database.Read(readContext(ctx, userID), func(db *sql.DB) error {
+		var err error
+		orders, err = orderquery.GetOrders(ctx, db, userID, ids)
+		return err
+	})
+	if err != nil {
+		return nil, apperrors.Internal("database_error", "Failed to read orders", err)
+	}
+
+	result := &models.BatchOrdersResult{Orders: orders, NotFound: []int{}}
+	found := make(map[int]bool, len(orders))
+	for _, o := range orders {
+		found[o.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			result.NotFound = append(result.NotFound, id)
+		}
+	}
+	return result, nil
+}
+
+// BatchUpdateOrderStatus 管理端批量修改订单状态。每个订单在独立事务中按状态机检查后修改，
+// 部分订单失败不影响其他订单，状态发生变化的订单各发布一个事件
+func BatchUpdateOrderStatus(c *gin.Context) {
+	defer recordOperation(c, "batch_update_status")
+
+	var request struct {
+		Updates []models.OrderStatusUpdate `json:"updates" binding:"required,min=1,max=500,dive"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(apperrors.FromBinding(err))
+		return
+	}
+
+	// 同一订单在一次请求中只能出现一次，否则结果取决于处理顺序
+	seen := make(map[int]bool, len(request.Updates))
+	for i, update := range request.Updates {
+		if seen[update.OrderID] {
+			_ = c.Error(apperrors.Validation("duplicate_order_id", "Each order may appear only once",
+				apperrors.FieldError{Field: fmt.Sprintf("updates[%d].order_id", i), Message: "is duplicated"}))
+			return
+		}
+		seen[update.OrderID] = true
+	}
+
+	actor := adminActor(c)
+	response := models.BatchStatusUpdateResponse{Results: make([]models.OrderStatusUpdateResult, 0, len(request.Updates))}
+	for _, update := range request.Updates {
+		result := applyStatusUpdate(c.Request.Context(), update, actor)
+		switch result.Result {
+		case models.BatchResultUpdated:
+			response.Updated++
+		case models.BatchResultUnchanged:
+			response.Unchanged++
+		default:
+			response.Failed++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// applyStatusUpdate 在独立事务中修改一个订单的状态，提交后发布状态变更事件
+func applyStatusUpdate(ctx context.Context, update models.OrderStatusUpdate, actor string) models.OrderStatusUpdateResult {
+	result := models.OrderStatusUpdateResult{OrderID: update.OrderID}
+	fail := func(err *apperrors.Error) models.OrderStatusUpdateResult {
+		if err.Kind == apperrors.KindInternal {
+			log.Printf("Batch status update failed for order %d: %v", update.OrderID, err)
+		}
+		result.Result = models.BatchResultFailed
+		result.ErrorCode = err.Code
+		result.Error = err.Message
+		return result
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(apperrors.Internal("transaction_failed", "Could not start transaction", err))
+	}
+	defer tx.Rollback()
+
+	var userID, version int
+	var oldStatus string
+	err = tx.QueryRow("SELECT user_id, status, version FROM orders WHERE id = ? FOR UPDATE", update.OrderID).
+		Scan(&userID, &oldStatus, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fail(apperrors.NotFound("order_not_found", "Order not found"))
+	}
+	if err != nil {
+		return fail(apperrors.Internal("database_error", "Database error", err))
+	}
+	result.OldStatus = oldStatus
+	result.Version = version
+
+	if update.Version != 0 && update.Version != version {
+		return fail(apperrors.PreconditionFailed("etag_mismatch", "Order has been modified, reload it and retry"))
+	}
+	if update.Status == oldStatus {
+		result.Result = models.BatchResultUnchanged
+		result.NewStatus = oldStatus
+		return result
+	}
+	if !models.CanTransitionOrder(oldStatus, update.Status) {
+		return fail(apperrors.Conflict("invalid_transition",
+			fmt.Sprintf("Cannot change order status from %s to %s", oldStatus, update.Status)))
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE orders SET status = ?, version = version + 1, updated_at = NOW() WHERE id = ? AND version = ?",
+		update.Status, update.OrderID, version,
+	); err != nil {
+		return fail(apperrors.Internal("database_error", "Database error", err))
+	}
+	if err := history.Record(tx, models.OrderHistoryEntry{
+		OrderID:   update.OrderID,
+		OldStatus: oldStatus,
+		NewStatus: update.Status,
+		Actor:     actor,
+		Source:    history.SourceAdmin,
+		Reason:    strings.TrimSpace(update.Reason),
+	}); err != nil {
+		return fail(apperrors.Internal("database_error", "Failed to record order history", err))
+	}
+	if err := tx.Commit(); err != nil {
+		return fail(apperrors.Internal("transaction_failed", "Transaction commit failed", err))
+	}
+	markWrite(userID)
+
+	publishStatusChange(update.OrderID, userID, oldStatus, update.Status)
+
+	result.Result = models.BatchResultUpdated
+	result.NewStatus = update.Status
+	result.Version = version + 1
+	return result
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"order-service/database"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReadYourWritesHeader 写接口在响应中返回该头，值为毫秒级 Unix 时间戳。客户端在此之前的读请求带上原值，
+// 读取就会走主库，避免从库复制延迟导致读不到刚写入的数据
+const ReadYourWritesHeader = "X-Read-Your-Writes"
+
+var readYourWritesWindow = 5 * time.Second
+
+// recentWrites 本实例上每个用户最近一次写入后读主库的截止时间（userID -> time.Time），
+// gRPC 等不带会话提示的调用也能读到自己的写入。过期记录在读取时或定期清理时删除
+var recentWrites sync.Map
+
+var sweepRecentWritesOnce sync.Once
+
+// SetReadYourWritesWindow 设置写入后读主库的时间窗口，0 表示关闭
+func SetReadYourWritesWindow(window time.Duration) {
+	readYourWritesWindow = window
+	if window > 0 {
+		sweepRecentWritesOnce.Do(func() { go sweepRecentWrites(window) })
+	}
+}
+
+// markWrite 记录用户的写入，窗口内该用户在本实例上的读请求走主库
+func markWrite(userID int) {
+	if readYourWritesWindow <= 0 {
+		return
+	}
+	recentWrites.Store(userID, time.Now().Add(readYourWritesWindow))
+}
+
+// sweepRecentWrites 定期删除过期记录，写入后不再读取的用户也不会一直占用内存
+func sweepRecentWrites(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := time.Now()
+		recentWrites.Range(func(userID, until interface{}) bool {
+			if !until.(time.Time).After(now) {
+				recentWrites.CompareAndDelete(userID, until)
+			}
+			return true
+		})
+	}
+}
+
+// setSessionHint 在写接口的响应中返回会话提示头
+func setSessionHint(c *gin.Context) {
+	if readYourWritesWindow > 0 {
+		until := time.Now().Add(readYourWritesWindow)
+		c.Header(ReadYourWritesHeader, strconv.FormatInt(until.UnixMilli(), 10))
+	}
+}
+
+// sessionContext 请求带有未过期的会话提示时返回要求读主库的 context。
+// 截止时间超过当前时间加窗口的提示视为无效，客户端不能借此长期占用主库
+func sessionContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	value := c.GetHeader(ReadYourWritesHeader)
+	if value == "" {
+		return ctx
+	}
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return ctx
+	}
+	now := time.Now()
+	if until := time.UnixMilli(millis); until.After(now) && !until.After(now.Add(readYourWritesWindow)) {
+		return database.WithPrimary(ctx)
+	}
+	return ctx
+}
+
+// readContext 用户在本实例上刚写入过时返回要求读主库的 context，顺带删除已过期的记录
+func readContext(ctx context.Context, userID int) context.Context {
+	until, ok := recentWrites.Load(userID)
+	if !ok {
+		return ctx
+	}
+	if time.Now().Before(until.(time.Time)) {
+		return database.WithPrimary(ctx)
+	}
+	recentWrites.CompareAndDelete(userID, until)
+	return ctx
+}
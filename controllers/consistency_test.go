@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReadContextAfterWrite(t *testing.T) {
+	defer func(window time.Duration) { readYourWritesWindow = window }(readYourWritesWindow)
+	readYourWritesWindow = time.Minute
+	ctx := context.Background()
+
+	markWrite(1)
+	if readContext(ctx, 1) == ctx {
+		t.Error("read after write should use the primary")
+	}
+	if readContext(ctx, 2) != ctx {
+		t.Error("user without writes should not use the primary")
+	}
+
+	// 过期记录在读取时删除
+	recentWrites.Store(3, time.Now().Add(-time.Second))
+	if readContext(ctx, 3) != ctx {
+		t.Error("expired write should not use the primary")
+	}
+	if _, ok := recentWrites.Load(3); ok {
+		t.Error("expired write was not evicted")
+	}
+
+	readYourWritesWindow = 0
+	markWrite(4)
+	if _, ok := recentWrites.Load(4); ok {
+		t.Error("markWrite recorded a write with the window disabled")
+	}
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"order-service/apperrors"
+	"order-service/database"
+	"order-service/promotions"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+)
+
+// CreateCoupon 管理端创建优惠券
+func CreateCoupon(c *gin.Context) {
+	defer recordOperation(c, "create_coupon")
+
+	var coupon promotions.Coupon
+	if err := c.ShouldBindJSON(&coupon); err != nil {
+		_ = c.Error(apperrors.FromBinding(err))
+		return
+	}
+	coupon.Code = strings.TrimSpace(coupon.Code)
+	if fields := coupon.Validate(); len(fields) > 0 {
+		_ = c.Error(apperrors.Validation("validation_failed", "Coupon validation failed", fields...))
+		return
+	}
+
+	if err := promotions.CreateCoupon(database.DB, &coupon); err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+			_ = c.Error(apperrors.Conflict("coupon_exists", "Coupon code already exists"))
+			return
+		}
+		_ = c.Error(apperrors.Internal("database_error", "Failed to create coupon", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, coupon)
+}
+
+// ListCoupons 管理端查询优惠券
+func ListCoupons(c *gin.Context) {
+	defer recordOperation(c, "list_coupons")
+
+	coupons, err := promotions.ListCoupons(database.DB)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+	c.JSON(http.StatusOK, coupons)
+}
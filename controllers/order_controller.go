@@ -1,14 +1,22 @@
 package controllers
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"order-service/apperrors"
 	"order-service/database"
+	"order-service/history"
 	"order-service/models"
+	"order-service/orderquery"
+	"order-service/promotions"
 	"order-service/rabbitmq"
+	"order-service/validation"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,328 +25,744 @@ import (
 
 var rabbitMQ *rabbitmq.RabbitMQ
 
+var orderRules = validation.DefaultOrderRules
+
+// paymentTimeout 下单后检查支付状态的延迟，超时未支付的订单会被自动取消
+var paymentTimeout = 15 * time.Minute
+
 func SetRabbitMQ(rmq *rabbitmq.RabbitMQ) {
 	rabbitMQ = rmq
 }
 
-func CreateOrder(c *gin.Context) {
-	defer func() {
-		status := c.Writer.Status() >= 200 && c.Writer.Status() < 300
-		middlewares.RecordOrderOperation("create", status)
-	}()
+// SetPaymentTimeout 设置下单后检查支付状态的延迟
+func SetPaymentTimeout(timeout time.Duration) {
+	paymentTimeout = timeout
+}
+
+// SetOrderRules 设置订单创建的校验规则，未设置的限制使用默认值
+func SetOrderRules(rules validation.OrderRules) {
+	orderRules = rules.WithDefaults()
+}
+
+// recordOperation 记录订单操作指标，处理器通过 c.Error 返回错误时视为失败
+func recordOperation(c *gin.Context, operation string) {
+	success := len(c.Errors) == 0 && c.Writer.Status() >= 200 && c.Writer.Status() < 300
+	middlewares.RecordOrderOperation(operation, success)
+}
+
+// currentUserID 获取 AuthMiddleware 设置的用户ID
+func currentUserID(c *gin.Context) (int, error) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return 0, apperrors.Unauthorized("unauthenticated", "User not authenticated")
+	}
+	return userID.(int), nil
+}
+
+// orderIDParam 解析路径中的订单ID
+func orderIDParam(c *gin.Context) (int, error) {
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, apperrors.Validation("invalid_order_id", "Invalid order ID",
+			apperrors.FieldError{Field: "id", Message: "must be an integer"})
+	}
+	return orderID, nil
+}
+
+// orderETag 由订单版本号生成 ETag
+func orderETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchVersion 解析 If-Match 头中的订单版本号，修改订单的请求必须携带
+func ifMatchVersion(c *gin.Context) (int, error) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" {
+		return 0, apperrors.PreconditionRequired("if_match_required", "If-Match header with the order ETag is required")
+	}
+	value = strings.TrimPrefix(value, "W/")
+	version, err := strconv.Atoi(strings.Trim(value, `"`))
+	if err != nil || version <= 0 {
+		return 0, apperrors.PreconditionFailed("etag_mismatch", "If-Match does not match the current order version")
+	}
+	return version, nil
+}
+
+func CreateOrder(c *gin.Context) {
+	defer recordOperation(c, "create")
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	var order models.Order
-	if err := c.ShouldBindJSON(&order); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	order, err := bindCreateOrder(c)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	// 验证订单项
-	if len(order.Items) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Order must contain at least one item"})
+	requireAddress := middlewares.APIVersion(c) == middlewares.APIVersion2
+	orderID, err := PlaceOrder(c.Request.Context(), userID, order, requireAddress)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
+	setSessionHint(c)
+	c.JSON(http.StatusCreated, gin.H{"order_id": orderID})
+}
+
+// PlaceOrder 校验、定价并保存新订单，提交后发布 created 事件和延迟支付检查。
+// REST 和 gRPC 接口共用，requireAddress 为 true 时非自提订单必须提供收货地址
+func PlaceOrder(ctx context.Context, userID int, order *models.Order, requireAddress bool) (int64, error) {
+	rules := orderRules
+	rules.RequireShippingAddress = requireAddress
+
+	// 验证订单项并合并重复商品，同时计算总价
+	if err := validation.NormalizeOrder(order, rules); err != nil {
+		return 0, err
+	}
+
 	// 设置用户ID
-	order.UserID = userID.(int)
+	order.UserID = userID
 	order.Status = "pending"
 	order.CreatedAt = time.Now()
 	order.UpdatedAt = time.Now()
 
-	// 计算总价
-	for _, item := range order.Items {
-		order.Total += item.Price * float64(item.Quantity)
-	}
-
 	// 开始事务
 	tx, err := database.DB.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start transaction"})
-		return
+		return 0, apperrors.Internal("transaction_failed", "Could not start transaction", err)
+	}
+	defer tx.Rollback()
+
+	// 解析地址簿引用为地址快照
+	if err := resolveOrderAddresses(tx, order); err != nil {
+		return 0, err
+	}
+
+	// 计算优惠、税费、运费和订单金额
+	coupon, err := priceOrder(ctx, tx, order)
+	if err != nil {
+		return 0, err
 	}
 
 	// 插入订单
-	orderResult, err := tx.Exec(
-		"INSERT INTO orders (user_id, total, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
-		order.UserID, order.Total, order.Status, order.CreatedAt, order.UpdatedAt,
+	orderResult, err := tx.Exec(`
+		INSERT INTO orders (user_id, subtotal, discount, coupon_code, tax_total, delivery_option, shipping_fee, total,
+		                    currency, base_currency, exchange_rate, total_base, status,
+		                    delivery_instructions, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, order.UserID, order.Subtotal, order.Discount, order.CouponCode, order.TaxTotal, order.DeliveryOption, order.ShippingFee,
+		order.Total, order.Currency, order.BaseCurrency, order.ExchangeRate, order.TotalBase, order.Status,
+		order.DeliveryInstructions, order.CreatedAt, order.UpdatedAt,
 	)
 	if err != nil {
-		err := tx.Rollback()
-		if err != nil {
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
-		return
+		return 0, apperrors.Internal("order_create_failed", "Failed to create order", err)
 	}
 
 	orderID, err := orderResult.LastInsertId()
 	if err != nil {
-		err := tx.Rollback()
-		if err != nil {
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order ID"})
-		return
+		return 0, apperrors.Internal("order_create_failed", "Failed to get order ID", err)
 	}
 
 	// 插入订单项
 	for _, item := range order.Items {
 		_, err = tx.Exec(
-			"INSERT INTO order_items (order_id, product_id, product_name, quantity, price) VALUES (?, ?, ?, ?, ?)",
-			orderID, item.ProductID, item.ProductName, item.Quantity, item.Price,
+			"INSERT INTO order_items (order_id, product_id, product_name, quantity, price, discount) VALUES (?, ?, ?, ?, ?, ?)",
+			orderID, item.ProductID, item.ProductName, item.Quantity, item.Price, item.Discount,
 		)
 		if err != nil {
-			err := tx.Rollback()
-			if err != nil {
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add order item"})
-			return
+			return 0, apperrors.Internal("order_create_failed", "Failed to add order item", err)
+		}
+	}
+
+	// 保存税行
+	if err := insertTaxLines(tx, orderID, order.TaxLines); err != nil {
+		return 0, apperrors.Internal("order_create_failed", "Failed to save tax lines", err)
+	}
+
+	// 保存地址快照
+	if err := insertOrderAddress(tx, orderID, addressTypeShipping, order.ShippingAddress, order.ShippingAddressID); err != nil {
+		return 0, apperrors.Internal("order_create_failed", "Failed to save shipping address", err)
+	}
+	if err := insertOrderAddress(tx, orderID, addressTypeBilling, order.BillingAddress, order.BillingAddressID); err != nil {
+		return 0, apperrors.Internal("order_create_failed", "Failed to save billing address", err)
+	}
+
+	// 记录初始状态
+	if err := history.Record(tx, models.OrderHistoryEntry{
+		OrderID:   int(orderID),
+		NewStatus: order.Status,
+		Actor:     history.UserActor(userID),
+		Source:    history.SourceAPI,
+		CreatedAt: order.CreatedAt,
+	}); err != nil {
+		return 0, apperrors.Internal("order_create_failed", "Failed to record order history", err)
+	}
+
+	// 核销优惠券
+	if coupon != nil {
+		if err := promotions.Redeem(tx, coupon, order.UserID, orderID, order.Discount); err != nil {
+			return 0, apperrors.Internal("order_create_failed", "Failed to redeem coupon", err)
 		}
 	}
 
 	// 提交事务
 	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
-		return
+		return 0, apperrors.Internal("transaction_failed", "Transaction commit failed", err)
 	}
+	markWrite(userID)
 
-	c.JSON(http.StatusCreated, gin.H{"order_id": orderID})
+	middlewares.ObserveOrderCreated(order.TotalBase, len(order.Items))
 
 	// 事务提交成功后发送事件
 	if rabbitMQ != nil {
 		// 高优先级事件（VIP用户）
-		priority := 5           // 默认优先级
-		if order.Total > 1000 { // 大额订单高优先级
+		priority := 5               // 默认优先级
+		if order.TotalBase > 1000 { // 大额订单高优先级
 			priority = 9
 		}
 
-		if err := rabbitMQ.PublishOrderEvent(int(orderID), priority, "created"); err != nil {
+		event := models.OrderEvent{
+			OrderID:              int(orderID),
+			UserID:               order.UserID,
+			Type:                 "created",
+			Status:               order.Status,
+			Total:                order.Total,
+			Currency:             order.Currency,
+			ShippingAddress:      order.ShippingAddress,
+			DeliveryInstructions: order.DeliveryInstructions,
+			DeliveryOption:       order.DeliveryOption,
+			ShippingFee:          order.ShippingFee,
+		}
+		if err := rabbitMQ.PublishOrderEvent(event, priority); err != nil {
 			log.Printf("Failed to publish order created event: %v", err)
 		}
 
-		// 设置延迟事件（15分钟后检查支付状态）
-		if err := rabbitMQ.PublishDelayedEvent(int(orderID), 15*time.Minute, "payment_check"); err != nil {
+		// 设置延迟事件（paymentTimeout 后检查支付状态）
+		if err := rabbitMQ.PublishDelayedEvent(int(orderID), paymentTimeout, "payment_check"); err != nil {
 			log.Printf("Failed to publish delayed payment check event: %v", err)
 		}
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"order_id": orderID})
+	return orderID, nil
 }
 
 func GetUserOrders(c *gin.Context) {
-	defer func() {
-		status := c.Writer.Status() >= 200 && c.Writer.Status() < 300
-		middlewares.RecordOrderOperation("list", status)
-	}()
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	defer recordOperation(c, "list")
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	rows, err := database.DB.Query(`
-		SELECT o.id, o.total, o.status, o.created_at, 
-		       oi.id, oi.product_id, oi.product_name, oi.quantity, oi.price
-		FROM orders o
-		JOIN order_items oi ON o.id = oi.order_id
-		WHERE o.user_id = ?
-		ORDER BY o.created_at DESC, oi.id ASC
-	`, userID)
+	opts := orderquery.ListOptions{Currency: c.Query("currency"), Cursor: c.Query("page_token")}
+	if value := c.Query("page_size"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 || size > orderquery.MaxPageSize {
+			_ = c.Error(apperrors.Validation("invalid_page_size", "Invalid page size", apperrors.FieldError{
+				Field: "page_size", Message: fmt.Sprintf("must be an integer between 1 and %d", orderquery.MaxPageSize)}))
+			return
+		}
+		opts.PageSize = size
+	}
+
+	page, err := ListUserOrders(sessionContext(c), userID, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		_ = c.Error(err)
 		return
 	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
 
-		}
-	}(rows)
+	// 下一页通过 Link 头返回，响应体保持订单数组
+	if page.NextCursor != "" {
+		next := *c.Request.URL
+		query := next.Query()
+		query.Set("page_token", page.NextCursor)
+		next.RawQuery = query.Encode()
+		// 已弃用版本的 successor-version 链接也在 Link 头中，这里追加而不是覆盖
+		c.Writer.Header().Add("Link", "<"+next.RequestURI()+`>; rel="next"`)
+	}
+	respondVersioned(c, http.StatusOK, page.Orders)
+}
 
-	ordersMap := make(map[int]*models.OrderResponse)
-	for rows.Next() {
-		var (
-			orderID     int
-			total       float64
-			status      string
-			createdAt   time.Time
-			itemID      int
-			productID   int
-			productName string
-			quantity    int
-			price       float64
-		)
+// ListUserOrders 按创建时间倒序分页查询用户的订单，opts.Currency 不为空时按货币筛选。
+// 读从库，用户刚写入过或 ctx 要求时读主库
+func ListUserOrders(ctx context.Context, userID int, opts orderquery.ListOptions) (*orderquery.Page, error) {
+	var page *orderquery.Page
+	err := database.Read(readContext(ctx, userID), func(db *sql.DB) error {
+		var err error
+		page, err = orderquery.ListUserOrders(ctx, db, userID, opts)
+		return err
+	})
+	if errors.Is(err, orderquery.ErrInvalidCursor) {
+		return nil, apperrors.Validation("invalid_page_token", "Invalid page token",
+			apperrors.FieldError{Field: "page_token", Message: "must be a token returned by a previous page"})
+	}
+	if err != nil {
+		return nil, apperrors.Internal("database_error", "Failed to list orders", err)
+	}
+	return page, nil
+}
 
-		if err := rows.Scan(&orderID, &total, &status, &createdAt,
-			&itemID, &productID, &productName, &quantity, &price); err != nil {
-			log.Printf("Error scanning row: %v", err)
-			continue
+func GetOrderDetails(c *gin.Context) {
+	defer recordOperation(c, "details")
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	orderID, err := orderIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	order, err := LoadOrderDetails(sessionContext(c), userID, orderID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Header("ETag", orderETag(order.Version))
+	respondVersioned(c, http.StatusOK, order)
+}
+
+// LoadOrderDetails 查询用户订单的详情，包括商品行、地址快照和税行。
+// 三次查询读同一个库，用户刚写入过或 ctx 要求时读主库
+func LoadOrderDetails(ctx context.Context, userID, orderID int) (*models.OrderResponse, error) {
+	var order *models.OrderResponse
+	var message string
+	err := database.Read(readContext(ctx, userID), func(db *sql.DB) error {
+		var err error
+		// 订单头和商品行在一次查询中读取
+		order, err = orderquery.GetOrder(ctx, db, userID, orderID)
+		if err != nil {
+			message = "Database error"
+			return err
 		}
 
-		if _, exists := ordersMap[orderID]; !exists {
-			ordersMap[orderID] = &models.OrderResponse{
-				ID:        orderID,
-				UserID:    userID.(int),
-				Total:     total,
-				Status:    status,
-				CreatedAt: createdAt,
-				Items:     []models.OrderItemDetail{},
-			}
+		// 查询地址快照
+		order.ShippingAddress, order.BillingAddress, err = loadOrderAddresses(db, orderID)
+		if err != nil {
+			message = "Failed to get order addresses"
+			return err
 		}
 
-		ordersMap[orderID].Items = append(ordersMap[orderID].Items, models.OrderItemDetail{
-			ProductID:   productID,
-			ProductName: productName,
-			Quantity:    quantity,
-			Price:       price,
-			Subtotal:    price * float64(quantity),
-		})
+		// 查询税行
+		order.TaxLines, err = loadTaxLines(db, orderID)
+		if err != nil {
+			message = "Failed to get order tax lines"
+		}
+		return err
+	})
+	if errors.Is(err, orderquery.ErrNotFound) {
+		return nil, apperrors.NotFound("order_not_found", "Order not found")
 	}
-
-	orders := make([]models.OrderResponse, 0, len(ordersMap))
-	for _, order := range ordersMap {
-		orders = append(orders, *order)
+	if err != nil {
+		return nil, apperrors.Internal("database_error", message, err)
 	}
 
-	c.JSON(http.StatusOK, orders)
+	return order, nil
 }
 
-func GetOrderDetails(c *gin.Context) {
-	defer func() {
-		status := c.Writer.Status() >= 200 && c.Writer.Status() < 300
-		middlewares.RecordOrderOperation("details", status)
-	}()
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+func UpdateOrderStatus(c *gin.Context) {
+	defer recordOperation(c, "update_status")
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	orderID, err := strconv.Atoi(c.Param("id"))
+	orderID, err := orderIDParam(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		_ = c.Error(err)
 		return
 	}
 
-	// 查询订单基本信息
-	var order models.OrderResponse
-	err = database.DB.QueryRow(`
-		SELECT id, user_id, total, status, created_at
-		FROM orders
-		WHERE id = ? AND user_id = ?
-	`, orderID, userID).Scan(
-		&order.ID, &order.UserID, &order.Total, &order.Status, &order.CreatedAt,
-	)
-
+	expectedVersion, err := ifMatchVersion(c)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		_ = c.Error(err)
+		return
+	}
+
+	var request struct {
+		Status string `json:"status" binding:"required"`
+		Reason string `json:"reason" binding:"max=255"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(apperrors.FromBinding(err))
 		return
 	}
 
-	// 查询订单项
-	rows, err := database.DB.Query(`
-		SELECT product_id, product_name, quantity, price
-		FROM order_items
-		WHERE order_id = ?
-	`, orderID)
+	version, err := ChangeOrderStatus(c.Request.Context(), userID, orderID, expectedVersion, request.Status, request.Reason)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order items"})
+		_ = c.Error(err)
 		return
 	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
 
+	setSessionHint(c)
+	c.Header("ETag", orderETag(version))
+	c.JSON(http.StatusOK, gin.H{"message": "Order status updated", "order_id": orderID})
+}
+
+// ChangeOrderStatus 在订单版本与 expectedVersion 一致时修改状态并记录历史，返回新的版本号
+func ChangeOrderStatus(ctx context.Context, userID, orderID, expectedVersion int, status, reason string) (int, error) {
+	if !models.IsOrderStatus(status) {
+		return 0, apperrors.Validation("validation_failed", "Invalid status",
+			apperrors.FieldError{Field: "status", Message: "must be one of: " + strings.Join(models.OrderStatuses, " ")})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, apperrors.Internal("transaction_failed", "Could not start transaction", err)
+	}
+	defer tx.Rollback()
+
+	var oldStatus string
+	var version int
+	err = tx.QueryRow(
+		"SELECT status, version FROM orders WHERE id = ? AND user_id = ? FOR UPDATE", orderID, userID,
+	).Scan(&oldStatus, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, apperrors.NotFound("order_not_found", "Order not found or not authorized")
+	}
+	if err != nil {
+		return 0, apperrors.Internal("database_error", "Database error", err)
+	}
+	if version != expectedVersion {
+		return 0, apperrors.PreconditionFailed("etag_mismatch", "Order has been modified, reload it and retry")
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE orders 
+		SET status = ?, version = version + 1, updated_at = NOW()
+		WHERE id = ? AND version = ?
+	`, status, orderID, version); err != nil {
+		return 0, apperrors.Internal("database_error", "Database error", err)
+	}
+
+	if status != oldStatus {
+		if err := history.Record(tx, models.OrderHistoryEntry{
+			OrderID:   orderID,
+			OldStatus: oldStatus,
+			NewStatus: status,
+			Actor:     history.UserActor(userID),
+			Source:    history.SourceAPI,
+			Reason:    strings.TrimSpace(reason),
+		}); err != nil {
+			return 0, apperrors.Internal("database_error", "Failed to record order history", err)
 		}
-	}(rows)
+	}
 
-	for rows.Next() {
-		var item models.OrderItemDetail
-		if err := rows.Scan(&item.ProductID, &item.ProductName, &item.Quantity, &item.Price); err != nil {
-			log.Printf("Error scanning order item: %v", err)
-			continue
+	if err := tx.Commit(); err != nil {
+		return 0, apperrors.Internal("transaction_failed", "Transaction commit failed", err)
+	}
+	markWrite(userID)
+
+	if rabbitMQ != nil {
+		priority := 5              // 默认优先级
+		if status == "cancelled" { // 取消订单高优先级
+			priority = 8
+		}
+
+		event := models.OrderEvent{
+			OrderID: orderID,
+			UserID:  userID,
+			Type:    "status_updated",
+			Status:  status,
+		}
+		if err := rabbitMQ.PublishOrderEvent(event, priority); err != nil {
+			log.Printf("Failed to publish order updated event: %v", err)
 		}
-		item.Subtotal = item.Price * float64(item.Quantity)
-		order.Items = append(order.Items, item)
 	}
 
-	c.JSON(http.StatusOK, order)
+	return version + 1, nil
 }
 
-func UpdateOrderStatus(c *gin.Context) {
-	defer func() {
-		status := c.Writer.Status() >= 200 && c.Writer.Status() < 300
-		middlewares.RecordOrderOperation("update_status", status)
-	}()
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+// UpdateOrderItems 修改待支付订单的商品行，在同一事务中重新计算优惠、税费、运费和总额
+func UpdateOrderItems(c *gin.Context) {
+	defer recordOperation(c, "update_items")
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	orderID, err := strconv.Atoi(c.Param("id"))
+	orderID, err := orderIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	expectedVersion, err := ifMatchVersion(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		_ = c.Error(err)
 		return
 	}
 
 	var request struct {
-		Status string `json:"status" binding:"required,oneof=pending processing shipped delivered cancelled"`
+		Items  []models.OrderItemUpdate `json:"items" binding:"required,min=1,dive"`
+		Reason string                   `json:"reason" binding:"max=255"`
 	}
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperrors.FromBinding(err))
 		return
 	}
 
-	result, err := database.DB.Exec(`
-		UPDATE orders 
-		SET status = ?, updated_at = NOW()
+	tx, err := database.DB.Begin()
+	if err != nil {
+		_ = c.Error(apperrors.Internal("transaction_failed", "Could not start transaction", err))
+		return
+	}
+	defer tx.Rollback()
+
+	order := models.Order{ID: orderID, UserID: userID}
+	var version int
+	err = tx.QueryRow(`
+		SELECT status, version, currency, coupon_code, delivery_option
+		FROM orders
 		WHERE id = ? AND user_id = ?
-	`, request.Status, orderID, userID)
+		FOR UPDATE
+	`, orderID, userID).Scan(&order.Status, &version, &order.Currency, &order.CouponCode, &order.DeliveryOption)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = c.Error(apperrors.NotFound("order_not_found", "Order not found"))
+		return
+	}
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+	if version != expectedVersion {
+		_ = c.Error(apperrors.PreconditionFailed("etag_mismatch", "Order has been modified, reload it and retry"))
+		return
+	}
+	if order.Status != models.OrderStatusPending {
+		_ = c.Error(apperrors.Conflict("order_not_modifiable", "Order in status "+order.Status+" cannot be modified"))
+		return
+	}
 
+	// 读取现有商品行
+	rows, err := tx.Query("SELECT id, product_id, product_name, quantity FROM order_items WHERE order_id = ? ORDER BY id", orderID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		_ = c.Error(apperrors.Internal("database_error", "Failed to get order items", err))
+		return
+	}
+	var current []models.OrderItem
+	itemIDs := make(map[int]int) // product_id -> order_items.id
+	for rows.Next() {
+		var itemID int
+		var item models.OrderItem
+		if err := rows.Scan(&itemID, &item.ProductID, &item.ProductName, &item.Quantity); err != nil {
+			rows.Close()
+			_ = c.Error(apperrors.Internal("database_error", "Failed to get order items", err))
+			return
+		}
+		itemIDs[item.ProductID] = itemID
+		current = append(current, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Failed to get order items", err))
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found or not authorized"})
+	items, changes, err := validation.ApplyItemChanges(current, request.Items, orderRules)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
+	order.Items = items
 
-	c.JSON(http.StatusOK, gin.H{"message": "Order status updated", "order_id": orderID})
+	order.ShippingAddress, _, err = loadOrderAddresses(tx, orderID)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Failed to get order addresses", err))
+		return
+	}
 
-	if rabbitMQ != nil {
-		priority := 5                      // 默认优先级
-		if request.Status == "cancelled" { // 取消订单高优先级
-			priority = 8
+	// 撤销原优惠券核销后按新的商品行重新定价
+	if err := promotions.Release(tx, orderID); err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Failed to release coupon", err))
+		return
+	}
+	coupon, err := priceOrder(c.Request.Context(), tx, &order)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// 更新、新增和删除商品行
+	keep := make(map[int]bool, len(order.Items))
+	for _, item := range order.Items {
+		keep[item.ProductID] = true
+		if itemID, ok := itemIDs[item.ProductID]; ok {
+			_, err = tx.Exec(
+				"UPDATE order_items SET quantity = ?, price = ?, discount = ? WHERE id = ?",
+				item.Quantity, item.Price, item.Discount, itemID,
+			)
+		} else {
+			_, err = tx.Exec(
+				"INSERT INTO order_items (order_id, product_id, product_name, quantity, price, discount) VALUES (?, ?, ?, ?, ?, ?)",
+				orderID, item.ProductID, item.ProductName, item.Quantity, item.Price, item.Discount,
+			)
+		}
+		if err != nil {
+			_ = c.Error(apperrors.Internal("order_update_failed", "Failed to update order item", err))
+			return
+		}
+	}
+	for productID, itemID := range itemIDs {
+		if keep[productID] {
+			continue
+		}
+		if _, err := tx.Exec("DELETE FROM order_items WHERE id = ?", itemID); err != nil {
+			_ = c.Error(apperrors.Internal("order_update_failed", "Failed to remove order item", err))
+			return
 		}
+	}
 
-		if err := rabbitMQ.PublishOrderEvent(orderID, priority, "status_updated"); err != nil {
-			log.Printf("Failed to publish order updated event: %v", err)
+	// 重新保存税行
+	if _, err := tx.Exec("DELETE FROM order_tax_lines WHERE order_id = ?", orderID); err != nil {
+		_ = c.Error(apperrors.Internal("order_update_failed", "Failed to save tax lines", err))
+		return
+	}
+	if err := insertTaxLines(tx, int64(orderID), order.TaxLines); err != nil {
+		_ = c.Error(apperrors.Internal("order_update_failed", "Failed to save tax lines", err))
+		return
+	}
+
+	if coupon != nil {
+		if err := promotions.Redeem(tx, coupon, userID, int64(orderID), order.Discount); err != nil {
+			_ = c.Error(apperrors.Internal("order_update_failed", "Failed to redeem coupon", err))
+			return
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Order status updated", "order_id": orderID})
+	if _, err := tx.Exec(`
+		UPDATE orders
+		SET subtotal = ?, discount = ?, tax_total = ?, shipping_fee = ?, total = ?,
+		    exchange_rate = ?, total_base = ?, version = version + 1, updated_at = NOW()
+		WHERE id = ? AND version = ?
+	`, order.Subtotal, order.Discount, order.TaxTotal, order.ShippingFee, order.Total,
+		order.ExchangeRate, order.TotalBase, orderID, version); err != nil {
+		_ = c.Error(apperrors.Internal("order_update_failed", "Failed to update order", err))
+		return
+	}
+
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		reason = describeItemChanges(changes)
+	}
+	if err := history.Record(tx, models.OrderHistoryEntry{
+		OrderID:   orderID,
+		OldStatus: order.Status,
+		NewStatus: order.Status,
+		Actor:     history.UserActor(userID),
+		Source:    history.SourceAPI,
+		Reason:    reason,
+	}); err != nil {
+		_ = c.Error(apperrors.Internal("order_update_failed", "Failed to record order history", err))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		_ = c.Error(apperrors.Internal("transaction_failed", "Transaction commit failed", err))
+		return
+	}
+	markWrite(userID)
+	setSessionHint(c)
+
+	if rabbitMQ != nil {
+		event := models.OrderEvent{
+			OrderID:     orderID,
+			UserID:      userID,
+			Type:        "items_changed",
+			Status:      order.Status,
+			Total:       order.Total,
+			Currency:    order.Currency,
+			ItemChanges: changes,
+		}
+		if err := rabbitMQ.PublishOrderEvent(event, 5); err != nil {
+			log.Printf("Failed to publish order items changed event: %v", err)
+		}
+	}
+
+	c.Header("ETag", orderETag(version+1))
+	respondVersioned(c, http.StatusOK, &models.OrderItemsResult{
+		OrderID:     orderID,
+		Subtotal:    order.Subtotal,
+		Discount:    order.Discount,
+		TaxTotal:    order.TaxTotal,
+		ShippingFee: order.ShippingFee,
+		Total:       order.Total,
+		Currency:    order.Currency,
+		Changes:     changes,
+	})
+}
+
+// describeItemChanges 生成历史记录中的商品行变化说明，截断到字段长度
+func describeItemChanges(changes []models.OrderItemChange) string {
+	parts := make([]string, len(changes))
+	for i, change := range changes {
+		parts[i] = fmt.Sprintf("product %d: %d -> %d", change.ProductID, change.OldQuantity, change.NewQuantity)
+	}
+	reason := "items changed: " + strings.Join(parts, ", ")
+	if len(reason) > 255 {
+		reason = reason[:252] + "..."
+	}
+	return reason
+}
+
+// GetOrderHistory 查询订单状态变更历史
+func GetOrderHistory(c *gin.Context) {
+	defer recordOperation(c, "history")
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	orderID, err := orderIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var exists int
+	err = database.DB.QueryRow("SELECT 1 FROM orders WHERE id = ? AND user_id = ?", orderID, userID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = c.Error(apperrors.NotFound("order_not_found", "Order not found"))
+		return
+	}
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+
+	entries, err := history.List(database.DB, orderID)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Failed to get order history", err))
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// adminActor 管理端操作人，由调用方通过 X-Admin-Actor 头提供
+func adminActor(c *gin.Context) string {
+	if actor := strings.TrimSpace(c.GetHeader("X-Admin-Actor")); actor != "" {
+		if len(actor) > 64 {
+			actor = actor[:64]
+		}
+		return actor
+	}
+	return "admin"
 }
 
 // HandleDeadLetter 死信队列处理函数
 func HandleDeadLetter(c *gin.Context) {
-	defer func() {
-		status := c.Writer.Status() >= 200 && c.Writer.Status() < 300
-		middlewares.RecordOrderOperation("dead_letter", status)
-	}()
+	defer recordOperation(c, "dead_letter")
 
 	var deadLetter struct {
 		OrderID int    `json:"order_id"`
@@ -346,11 +770,12 @@ func HandleDeadLetter(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&deadLetter); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperrors.FromBinding(err))
 		return
 	}
 
 	log.Printf("Handling dead letter for order %d: %s", deadLetter.OrderID, deadLetter.Reason)
+	middlewares.RecordDeadLetter("http")
 
 	// 实际处理逻辑：记录、通知管理员等
 	c.JSON(http.StatusOK, gin.H{"message": "Dead letter processed"})
@@ -0,0 +1,367 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"order-service/apperrors"
+	"order-service/currency"
+	"order-service/models"
+	"order-service/promotions"
+	"order-service/shipping"
+	"order-service/tax"
+	"order-service/validation"
+	"strings"
+)
+
+var (
+	taxCalculator      tax.Calculator        = tax.NewTableCalculator(tax.DefaultRules)
+	shippingRates      shipping.RateProvider = shipping.NewTableRateProvider(shipping.DefaultRules)
+	defaultWeightGrams                       = 500
+	rateSource         currency.RateSource   = currency.StaticRateSource{Base: "CNY"}
+	baseCurrency                             = "CNY"
+	pickupLocation                           = tax.Destination{Country: "CN"}
+)
+
+// SetRateSource 设置汇率来源和报表使用的基准货币。
+// 优惠券金额和运费规则均以基准货币配置
+func SetRateSource(source currency.RateSource, base string) {
+	rateSource = source
+	baseCurrency = base
+}
+
+// SetTaxCalculator 设置税费计算实现
+func SetTaxCalculator(calc tax.Calculator) {
+	taxCalculator = calc
+}
+
+// SetShippingRateProvider 设置运费计算实现
+func SetShippingRateProvider(provider shipping.RateProvider) {
+	shippingRates = provider
+}
+
+// SetPickupLocation 设置自提点所在地区，自提订单在自提点完成交易，按该地区计税
+func SetPickupLocation(dest tax.Destination) {
+	pickupLocation = dest
+}
+
+// SetDefaultWeightGrams 设置未配置重量商品的默认单件重量
+func SetDefaultWeightGrams(grams int) {
+	defaultWeightGrams = grams
+}
+
+// priceOrder 在下单事务中计算订单金额：总额 = 商品合计 - 优惠 + 价外税 + 运费。
+// 商品价格取自订单货币的商品目录，并保存对基准货币的汇率快照。
+// 使用优惠券时锁定并校验优惠券，返回的优惠券需要在订单写入后核销
+func priceOrder(ctx context.Context, tx *sql.Tx, order *models.Order) (*promotions.Coupon, error) {
+	rate, err := rateSource.Rate(ctx, order.Currency, baseCurrency)
+	if err != nil {
+		var unknown *currency.ErrUnknownCurrency
+		if errors.As(err, &unknown) {
+			return nil, apperrors.Validation("validation_failed", "Order validation failed",
+				apperrors.FieldError{Field: "currency", Message: "has no exchange rate"})
+		}
+		return nil, apperrors.Unavailable("exchange_rate_unavailable", "Exchange rates are unavailable").Wrap(err)
+	}
+	order.BaseCurrency = baseCurrency
+	order.ExchangeRate = rate.Value
+
+	if err := applyCatalogPrices(tx, order); err != nil {
+		return nil, err
+	}
+
+	var subtotal float64
+	for i := range order.Items {
+		order.Items[i].Discount = 0
+		subtotal += order.Items[i].Price * float64(order.Items[i].Quantity)
+	}
+	order.Subtotal = validation.RoundMoney(subtotal)
+	order.Discount = 0
+
+	var coupon *promotions.Coupon
+	if order.CouponCode != "" {
+		var err error
+		coupon, err = promotions.LockCoupon(tx, order.CouponCode, order.UserID, order.Subtotal*rate.Value)
+		if err != nil {
+			return nil, err
+		}
+		// 固定金额优惠以基准货币配置，换算为订单货币
+		localized := *coupon
+		if localized.Type == promotions.TypeFixed {
+			localized.Value = validation.RoundMoney(localized.Value / rate.Value)
+		}
+		result := promotions.Apply(&localized, order.Items)
+		for i, discount := range result.LineDiscounts {
+			order.Items[i].Discount = discount
+		}
+		order.Discount = result.OrderDiscount
+	}
+
+	if err := calculateTax(ctx, tx, order); err != nil {
+		return nil, err
+	}
+
+	if err := calculateShipping(ctx, tx, order); err != nil {
+		return nil, err
+	}
+
+	order.Total = validation.RoundMoney(order.Subtotal - order.Discount + order.TaxTotal + order.ShippingFee)
+	order.TotalBase = validation.RoundMoney(order.Total * rate.Value)
+	return coupon, nil
+}
+
+// applyCatalogPrices 使用订单货币的目录价格，客户端提供的价格必须与目录一致。
+// 基准货币的订单中没有目录价格的商品沿用客户端价格，兼容目录价格导入完成前的旧客户端；
+// 其他货币必须有目录价格
+func applyCatalogPrices(tx *sql.Tx, order *models.Order) error {
+	if len(order.Items) == 0 {
+		return nil
+	}
+	placeholders, args := productIDArgs(order.Items)
+	args = append(args, order.Currency)
+	rows, err := tx.Query(
+		"SELECT product_id, price FROM product_prices WHERE product_id IN "+placeholders+" AND currency = ?", args...,
+	)
+	if err != nil {
+		return apperrors.Internal("database_error", "Failed to load catalog prices", err)
+	}
+	defer rows.Close()
+
+	prices := make(map[int]float64)
+	for rows.Next() {
+		var productID int
+		var price float64
+		if err := rows.Scan(&productID, &price); err != nil {
+			return apperrors.Internal("database_error", "Failed to load catalog prices", err)
+		}
+		prices[productID] = price
+	}
+	if err := rows.Err(); err != nil {
+		return apperrors.Internal("database_error", "Failed to load catalog prices", err)
+	}
+
+	var fields []apperrors.FieldError
+	for i := range order.Items {
+		item := &order.Items[i]
+		price, ok := prices[item.ProductID]
+		if !ok && order.Currency == baseCurrency && item.Price > 0 {
+			continue
+		}
+		if !ok {
+			fields = append(fields, apperrors.FieldError{
+				Field:   fmt.Sprintf("items[%d].product_id", i),
+				Message: "is not available in " + order.Currency,
+			})
+			continue
+		}
+		if item.Price > 0 && validation.RoundMoney(item.Price) != validation.RoundMoney(price) {
+			fields = append(fields, apperrors.FieldError{
+				Field:   fmt.Sprintf("items[%d].price", i),
+				Message: fmt.Sprintf("does not match the catalog price %.2f", price),
+			})
+			continue
+		}
+		item.Price = price
+	}
+	if len(fields) > 0 {
+		return apperrors.Validation("validation_failed", "Order validation failed", fields...)
+	}
+	return nil
+}
+
+// calculateTax 按商品税类和收货地区计算每行税额，价外税计入 TaxTotal。
+// 自提订单按自提点所在地区计税
+func calculateTax(ctx context.Context, tx *sql.Tx, order *models.Order) error {
+	order.TaxLines = nil
+	order.TaxTotal = 0
+	dest, ok := taxDestination(order)
+	if !ok {
+		return nil
+	}
+
+	if err := loadTaxClasses(tx, order.Items); err != nil {
+		return apperrors.Internal("database_error", "Failed to load product tax classes", err)
+	}
+
+	lines := make([]tax.Line, len(order.Items))
+	for i, item := range order.Items {
+		lines[i] = tax.Line{
+			ProductID: item.ProductID,
+			TaxClass:  item.TaxClass,
+			Amount:    validation.RoundMoney(item.Price*float64(item.Quantity) - item.Discount),
+		}
+	}
+	taxes, err := taxCalculator.Calculate(ctx, dest, lines)
+	if err != nil {
+		return apperrors.Unavailable("tax_unavailable", "Tax calculation is unavailable").Wrap(err)
+	}
+
+	var total float64
+	order.TaxLines = make([]models.TaxLine, len(taxes))
+	for i, t := range taxes {
+		order.TaxLines[i] = models.TaxLine(t)
+		if !t.Inclusive {
+			total += t.Amount
+		}
+	}
+	order.TaxTotal = validation.RoundMoney(total)
+	return nil
+}
+
+// taxDestination 返回订单的计税地区，没有收货地址的非自提订单无法计税
+func taxDestination(order *models.Order) (tax.Destination, bool) {
+	if order.DeliveryOption == shipping.OptionPickup {
+		return pickupLocation, true
+	}
+	if order.ShippingAddress == nil {
+		return tax.Destination{}, false
+	}
+	return tax.Destination{Country: order.ShippingAddress.Country, State: order.ShippingAddress.State}, true
+}
+
+// calculateShipping 按配送方式、商品重量和目的地计算运费
+func calculateShipping(ctx context.Context, tx *sql.Tx, order *models.Order) error {
+	if err := loadProductWeights(tx, order.Items); err != nil {
+		return apperrors.Internal("database_error", "Failed to load product weights", err)
+	}
+
+	items := make([]shipping.Item, len(order.Items))
+	for i, item := range order.Items {
+		items[i] = shipping.Item{ProductID: item.ProductID, Quantity: item.Quantity, WeightGrams: item.WeightGrams}
+	}
+	var dest shipping.Destination
+	if order.ShippingAddress != nil {
+		dest = shipping.Destination{Country: order.ShippingAddress.Country, State: order.ShippingAddress.State}
+	}
+
+	// 运费规则以基准货币配置
+	quote, err := shippingRates.Quote(ctx, order.DeliveryOption, dest, items, (order.Subtotal-order.Discount)*order.ExchangeRate)
+	if err != nil {
+		var noRate *shipping.ErrNoRate
+		if errors.As(err, &noRate) {
+			return apperrors.Validation("validation_failed", "Order validation failed", apperrors.FieldError{
+				Field:   "delivery_option",
+				Message: "is not available for the shipping destination",
+			})
+		}
+		return apperrors.Unavailable("shipping_unavailable", "Shipping rate calculation is unavailable").Wrap(err)
+	}
+	order.ShippingFee = validation.RoundMoney(quote.Fee / order.ExchangeRate)
+	return nil
+}
+
+// productIDArgs 生成商品ID的 IN 占位符和参数
+func productIDArgs(items []models.OrderItem) (string, []interface{}) {
+	args := make([]interface{}, len(items))
+	for i, item := range items {
+		args[i] = item.ProductID
+	}
+	return "(?" + strings.Repeat(", ?", len(items)-1) + ")", args
+}
+
+// loadTaxClasses 查询商品税类，未配置的商品使用默认税类
+func loadTaxClasses(tx *sql.Tx, items []models.OrderItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	for i := range items {
+		items[i].TaxClass = tax.DefaultTaxClass
+	}
+	placeholders, args := productIDArgs(items)
+	rows, err := tx.Query("SELECT product_id, tax_class FROM product_tax_classes WHERE product_id IN "+placeholders, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	classes := make(map[int]string)
+	for rows.Next() {
+		var productID int
+		var taxClass string
+		if err := rows.Scan(&productID, &taxClass); err != nil {
+			return err
+		}
+		classes[productID] = taxClass
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range items {
+		if taxClass, ok := classes[items[i].ProductID]; ok {
+			items[i].TaxClass = taxClass
+		}
+	}
+	return nil
+}
+
+// loadProductWeights 查询商品单件重量，未配置的商品使用默认重量
+func loadProductWeights(tx *sql.Tx, items []models.OrderItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	for i := range items {
+		items[i].WeightGrams = defaultWeightGrams
+	}
+	placeholders, args := productIDArgs(items)
+	rows, err := tx.Query("SELECT product_id, weight_grams FROM product_weights WHERE product_id IN "+placeholders, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	weights := make(map[int]int)
+	for rows.Next() {
+		var productID, grams int
+		if err := rows.Scan(&productID, &grams); err != nil {
+			return err
+		}
+		weights[productID] = grams
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range items {
+		if grams, ok := weights[items[i].ProductID]; ok {
+			items[i].WeightGrams = grams
+		}
+	}
+	return nil
+}
+
+// insertTaxLines 保存订单税行
+func insertTaxLines(tx *sql.Tx, orderID int64, lines []models.TaxLine) error {
+	for _, t := range lines {
+		if _, err := tx.Exec(`
+			INSERT INTO order_tax_lines (order_id, product_id, tax_class, jurisdiction, rate, taxable_amount, amount, inclusive)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, orderID, t.ProductID, t.TaxClass, t.Jurisdiction, t.Rate, t.TaxableAmount, t.Amount, t.Inclusive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTaxLines 读取订单税行
+func loadTaxLines(q querier, orderID int) ([]models.TaxLine, error) {
+	rows, err := q.Query(`
+		SELECT product_id, tax_class, jurisdiction, rate, taxable_amount, amount, inclusive
+		FROM order_tax_lines
+		WHERE order_id = ?
+		ORDER BY id
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []models.TaxLine
+	for rows.Next() {
+		var t models.TaxLine
+		if err := rows.Scan(&t.ProductID, &t.TaxClass, &t.Jurisdiction, &t.Rate, &t.TaxableAmount, &t.Amount, &t.Inclusive); err != nil {
+			return nil, err
+		}
+		lines = append(lines, t)
+	}
+	return lines, rows.Err()
+}
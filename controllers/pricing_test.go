@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"order-service/models"
+	"order-service/shipping"
+	"order-service/tax"
+	"testing"
+)
+
+func TestTaxDestination(t *testing.T) {
+	store := tax.Destination{Country: "US", State: "NY"}
+	defer SetPickupLocation(pickupLocation)
+	SetPickupLocation(store)
+
+	address := &models.Address{Country: "US", State: "CA"}
+	tests := []struct {
+		name   string
+		order  models.Order
+		want   tax.Destination
+		wantOK bool
+	}{
+		{
+			name:   "shipping address",
+			order:  models.Order{DeliveryOption: shipping.OptionStandard, ShippingAddress: address},
+			want:   tax.Destination{Country: "US", State: "CA"},
+			wantOK: true,
+		},
+		{
+			name:   "pickup without address taxed at the store",
+			order:  models.Order{DeliveryOption: shipping.OptionPickup},
+			want:   store,
+			wantOK: true,
+		},
+		{
+			name:   "pickup with address still taxed at the store",
+			order:  models.Order{DeliveryOption: shipping.OptionPickup, ShippingAddress: address},
+			want:   store,
+			wantOK: true,
+		},
+		{
+			name:  "delivery without address",
+			order: models.Order{DeliveryOption: shipping.OptionStandard},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := taxDestination(&tt.order)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("taxDestination() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// 没有收货地址的自提订单不保存地址快照，也不能访问事务
+func TestInsertOrderAddressSkipsMissingAddress(t *testing.T) {
+	if err := insertOrderAddress(nil, 1, addressTypeShipping, nil, nil); err != nil {
+		t.Errorf("insertOrderAddress(nil address) = %v, want nil", err)
+	}
+}
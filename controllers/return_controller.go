@@ -0,0 +1,547 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"order-service/apperrors"
+	"order-service/database"
+	"order-service/history"
+	"order-service/models"
+	"order-service/refunds"
+	"order-service/validation"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	refunder             refunds.Refunder = refunds.LogRefunder{}
+	restockingFeePercent float64
+)
+
+// SetRefunder 设置退款执行实现
+func SetRefunder(r refunds.Refunder) {
+	refunder = r
+}
+
+// SetRestockingFeePercent 设置默认退货手续费比例（0-100）
+func SetRestockingFeePercent(percent float64) {
+	restockingFeePercent = percent
+}
+
+// RequestReturn 用户对已签收订单的商品申请退货
+func RequestReturn(c *gin.Context) {
+	defer recordOperation(c, "request_return")
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	orderID, err := orderIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var request struct {
+		Items []models.ReturnItem `json:"items" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(apperrors.FromBinding(err))
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		_ = c.Error(apperrors.Internal("transaction_failed", "Could not start transaction", err))
+		return
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM orders WHERE id = ? AND user_id = ? FOR UPDATE", orderID, userID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = c.Error(apperrors.NotFound("order_not_found", "Order not found"))
+		return
+	}
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+	if status != models.OrderStatusDelivered {
+		_ = c.Error(apperrors.Conflict("order_not_returnable", "Only delivered orders can be returned"))
+		return
+	}
+
+	lines, err := loadReturnableLines(tx, orderID)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+
+	// 校验退货数量不超过可退数量
+	var fields []apperrors.FieldError
+	var itemsAmount float64
+	requested := make(map[int]int)
+	for i := range request.Items {
+		item := &request.Items[i]
+		item.Reason = strings.TrimSpace(item.Reason)
+		line, ok := lines[item.ProductID]
+		if !ok {
+			fields = append(fields, apperrors.FieldError{Field: fmt.Sprintf("items[%d].product_id", i), Message: "is not part of this order"})
+			continue
+		}
+		requested[item.ProductID] += item.Quantity
+		if requested[item.ProductID] > line.ordered-line.returned {
+			fields = append(fields, apperrors.FieldError{
+				Field:   fmt.Sprintf("items[%d].quantity", i),
+				Message: fmt.Sprintf("exceeds returnable quantity %d", line.ordered-line.returned),
+			})
+		}
+		item.Price = line.unitPrice()
+		itemsAmount += line.refundAmount(item.Quantity)
+	}
+	if len(fields) > 0 {
+		_ = c.Error(apperrors.Validation("validation_failed", "Return validation failed", fields...))
+		return
+	}
+
+	ret := models.ReturnRequest{
+		OrderID:     orderID,
+		UserID:      userID,
+		Status:      models.ReturnStatusRequested,
+		Items:       request.Items,
+		ItemsAmount: validation.RoundMoney(itemsAmount),
+	}
+	result, err := tx.Exec(`
+		INSERT INTO return_requests (order_id, user_id, status, items_amount, created_at, updated_at)
+		VALUES (?, ?, ?, ?, NOW(), NOW())
+	`, orderID, userID, ret.Status, ret.ItemsAmount)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Failed to create return request", err))
+		return
+	}
+	returnID, err := result.LastInsertId()
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Failed to create return request", err))
+		return
+	}
+
+	for _, item := range ret.Items {
+		if _, err := tx.Exec(
+			"INSERT INTO return_items (return_id, order_item_id, quantity, reason) VALUES (?, ?, ?, ?)",
+			returnID, lines[item.ProductID].orderItemID, item.Quantity, item.Reason,
+		); err != nil {
+			_ = c.Error(apperrors.Internal("database_error", "Failed to add return item", err))
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		_ = c.Error(apperrors.Internal("transaction_failed", "Transaction commit failed", err))
+		return
+	}
+
+	created, err := loadReturn(database.DB, int(returnID))
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+	publishReturnEvent("return_requested", created)
+
+	respondVersioned(c, http.StatusCreated, created)
+}
+
+// ListOrderReturns 用户查询订单的退货单
+func ListOrderReturns(c *gin.Context) {
+	defer recordOperation(c, "list_returns")
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	orderID, err := orderIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT id FROM return_requests WHERE order_id = ? AND user_id = ? ORDER BY id", orderID, userID,
+	)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+
+	returns := make([]*models.ReturnRequest, 0, len(ids))
+	for _, id := range ids {
+		ret, err := loadReturn(database.DB, id)
+		if err != nil {
+			_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+			return
+		}
+		returns = append(returns, ret)
+	}
+	respondVersioned(c, http.StatusOK, returns)
+}
+
+// ReviewReturn 管理端审核退货申请
+func ReviewReturn(c *gin.Context) {
+	defer recordOperation(c, "review_return")
+	returnID, err := returnIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var request struct {
+		Decision string `json:"decision" binding:"required,oneof=approve reject"`
+		Note     string `json:"note" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(apperrors.FromBinding(err))
+		return
+	}
+
+	newStatus := models.ReturnStatusApproved
+	if request.Decision == "reject" {
+		newStatus = models.ReturnStatusRejected
+	}
+
+	ret, err := transitionReturn(adminActor(c), returnID, models.ReturnStatusRequested, newStatus,
+		"review_note = ?", strings.TrimSpace(request.Note))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	publishReturnEvent("return_"+newStatus, ret)
+
+	c.JSON(http.StatusOK, ret)
+}
+
+// ReceiveReturn 管理端确认收到退货，计算退款金额并执行退款
+func ReceiveReturn(c *gin.Context) {
+	defer recordOperation(c, "receive_return")
+	returnID, err := returnIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var request struct {
+		RestockingFeePercent *float64 `json:"restocking_fee_percent" binding:"omitempty,gte=0,lte=100"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		_ = c.Error(apperrors.FromBinding(err))
+		return
+	}
+	feePercent := restockingFeePercent
+	if request.RestockingFeePercent != nil {
+		feePercent = *request.RestockingFeePercent
+	}
+
+	current, err := loadReturn(database.DB, returnID)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = c.Error(apperrors.NotFound("return_not_found", "Return request not found"))
+		return
+	}
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+
+	fee := validation.RoundMoney(current.ItemsAmount * feePercent / 100)
+	refund := validation.RoundMoney(current.ItemsAmount - fee)
+	ret, err := transitionReturn(adminActor(c), returnID, models.ReturnStatusApproved, models.ReturnStatusReceived,
+		"restocking_fee = ?, refund_amount = ?", fee, refund)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	publishReturnEvent("return_received", ret)
+
+	refunded, err := executeRefund(c, ret)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, refunded)
+}
+
+// RetryRefund 管理端重试失败的退款
+func RetryRefund(c *gin.Context) {
+	defer recordOperation(c, "retry_refund")
+	returnID, err := returnIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	ret, err := loadReturn(database.DB, returnID)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = c.Error(apperrors.NotFound("return_not_found", "Return request not found"))
+		return
+	}
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+	if ret.Status != models.ReturnStatusRefundFailed {
+		_ = c.Error(apperrors.Conflict("invalid_return_state", "Return request is in status "+ret.Status))
+		return
+	}
+
+	refunded, err := executeRefund(c, ret)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, refunded)
+}
+
+// executeRefund 先用带状态条件的更新把退货单占用为 refunding，只有占用成功的请求才调用退款接口，
+// 并发的确认收货或重试会得到冲突错误，不会重复退款。退款失败时进入 refund_failed 状态。
+// 退款成功但状态未能写回时退货单停留在 refunding，需要按支付渠道的记录人工处理
+func executeRefund(c *gin.Context, ret *models.ReturnRequest) (*models.ReturnRequest, error) {
+	actor := adminActor(c)
+	claimed, err := transitionReturn(actor, ret.ID, ret.Status, models.ReturnStatusRefunding, "")
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := refunder.Refund(c.Request.Context(), refunds.Request{
+		OrderID:        claimed.OrderID,
+		ReturnID:       claimed.ID,
+		UserID:         claimed.UserID,
+		Amount:         claimed.RefundAmount,
+		Currency:       claimed.Currency,
+		Reason:         "return",
+		IdempotencyKey: refunds.IdempotencyKey(claimed.ID),
+	})
+	if err != nil {
+		log.Printf("Refund failed for return %d: %v", claimed.ID, err)
+		updated, terr := transitionReturn(actor, claimed.ID, models.ReturnStatusRefunding, models.ReturnStatusRefundFailed, "")
+		if terr != nil {
+			log.Printf("Failed to mark return %d as refund failed: %v", claimed.ID, terr)
+			return claimed, nil
+		}
+		publishReturnEvent("return_refund_failed", updated)
+		return updated, nil
+	}
+
+	updated, err := transitionReturn(actor, claimed.ID, models.ReturnStatusRefunding, models.ReturnStatusRefunded,
+		"refund_reference = ?", result.Reference)
+	if err != nil {
+		log.Printf("Failed to mark return %d as refunded (reference %s): %v", claimed.ID, result.Reference, err)
+		return claimed, nil
+	}
+	publishReturnEvent("return_refunded", updated)
+	return updated, nil
+}
+
+// transitionReturn 在 from 状态下将退货单更新为 to 状态，可同时更新其他列。
+// 同一事务中写入订单状态历史，订单状态不变，reason 记录退货单的变化
+func transitionReturn(actor string, returnID int, from, to, extraSet string, extraArgs ...interface{}) (*models.ReturnRequest, error) {
+	set := "status = ?, updated_at = NOW()"
+	if extraSet != "" {
+		set += ", " + extraSet
+	}
+	args := append([]interface{}{to}, extraArgs...)
+	args = append(args, returnID, from)
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, apperrors.Internal("database_error", "Failed to update return request", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE return_requests SET "+set+" WHERE id = ? AND status = ?", args...)
+	if err != nil {
+		return nil, apperrors.Internal("database_error", "Failed to update return request", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		ret, err := loadReturn(tx, returnID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NotFound("return_not_found", "Return request not found")
+		}
+		if err != nil {
+			return nil, apperrors.Internal("database_error", "Database error", err)
+		}
+		return nil, apperrors.Conflict("invalid_return_state", "Return request is in status "+ret.Status)
+	}
+
+	ret, err := loadReturn(tx, returnID)
+	if err != nil {
+		return nil, apperrors.Internal("database_error", "Database error", err)
+	}
+	var orderStatus string
+	if err := tx.QueryRow("SELECT status FROM orders WHERE id = ?", ret.OrderID).Scan(&orderStatus); err != nil {
+		return nil, apperrors.Internal("database_error", "Database error", err)
+	}
+	if err := history.Record(tx, models.OrderHistoryEntry{
+		OrderID:   ret.OrderID,
+		OldStatus: orderStatus,
+		NewStatus: orderStatus,
+		Actor:     actor,
+		Source:    history.SourceAdmin,
+		Reason:    fmt.Sprintf("return %d %s", returnID, to),
+	}); err != nil {
+		return nil, apperrors.Internal("database_error", "Failed to record order history", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, apperrors.Internal("database_error", "Failed to update return request", err)
+	}
+	return ret, nil
+}
+
+func returnIDParam(c *gin.Context) (int, error) {
+	returnID, err := strconv.Atoi(c.Param("return_id"))
+	if err != nil {
+		return 0, apperrors.Validation("invalid_return_id", "Invalid return ID",
+			apperrors.FieldError{Field: "return_id", Message: "must be an integer"})
+	}
+	return returnID, nil
+}
+
+// returnableLine 订单商品行的订购数量、价格、分摊的优惠、价外税和已申请退货数量
+type returnableLine struct {
+	orderItemID int
+	ordered     int
+	price       float64
+	discount    float64 // 整行分摊的优惠
+	tax         float64 // 整行的价外税，价内税已包含在价格中
+	returned    int
+}
+
+// unitPrice 扣除优惠后的单价
+func (l *returnableLine) unitPrice() float64 {
+	if l.ordered <= 0 {
+		return l.price
+	}
+	return validation.RoundMoney(l.price - l.discount/float64(l.ordered))
+}
+
+// refundAmount 退回 quantity 件的金额：按数量比例退还实付的商品金额（扣除优惠）和价外税
+func (l *returnableLine) refundAmount(quantity int) float64 {
+	if l.ordered <= 0 {
+		return 0
+	}
+	paid := l.price*float64(l.ordered) - l.discount + l.tax
+	return validation.RoundMoney(paid * float64(quantity) / float64(l.ordered))
+}
+
+// returnableLineColumns 商品行的价格、优惠和价外税合计
+const returnableLineColumns = `oi.quantity, oi.price, oi.discount,
+		       COALESCE((SELECT SUM(tl.amount)
+		                 FROM order_tax_lines tl
+		                 WHERE tl.order_id = oi.order_id AND tl.product_id = oi.product_id AND NOT tl.inclusive), 0)`
+
+// loadReturnableLines 按 product_id 汇总可退货数量，已拒绝的退货单不计入
+func loadReturnableLines(tx *sql.Tx, orderID int) (map[int]*returnableLine, error) {
+	rows, err := tx.Query(`
+		SELECT oi.id, oi.product_id, `+returnableLineColumns+`,
+		       COALESCE((SELECT SUM(ri.quantity)
+		                 FROM return_items ri
+		                 JOIN return_requests rr ON rr.id = ri.return_id
+		                 WHERE ri.order_item_id = oi.id AND rr.status <> ?), 0)
+		FROM order_items oi
+		WHERE oi.order_id = ?
+	`, models.ReturnStatusRejected, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make(map[int]*returnableLine)
+	for rows.Next() {
+		var productID int
+		line := &returnableLine{}
+		if err := rows.Scan(&line.orderItemID, &productID, &line.ordered, &line.price, &line.discount, &line.tax,
+			&line.returned); err != nil {
+			return nil, err
+		}
+		lines[productID] = line
+	}
+	return lines, rows.Err()
+}
+
+// loadReturn 读取退货单及其商品
+func loadReturn(q querier, returnID int) (*models.ReturnRequest, error) {
+	rows, err := q.Query(`
+		SELECT rr.id, rr.order_id, rr.user_id, rr.status, o.currency, rr.items_amount, rr.restocking_fee, rr.refund_amount,
+		       rr.refund_reference, rr.review_note, rr.created_at, rr.updated_at,
+		       oi.product_id, ri.quantity, ri.reason, `+returnableLineColumns+`
+		FROM return_requests rr
+		JOIN orders o ON o.id = rr.order_id
+		JOIN return_items ri ON ri.return_id = rr.id
+		JOIN order_items oi ON oi.id = ri.order_item_id
+		WHERE rr.id = ?
+		ORDER BY ri.id
+	`, returnID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret *models.ReturnRequest
+	for rows.Next() {
+		var r models.ReturnRequest
+		var item models.ReturnItem
+		var line returnableLine
+		if err := rows.Scan(&r.ID, &r.OrderID, &r.UserID, &r.Status, &r.Currency, &r.ItemsAmount, &r.RestockingFee, &r.RefundAmount,
+			&r.RefundReference, &r.ReviewNote, &r.CreatedAt, &r.UpdatedAt,
+			&item.ProductID, &item.Quantity, &item.Reason, &line.ordered, &line.price, &line.discount, &line.tax); err != nil {
+			return nil, err
+		}
+		item.Price = line.unitPrice()
+		if ret == nil {
+			ret = &r
+		}
+		ret.Items = append(ret.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		return nil, sql.ErrNoRows
+	}
+	return ret, nil
+}
+
+// publishReturnEvent 发布退货相关事件
+func publishReturnEvent(eventType string, ret *models.ReturnRequest) {
+	if rabbitMQ == nil {
+		return
+	}
+	event := models.OrderEvent{
+		OrderID: ret.OrderID,
+		UserID:  ret.UserID,
+		Type:    eventType,
+		Total:   ret.RefundAmount,
+		Return:  ret,
+	}
+	if err := rabbitMQ.PublishOrderEvent(event, 5); err != nil {
+		log.Printf("Failed to publish %s event: %v", eventType, err)
+	}
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import "testing"
+
+func TestReturnableLineRefund(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      returnableLine
+		quantity  int
+		wantUnit  float64
+		wantTotal float64
+	}{
+		{
+			name:      "no discount or tax",
+			line:      returnableLine{ordered: 3, price: 10},
+			quantity:  2,
+			wantUnit:  10,
+			wantTotal: 20,
+		},
+		{
+			name:      "coupon discount spread over units",
+			line:      returnableLine{ordered: 4, price: 25, discount: 10},
+			quantity:  1,
+			wantUnit:  22.5,
+			wantTotal: 22.5,
+		},
+		{
+			name:      "discount and exclusive tax",
+			line:      returnableLine{ordered: 2, price: 50, discount: 10, tax: 7.2},
+			quantity:  1,
+			wantUnit:  45,
+			wantTotal: 48.6,
+		},
+		{
+			name:      "whole line refunds exactly what was paid",
+			line:      returnableLine{ordered: 3, price: 9.99, discount: 1, tax: 2.33},
+			quantity:  3,
+			wantUnit:  9.66,
+			wantTotal: 31.3,
+		},
+		{
+			name:      "rounded per request",
+			line:      returnableLine{ordered: 3, price: 10, discount: 1},
+			quantity:  1,
+			wantUnit:  9.67,
+			wantTotal: 9.67,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.line.unitPrice(); got != tt.wantUnit {
+				t.Errorf("unitPrice() = %v, want %v", got, tt.wantUnit)
+			}
+			if got := tt.line.refundAmount(tt.quantity); got != tt.wantTotal {
+				t.Errorf("refundAmount(%d) = %v, want %v", tt.quantity, got, tt.wantTotal)
+			}
+		})
+	}
+}
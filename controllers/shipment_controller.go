@@ -0,0 +1,415 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"order-service/apperrors"
+	"order-service/database"
+	"order-service/history"
+	"order-service/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateShipment 管理端为订单创建发货单，并根据发货情况更新订单状态
+func CreateShipment(c *gin.Context) {
+	defer recordOperation(c, "create_shipment")
+	orderID, err := orderIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var request struct {
+		Carrier        string                `json:"carrier" binding:"required,max=50"`
+		TrackingNumber string                `json:"tracking_number" binding:"required,max=100"`
+		ShippedAt      *time.Time            `json:"shipped_at"`
+		Items          []models.ShipmentItem `json:"items" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(apperrors.FromBinding(err))
+		return
+	}
+
+	shipment := models.Shipment{
+		OrderID:        orderID,
+		Carrier:        strings.TrimSpace(request.Carrier),
+		TrackingNumber: strings.TrimSpace(request.TrackingNumber),
+		Status:         models.ShipmentStatusShipped,
+		ShippedAt:      time.Now(),
+		Items:          request.Items,
+	}
+	if request.ShippedAt != nil {
+		shipment.ShippedAt = *request.ShippedAt
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		_ = c.Error(apperrors.Internal("transaction_failed", "Could not start transaction", err))
+		return
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var status string
+	err = tx.QueryRow("SELECT user_id, status FROM orders WHERE id = ? FOR UPDATE", orderID).Scan(&userID, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = c.Error(apperrors.NotFound("order_not_found", "Order not found"))
+		return
+	}
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+	if status != models.OrderStatusProcessing && status != models.OrderStatusPartiallyShipped {
+		_ = c.Error(apperrors.Conflict("order_not_shippable", "Order in status "+status+" cannot be shipped"))
+		return
+	}
+
+	lines, err := loadShippableLines(tx, orderID)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+
+	// 校验发货数量不超过未发货数量
+	var fields []apperrors.FieldError
+	requested := make(map[int]int)
+	for i, item := range shipment.Items {
+		line, ok := lines[item.ProductID]
+		if !ok {
+			fields = append(fields, apperrors.FieldError{Field: fmt.Sprintf("items[%d].product_id", i), Message: "is not part of this order"})
+			continue
+		}
+		requested[item.ProductID] += item.Quantity
+		if requested[item.ProductID] > line.ordered-line.shipped {
+			fields = append(fields, apperrors.FieldError{
+				Field:   fmt.Sprintf("items[%d].quantity", i),
+				Message: fmt.Sprintf("exceeds unshipped quantity %d", line.ordered-line.shipped),
+			})
+		}
+	}
+	if len(fields) > 0 {
+		_ = c.Error(apperrors.Validation("validation_failed", "Shipment validation failed", fields...))
+		return
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO shipments (order_id, carrier, tracking_number, status, shipped_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, orderID, shipment.Carrier, shipment.TrackingNumber, shipment.Status, shipment.ShippedAt)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Failed to create shipment", err))
+		return
+	}
+	shipmentID, err := result.LastInsertId()
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Failed to create shipment", err))
+		return
+	}
+	shipment.ID = int(shipmentID)
+
+	for _, item := range shipment.Items {
+		if _, err := tx.Exec(
+			"INSERT INTO shipment_items (shipment_id, order_item_id, quantity) VALUES (?, ?, ?)",
+			shipmentID, lines[item.ProductID].orderItemID, item.Quantity,
+		); err != nil {
+			_ = c.Error(apperrors.Internal("database_error", "Failed to add shipment item", err))
+			return
+		}
+	}
+
+	newStatus, err := syncOrderShipmentStatus(tx, orderID, status, adminActor(c), fmt.Sprintf("shipment %d created", shipment.ID))
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Failed to update order status", err))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		_ = c.Error(apperrors.Internal("transaction_failed", "Transaction commit failed", err))
+		return
+	}
+	markWrite(userID)
+
+	if rabbitMQ != nil {
+		event := models.OrderEvent{
+			OrderID:  orderID,
+			UserID:   userID,
+			Type:     "shipment_created",
+			Status:   newStatus,
+			Shipment: &shipment,
+		}
+		if err := rabbitMQ.PublishOrderEvent(event, 6); err != nil {
+			log.Printf("Failed to publish shipment created event: %v", err)
+		}
+	}
+	publishStatusChange(orderID, userID, status, newStatus)
+
+	c.JSON(http.StatusCreated, shipment)
+}
+
+// UpdateShipmentTracking 管理端更新发货单物流信息或标记签收
+func UpdateShipmentTracking(c *gin.Context) {
+	defer recordOperation(c, "update_shipment")
+	shipmentID, err := strconv.Atoi(c.Param("shipment_id"))
+	if err != nil {
+		_ = c.Error(apperrors.Validation("invalid_shipment_id", "Invalid shipment ID",
+			apperrors.FieldError{Field: "shipment_id", Message: "must be an integer"}))
+		return
+	}
+
+	var request struct {
+		Carrier        *string    `json:"carrier" binding:"omitempty,max=50"`
+		TrackingNumber *string    `json:"tracking_number" binding:"omitempty,max=100"`
+		DeliveredAt    *time.Time `json:"delivered_at"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(apperrors.FromBinding(err))
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		_ = c.Error(apperrors.Internal("transaction_failed", "Could not start transaction", err))
+		return
+	}
+	defer tx.Rollback()
+
+	var orderID, userID int
+	var orderStatus string
+	err = tx.QueryRow(`
+		SELECT o.id, o.user_id, o.status
+		FROM shipments s
+		JOIN orders o ON o.id = s.order_id
+		WHERE s.id = ?
+		FOR UPDATE
+	`, shipmentID).Scan(&orderID, &userID, &orderStatus)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = c.Error(apperrors.NotFound("shipment_not_found", "Shipment not found"))
+		return
+	}
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+
+	if request.Carrier != nil {
+		if _, err := tx.Exec("UPDATE shipments SET carrier = ? WHERE id = ?", strings.TrimSpace(*request.Carrier), shipmentID); err != nil {
+			_ = c.Error(apperrors.Internal("database_error", "Failed to update shipment", err))
+			return
+		}
+	}
+	if request.TrackingNumber != nil {
+		if _, err := tx.Exec("UPDATE shipments SET tracking_number = ? WHERE id = ?", strings.TrimSpace(*request.TrackingNumber), shipmentID); err != nil {
+			_ = c.Error(apperrors.Internal("database_error", "Failed to update shipment", err))
+			return
+		}
+	}
+	if request.DeliveredAt != nil {
+		if _, err := tx.Exec(
+			"UPDATE shipments SET status = ?, delivered_at = ? WHERE id = ?",
+			models.ShipmentStatusDelivered, *request.DeliveredAt, shipmentID,
+		); err != nil {
+			_ = c.Error(apperrors.Internal("database_error", "Failed to update shipment", err))
+			return
+		}
+	}
+
+	newStatus, err := syncOrderShipmentStatus(tx, orderID, orderStatus, adminActor(c), fmt.Sprintf("shipment %d updated", shipmentID))
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Failed to update order status", err))
+		return
+	}
+
+	shipments, err := loadShipments(tx, orderID)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		_ = c.Error(apperrors.Internal("transaction_failed", "Transaction commit failed", err))
+		return
+	}
+	markWrite(userID)
+
+	publishStatusChange(orderID, userID, orderStatus, newStatus)
+
+	for _, s := range shipments {
+		if s.ID == shipmentID {
+			c.JSON(http.StatusOK, s)
+			return
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListShipments 管理端查询订单的发货单
+func ListShipments(c *gin.Context) {
+	defer recordOperation(c, "list_shipments")
+	orderID, err := orderIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	shipments, err := loadShipments(database.DB, orderID)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+	c.JSON(http.StatusOK, shipments)
+}
+
+// shippableLine 订单商品行的已订购和已发货数量
+type shippableLine struct {
+	orderItemID int
+	ordered     int
+	shipped     int
+}
+
+// loadShippableLines 按 product_id 汇总订单商品行的订购和发货数量
+func loadShippableLines(tx *sql.Tx, orderID int) (map[int]*shippableLine, error) {
+	rows, err := tx.Query(`
+		SELECT oi.id, oi.product_id, oi.quantity, COALESCE(SUM(si.quantity), 0)
+		FROM order_items oi
+		LEFT JOIN shipment_items si ON si.order_item_id = oi.id
+		WHERE oi.order_id = ?
+		GROUP BY oi.id, oi.product_id, oi.quantity
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make(map[int]*shippableLine)
+	for rows.Next() {
+		var productID int
+		line := &shippableLine{}
+		if err := rows.Scan(&line.orderItemID, &productID, &line.ordered, &line.shipped); err != nil {
+			return nil, err
+		}
+		lines[productID] = line
+	}
+	return lines, rows.Err()
+}
+
+// syncOrderShipmentStatus 根据发货单推导订单状态：
+// 部分发货为 partially_shipped，全部发货为 shipped，全部发货且签收为 delivered。
+// 状态变化时以管理端来源写入状态历史
+func syncOrderShipmentStatus(tx *sql.Tx, orderID int, current, actor, reason string) (string, error) {
+	lines, err := loadShippableLines(tx, orderID)
+	if err != nil {
+		return "", err
+	}
+
+	var pendingDeliveries int
+	if err := tx.QueryRow(
+		"SELECT COUNT(*) FROM shipments WHERE order_id = ? AND status <> ?",
+		orderID, models.ShipmentStatusDelivered,
+	).Scan(&pendingDeliveries); err != nil {
+		return "", err
+	}
+
+	anyShipped, allShipped := false, true
+	for _, line := range lines {
+		if line.shipped > 0 {
+			anyShipped = true
+		}
+		if line.shipped < line.ordered {
+			allShipped = false
+		}
+	}
+
+	status := current
+	switch {
+	case allShipped && anyShipped && pendingDeliveries == 0:
+		status = models.OrderStatusDelivered
+	case allShipped && anyShipped:
+		status = models.OrderStatusShipped
+	case anyShipped:
+		status = models.OrderStatusPartiallyShipped
+	}
+
+	if status != current {
+		if _, err := tx.Exec("UPDATE orders SET status = ?, version = version + 1, updated_at = NOW() WHERE id = ?", status, orderID); err != nil {
+			return "", err
+		}
+		if err := history.Record(tx, models.OrderHistoryEntry{
+			OrderID:   orderID,
+			OldStatus: current,
+			NewStatus: status,
+			Actor:     actor,
+			Source:    history.SourceAdmin,
+			Reason:    reason,
+		}); err != nil {
+			return "", err
+		}
+	}
+	return status, nil
+}
+
+// querier 由 *sql.DB 和 *sql.Tx 实现
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadShipments 读取订单的所有发货单及其商品
+func loadShipments(q querier, orderID int) ([]models.Shipment, error) {
+	rows, err := q.Query(`
+		SELECT s.id, s.carrier, s.tracking_number, s.status, s.shipped_at, s.delivered_at,
+		       oi.product_id, si.quantity
+		FROM shipments s
+		JOIN shipment_items si ON si.shipment_id = s.id
+		JOIN order_items oi ON oi.id = si.order_item_id
+		WHERE s.order_id = ?
+		ORDER BY s.id, si.id
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shipments := []models.Shipment{}
+	for rows.Next() {
+		var s models.Shipment
+		var deliveredAt sql.NullTime
+		var item models.ShipmentItem
+		if err := rows.Scan(&s.ID, &s.Carrier, &s.TrackingNumber, &s.Status, &s.ShippedAt, &deliveredAt,
+			&item.ProductID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		if n := len(shipments); n == 0 || shipments[n-1].ID != s.ID {
+			s.OrderID = orderID
+			if deliveredAt.Valid {
+				s.DeliveredAt = &deliveredAt.Time
+			}
+			shipments = append(shipments, s)
+		}
+		last := &shipments[len(shipments)-1]
+		last.Items = append(last.Items, item)
+	}
+	return shipments, rows.Err()
+}
+
+// publishStatusChange 订单状态发生变化时发布 status_updated 事件
+func publishStatusChange(orderID, userID int, oldStatus, newStatus string) {
+	if rabbitMQ == nil || oldStatus == newStatus {
+		return
+	}
+	event := models.OrderEvent{
+		OrderID: orderID,
+		UserID:  userID,
+		Type:    "status_updated",
+		Status:  newStatus,
+	}
+	if err := rabbitMQ.PublishOrderEvent(event, 5); err != nil {
+		log.Printf("Failed to publish order updated event: %v", err)
+	}
+}
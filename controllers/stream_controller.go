@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"order-service/apperrors"
+	"order-service/database"
+	"order-service/history"
+	"order-service/middlewares"
+	"order-service/models"
+	"order-service/streaming"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	eventHub        *streaming.Hub
+	streamHeartbeat = 15 * time.Second
+)
+
+// SetEventHub 设置 SSE 连接使用的事件分发器和心跳间隔
+func SetEventHub(hub *streaming.Hub, heartbeat time.Duration) {
+	eventHub = hub
+	streamHeartbeat = heartbeat
+}
+
+// StreamOrderEvents 通过 SSE 推送单个订单的状态变更
+func StreamOrderEvents(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	orderID, err := orderIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var exists int
+	err = database.DB.QueryRow("SELECT 1 FROM orders WHERE id = ? AND user_id = ?", orderID, userID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = c.Error(apperrors.NotFound("order_not_found", "Order not found"))
+		return
+	}
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+
+	streamStatusHistory(c, userID, orderID)
+}
+
+// StreamUserOrders 通过 SSE 推送当前用户所有订单的状态变更
+func StreamUserOrders(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	streamStatusHistory(c, userID, 0)
+}
+
+// lastEventID 读取断线重连时的 Last-Event-ID，也可以通过 last_event_id 查询参数提供
+func lastEventID(c *gin.Context) (int, bool, error) {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 0 {
+		return 0, false, apperrors.Validation("invalid_last_event_id", "Invalid Last-Event-ID",
+			apperrors.FieldError{Field: "Last-Event-ID", Message: "must be a non-negative integer"})
+	}
+	return id, true, nil
+}
+
+// streamStatusHistory 通过 SSE 推送状态变更，状态历史 ID 作为事件 ID
+func streamStatusHistory(c *gin.Context, userID, orderID int) {
+	lastID, resume, err := lastEventID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if eventHub == nil {
+		_ = c.Error(apperrors.Unavailable("streaming_unavailable", "Event streaming is unavailable"))
+		return
+	}
+
+	middlewares.StreamOpened()
+	defer middlewares.StreamClosed()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, "retry: 3000\n\n") // 断线后 3 秒重连
+	c.Writer.Flush()
+
+	err = WatchStatusHistory(c.Request.Context(), userID, orderID, lastID, resume,
+		func(entry models.OrderHistoryEntry) error {
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: status\ndata: %s\n\n", entry.ID, data); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return nil
+		},
+		func() error {
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return nil
+		},
+	)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("Order event stream for user %d stopped: %v", userID, err)
+	}
+}
+
+// WatchStatusHistory 持续推送用户订单在 lastID 之后的状态历史，直到 ctx 结束。
+// resume 为 false 时只推送之后的变更。事件分发器的通知和心跳都会触发查询，
+// 因此未收到通知的变更（如消费者自动取消）也会在心跳时送达；没有新记录的心跳调用 heartbeat。
+// SSE 和 gRPC WatchOrder 共用
+func WatchStatusHistory(ctx context.Context, userID, orderID, lastID int, resume bool,
+	send func(models.OrderHistoryEntry) error, heartbeat func() error) error {
+	if eventHub == nil {
+		return apperrors.Unavailable("streaming_unavailable", "Event streaming is unavailable")
+	}
+	if !resume {
+		var err error
+		lastID, err = history.LatestID(database.DB, userID, orderID)
+		if err != nil {
+			return apperrors.Internal("database_error", "Database error", err)
+		}
+	}
+
+	sub := eventHub.Subscribe(userID, orderID)
+	defer eventHub.Unsubscribe(sub)
+
+	ticker := time.NewTicker(streamHeartbeat)
+	defer ticker.Stop()
+	for {
+		sent := 0
+		for {
+			entries, err := history.ListForUser(database.DB, userID, orderID, lastID, 100)
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				if err := send(entry); err != nil {
+					return err
+				}
+				lastID = entry.ID
+				sent++
+			}
+			if len(entries) < 100 {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sub.Wake:
+		case <-ticker.C:
+			if sent == 0 {
+				if err := heartbeat(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
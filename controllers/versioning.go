@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"encoding/json"
+	"order-service/apperrors"
+	v1 "order-service/dto/v1"
+	v2 "order-service/dto/v2"
+	"order-service/middlewares"
+	"order-service/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondVersioned 按请求的接口版本将领域对象转换为对应版本的响应结构，
+// 使用协商的媒体类型输出。版本之间结构相同的响应直接输出
+func respondVersioned(c *gin.Context, status int, value interface{}) {
+	version := middlewares.APIVersion(c)
+	var body interface{} = value
+	switch v := value.(type) {
+	case *models.OrderResponse:
+		if version == middlewares.APIVersion2 {
+			body = v2.NewOrder(v)
+		} else {
+			body = v1.NewOrder(v)
+		}
+	case []models.OrderResponse:
+		if version == middlewares.APIVersion2 {
+			body = v2.NewOrders(v)
+		} else {
+			body = v1.NewOrders(v)
+		}
+	case *models.BatchOrdersResult:
+		if version == middlewares.APIVersion2 {
+			body = v2.NewBatchOrders(v)
+		} else {
+			body = v1.NewBatchOrders(v)
+		}
+	case *models.OrderItemsResult:
+		if version == middlewares.APIVersion2 {
+			body = v2.NewItemsUpdated(v)
+		} else {
+			body = v1.NewItemsUpdated(v)
+		}
+	case *models.ReturnRequest:
+		if version == middlewares.APIVersion2 {
+			body = v2.NewReturn(v)
+		} else {
+			body = v1.NewReturn(v)
+		}
+	case []*models.ReturnRequest:
+		if version == middlewares.APIVersion2 {
+			body = v2.NewReturns(v)
+		} else {
+			body = v1.NewReturns(v)
+		}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("encoding_failed", "Failed to encode response", err))
+		return
+	}
+	c.Data(status, middlewares.APIMediaType(c)+"; charset=utf-8", data)
+}
+
+// bindCreateOrder 按接口版本解析下单请求
+func bindCreateOrder(c *gin.Context) (*models.Order, error) {
+	if middlewares.APIVersion(c) == middlewares.APIVersion2 {
+		var request v2.CreateOrderRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			return nil, apperrors.FromBinding(err)
+		}
+		return request.ToOrder(orderRules.DefaultCurrency)
+	}
+
+	var request v1.CreateOrderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		return nil, apperrors.FromBinding(err)
+	}
+	return request.ToOrder(), nil
+}
@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"order-service/apperrors"
+	"order-service/database"
+	"order-service/webhooks"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhookRequest 创建或修改订阅的请求
+type webhookRequest struct {
+	URL        string   `json:"url" binding:"required,max=2048"`
+	Secret     string   `json:"secret" binding:"omitempty,min=16,max=128"` // 不提供时自动生成
+	EventTypes []string `json:"event_types" binding:"omitempty,dive,max=50"`
+	Active     *bool    `json:"active"`
+}
+
+// webhookIDParam 解析路径中的订阅ID
+func webhookIDParam(c *gin.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("webhook_id"))
+	if err != nil {
+		return 0, apperrors.Validation("invalid_webhook_id", "Invalid webhook ID",
+			apperrors.FieldError{Field: "webhook_id", Message: "must be an integer"})
+	}
+	return id, nil
+}
+
+// deliveryIDParam 解析路径中的投递ID
+func deliveryIDParam(c *gin.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("delivery_id"))
+	if err != nil {
+		return 0, apperrors.Validation("invalid_delivery_id", "Invalid delivery ID",
+			apperrors.FieldError{Field: "delivery_id", Message: "must be an integer"})
+	}
+	return id, nil
+}
+
+// webhookStoreError 转换订阅存储错误
+func webhookStoreError(err error, notFoundCode, notFoundMessage string) error {
+	if errors.Is(err, webhooks.ErrNotFound) {
+		return apperrors.NotFound(notFoundCode, notFoundMessage)
+	}
+	return apperrors.Internal("database_error", "Database error", err)
+}
+
+// CreateWebhook 管理端创建 webhook 订阅，响应中包含签名密钥，之后不再返回
+func CreateWebhook(c *gin.Context) {
+	defer recordOperation(c, "create_webhook")
+
+	var request webhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(apperrors.FromBinding(err))
+		return
+	}
+	sub := webhooks.Subscription{
+		URL:        strings.TrimSpace(request.URL),
+		Secret:     request.Secret,
+		EventTypes: webhooks.NormalizeEventTypes(request.EventTypes),
+		Active:     request.Active == nil || *request.Active,
+	}
+	if fields := webhooks.ValidateURL(sub.URL); len(fields) > 0 {
+		_ = c.Error(apperrors.Validation("validation_failed", "Webhook validation failed", fields...))
+		return
+	}
+	if sub.Secret == "" {
+		secret, err := webhooks.GenerateSecret()
+		if err != nil {
+			_ = c.Error(apperrors.Internal("secret_generation_failed", "Failed to generate webhook secret", err))
+			return
+		}
+		sub.Secret = secret
+	}
+
+	if err := webhooks.CreateSubscription(database.DB, &sub); err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Failed to create webhook", err))
+		return
+	}
+	c.JSON(http.StatusCreated, sub)
+}
+
+// ListWebhooks 管理端查询 webhook 订阅
+func ListWebhooks(c *gin.Context) {
+	defer recordOperation(c, "list_webhooks")
+
+	subs, err := webhooks.ListSubscriptions(database.DB)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+// UpdateWebhook 管理端修改订阅，设置 active 为 true 可重新启用被停用的订阅。密钥不可修改
+func UpdateWebhook(c *gin.Context) {
+	defer recordOperation(c, "update_webhook")
+	id, err := webhookIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var request webhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		_ = c.Error(apperrors.FromBinding(err))
+		return
+	}
+	sub, err := webhooks.GetSubscription(database.DB, id)
+	if err != nil {
+		_ = c.Error(webhookStoreError(err, "webhook_not_found", "Webhook not found"))
+		return
+	}
+	sub.URL = strings.TrimSpace(request.URL)
+	sub.EventTypes = webhooks.NormalizeEventTypes(request.EventTypes)
+	if request.Active != nil {
+		sub.Active = *request.Active
+	}
+	if fields := webhooks.ValidateURL(sub.URL); len(fields) > 0 {
+		_ = c.Error(apperrors.Validation("validation_failed", "Webhook validation failed", fields...))
+		return
+	}
+
+	if err := webhooks.UpdateSubscription(database.DB, sub); err != nil {
+		_ = c.Error(webhookStoreError(err, "webhook_not_found", "Webhook not found"))
+		return
+	}
+	sub, err = webhooks.GetSubscription(database.DB, id)
+	if err != nil {
+		_ = c.Error(webhookStoreError(err, "webhook_not_found", "Webhook not found"))
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// DeleteWebhook 管理端删除订阅及其投递记录
+func DeleteWebhook(c *gin.Context) {
+	defer recordOperation(c, "delete_webhook")
+	id, err := webhookIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := webhooks.DeleteSubscription(database.DB, id); err != nil {
+		_ = c.Error(webhookStoreError(err, "webhook_not_found", "Webhook not found"))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries 管理端查询订阅的投递记录，默认最近 50 条
+func ListWebhookDeliveries(c *gin.Context) {
+	defer recordOperation(c, "list_webhook_deliveries")
+	id, err := webhookIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		_ = c.Error(apperrors.Validation("invalid_limit", "Invalid limit",
+			apperrors.FieldError{Field: "limit", Message: "must be between 1 and 500"}))
+		return
+	}
+
+	if _, err := webhooks.GetSubscription(database.DB, id); err != nil {
+		_ = c.Error(webhookStoreError(err, "webhook_not_found", "Webhook not found"))
+		return
+	}
+	deliveries, err := webhooks.ListDeliveries(database.DB, id, limit)
+	if err != nil {
+		_ = c.Error(apperrors.Internal("database_error", "Database error", err))
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetWebhookDelivery 管理端查询投递记录、消息体和每次尝试
+func GetWebhookDelivery(c *gin.Context) {
+	defer recordOperation(c, "get_webhook_delivery")
+	id, err := deliveryIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	delivery, err := webhooks.GetDelivery(database.DB, id)
+	if err != nil {
+		_ = c.Error(webhookStoreError(err, "delivery_not_found", "Webhook delivery not found"))
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// RedeliverWebhook 管理端重新投递，由后台投递循环立即发送
+func RedeliverWebhook(c *gin.Context) {
+	defer recordOperation(c, "redeliver_webhook")
+	id, err := deliveryIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := webhooks.Redeliver(database.DB, id); err != nil {
+		_ = c.Error(webhookStoreError(err, "delivery_not_found", "Webhook delivery not found"))
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Redelivery scheduled", "delivery_id": id})
+}
@@ -0,0 +1,147 @@
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minorUnits ISO 4217 中小数位数不是 2 的货币
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// MinorUnits 货币的小数位数，如 CNY 为 2、JPY 为 0，未知货币按 2 位
+func MinorUnits(code string) int {
+	if units, ok := minorUnits[strings.ToUpper(code)]; ok {
+		return units
+	}
+	return 2
+}
+
+// Rate 汇率快照：1 单位 From 货币等于 Value 单位 To 货币
+type Rate struct {
+	From  string
+	To    string
+	Value float64
+	AsOf  time.Time
+}
+
+// RateSource 汇率来源接口
+type RateSource interface {
+	Rate(ctx context.Context, from, to string) (Rate, error)
+}
+
+// ErrUnknownCurrency 汇率来源不支持该货币
+type ErrUnknownCurrency struct {
+	Code string
+}
+
+func (e *ErrUnknownCurrency) Error() string {
+	return fmt.Sprintf("no exchange rate for currency %q", e.Code)
+}
+
+// rateFile 汇率文件格式：rates 为 1 单位各货币折合基准货币的数量
+//
+//	{"base": "CNY", "as_of": "2026-10-01T00:00:00Z", "rates": {"USD": 7.12, "EUR": 7.75}}
+type rateFile struct {
+	Base  string             `json:"base"`
+	AsOf  time.Time          `json:"as_of"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// FileRateSource 从 JSON 文件读取汇率，文件修改后自动重新加载
+type FileRateSource struct {
+	path string
+
+	mu      sync.RWMutex
+	modTime time.Time
+	data    rateFile
+}
+
+// NewFileRateSource 创建文件汇率来源，并立即加载一次
+func NewFileRateSource(path string) (*FileRateSource, error) {
+	s := &FileRateSource{path: path}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileRateSource) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var data rateFile
+	if err := json.Unmarshal(content, &data); err != nil {
+		return err
+	}
+	data.Base = strings.ToUpper(data.Base)
+	normalized := make(map[string]float64, len(data.Rates))
+	for code, value := range data.Rates {
+		normalized[strings.ToUpper(code)] = value
+	}
+	normalized[data.Base] = 1
+	data.Rates = normalized
+
+	s.mu.Lock()
+	s.data = data
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *FileRateSource) Rate(_ context.Context, from, to string) (Rate, error) {
+	if err := s.reload(); err != nil {
+		return Rate{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	fromBase, ok := s.data.Rates[from]
+	if !ok || fromBase <= 0 {
+		return Rate{}, &ErrUnknownCurrency{Code: from}
+	}
+	toBase, ok := s.data.Rates[to]
+	if !ok || toBase <= 0 {
+		return Rate{}, &ErrUnknownCurrency{Code: to}
+	}
+	return Rate{From: from, To: to, Value: fromBase / toBase, AsOf: s.data.AsOf}, nil
+}
+
+// StaticRateSource 固定汇率来源，未配置汇率文件时只支持基准货币
+type StaticRateSource struct {
+	Base string
+}
+
+func (s StaticRateSource) Rate(_ context.Context, from, to string) (Rate, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from != s.Base {
+		return Rate{}, &ErrUnknownCurrency{Code: from}
+	}
+	if to != s.Base {
+		return Rate{}, &ErrUnknownCurrency{Code: to}
+	}
+	return Rate{From: from, To: to, Value: 1}, nil
+}
@@ -0,0 +1,117 @@
+package currency
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeRates(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileRateSourceRate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	writeRates(t, path, `{"base":"cny","as_of":"2026-10-01T00:00:00Z","rates":{"usd":7.2,"EUR":7.8,"XXX":0}}`, time.Unix(1000, 0))
+	source, err := NewFileRateSource(path)
+	if err != nil {
+		t.Fatalf("NewFileRateSource: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		from, to    string
+		want        float64
+		wantUnknown string
+	}{
+		{name: "to base", from: "USD", to: "CNY", want: 7.2},
+		{name: "from base", from: "cny", to: "usd", want: 1 / 7.2},
+		{name: "cross rate", from: "EUR", to: "USD", want: 7.8 / 7.2},
+		{name: "base to itself", from: "CNY", to: "CNY", want: 1},
+		{name: "unknown from", from: "JPY", to: "CNY", wantUnknown: "JPY"},
+		{name: "unknown to", from: "CNY", to: "GBP", wantUnknown: "GBP"},
+		{name: "non-positive rate", from: "XXX", to: "CNY", wantUnknown: "XXX"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, err := source.Rate(context.Background(), tt.from, tt.to)
+			if tt.wantUnknown != "" {
+				var unknown *ErrUnknownCurrency
+				if !errors.As(err, &unknown) || unknown.Code != tt.wantUnknown {
+					t.Fatalf("Rate() error = %v, want unknown currency %s", err, tt.wantUnknown)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rate: %v", err)
+			}
+			if math.Abs(rate.Value-tt.want) > 1e-9 {
+				t.Errorf("Rate() = %v, want %v", rate.Value, tt.want)
+			}
+			if !rate.AsOf.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("Rate().AsOf = %v", rate.AsOf)
+			}
+		})
+	}
+}
+
+func TestFileRateSourceReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	writeRates(t, path, `{"base":"CNY","rates":{"USD":7}}`, time.Unix(1000, 0))
+	source, err := NewFileRateSource(path)
+	if err != nil {
+		t.Fatalf("NewFileRateSource: %v", err)
+	}
+
+	writeRates(t, path, `{"base":"CNY","rates":{"USD":8}}`, time.Unix(2000, 0))
+	rate, err := source.Rate(context.Background(), "USD", "CNY")
+	if err != nil || rate.Value != 8 {
+		t.Errorf("Rate() after change = %v, %v, want 8", rate.Value, err)
+	}
+
+	writeRates(t, path, "{", time.Unix(3000, 0))
+	if _, err := source.Rate(context.Background(), "USD", "CNY"); err == nil {
+		t.Error("expected error for malformed rates file")
+	}
+}
+
+func TestStaticRateSourceRate(t *testing.T) {
+	source := StaticRateSource{Base: "CNY"}
+
+	rate, err := source.Rate(context.Background(), "cny", "CNY")
+	if err != nil || rate.Value != 1 {
+		t.Errorf("Rate(CNY, CNY) = %v, %v, want 1", rate.Value, err)
+	}
+	var unknown *ErrUnknownCurrency
+	if _, err := source.Rate(context.Background(), "USD", "CNY"); !errors.As(err, &unknown) || unknown.Code != "USD" {
+		t.Errorf("Rate(USD, CNY) error = %v, want unknown USD", err)
+	}
+	if _, err := source.Rate(context.Background(), "CNY", "EUR"); !errors.As(err, &unknown) || unknown.Code != "EUR" {
+		t.Errorf("Rate(CNY, EUR) error = %v, want unknown EUR", err)
+	}
+}
+
+func TestMinorUnits(t *testing.T) {
+	for code, want := range map[string]int{
+		"CNY": 2,
+		"usd": 2,
+		"JPY": 0,
+		"krw": 0,
+		"KWD": 3,
+		"":    2,
+	} {
+		if got := MinorUnits(code); got != want {
+			t.Errorf("MinorUnits(%q) = %d, want %d", code, got, want)
+		}
+	}
+}
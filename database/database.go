@@ -1,34 +1,150 @@
 package database
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
 	"fmt"
+	"log"
+	"net"
 	"order-service/config"
+	"os"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 var DB *sql.DB
 
-func InitDB() error {
-	cfg := config.LoadConfig()
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-		cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName)
+// 启动时连接主库失败后的重试间隔，每次翻倍
+const (
+	connectBackoff    = time.Second
+	maxConnectBackoff = 30 * time.Second
+)
 
-	db, err := sql.Open("mysql", dsn)
+// InitDB 按配置打开主库和从库。DBPrimaryDSN 为空时由 DB_HOST 等单项配置生成连接参数，
+// 密码不经过字符串拼接；主库连接失败时按指数退避重试 DBConnectAttempts 次
+func InitDB(cfg *config.Config) error {
+	tlsConfig, err := loadTLSConfig(cfg.DBTLSCAFile)
 	if err != nil {
 		return err
 	}
 
-	if err = db.Ping(); err != nil {
+	var primary *mysql.Config
+	if cfg.DBPrimaryDSN != "" {
+		if primary, err = parseDSN(cfg.DBPrimaryDSN); err != nil {
+			return err
+		}
+	} else {
+		primary = mysql.NewConfig()
+		primary.User = cfg.DBUser
+		primary.Passwd = cfg.DBPassword
+		primary.Net = "tcp"
+		primary.Addr = net.JoinHostPort(cfg.DBHost, cfg.DBPort)
+		primary.DBName = cfg.DBName
+	}
+
+	db, err := openDB(primary, cfg, tlsConfig)
+	if err != nil {
+		return err
+	}
+	if err := pingWithRetry(db, cfg.DBConnectAttempts); err != nil {
+		db.Close()
 		return err
 	}
 
 	DB = db
-	return nil
+	return openReplicas(cfg, tlsConfig)
+}
+
+// parseDSN 解析 DSN，错误信息不包含 DSN 本身
+func parseDSN(dsn string) (*mysql.Config, error) {
+	mc, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database DSN: %w", err)
+	}
+	return mc, nil
+}
+
+// openDB 补充 DSN 中未设置的超时和 TLS，打开连接池并设置连接数和连接寿命。
+// 时间列统一解析为 time.Time
+func openDB(mc *mysql.Config, cfg *config.Config, tlsConfig *tls.Config) (*sql.DB, error) {
+	mc.ParseTime = true
+	if mc.Timeout == 0 {
+		mc.Timeout = seconds(cfg.DBDialTimeoutSeconds)
+	}
+	if mc.ReadTimeout == 0 {
+		mc.ReadTimeout = seconds(cfg.DBReadTimeoutSeconds)
+	}
+	if mc.WriteTimeout == 0 {
+		mc.WriteTimeout = seconds(cfg.DBWriteTimeoutSeconds)
+	}
+	if mc.TLS == nil {
+		if tlsConfig != nil {
+			// 每个连接池使用独立副本，驱动会按各自的主机名填写 ServerName
+			mc.TLS = tlsConfig.Clone()
+		} else {
+			mc.TLSConfig = cfg.DBTLS
+		}
+	}
+
+	connector, err := mysql.NewConnector(mc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database configuration for %s: %w", mc.Addr, err)
+	}
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(seconds(cfg.DBMaxLifetimeSeconds))
+	db.SetConnMaxIdleTime(seconds(cfg.DBMaxIdleTimeSeconds))
+	return db, nil
+}
+
+// loadTLSConfig caFile 不为空时返回用该 CA 校验服务端证书的 TLS 配置
+func loadTLSConfig(caFile string) (*tls.Config, error) {
+	if caFile == "" {
+		return nil, nil
+	}
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// pingWithRetry 启动时数据库可能还未就绪，连接失败后按指数退避重试
+func pingWithRetry(db *sql.DB, attempts int) error {
+	backoff := connectBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.Ping()
+		if err == nil {
+			return nil
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("database unreachable after %d attempts: %w", attempt, err)
+		}
+		log.Printf("Database connection failed (attempt %d/%d), retrying in %s: %v", attempt, attempts, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
 }
 
 func CloseDB() {
+	closeReplicas()
 	if DB != nil {
 		err := DB.Close()
 		if err != nil {
@@ -0,0 +1,37 @@
+-- 订单收货/账单地址快照和用户地址簿
+
+ALTER TABLE orders
+    ADD COLUMN delivery_instructions VARCHAR(500) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS user_addresses (
+    id             INT AUTO_INCREMENT PRIMARY KEY,
+    user_id        INT          NOT NULL,
+    label          VARCHAR(50)  NOT NULL DEFAULT '',
+    recipient_name VARCHAR(200) NOT NULL,
+    phone          VARCHAR(20)  NOT NULL,
+    line1          VARCHAR(200) NOT NULL,
+    line2          VARCHAR(200) NOT NULL DEFAULT '',
+    city           VARCHAR(200) NOT NULL,
+    state          VARCHAR(200) NOT NULL DEFAULT '',
+    postal_code    VARCHAR(20)  NOT NULL DEFAULT '',
+    country        CHAR(2)      NOT NULL,
+    created_at     TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_user_addresses_user (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS order_addresses (
+    id                INT AUTO_INCREMENT PRIMARY KEY,
+    order_id          INT                          NOT NULL,
+    address_type      ENUM ('shipping', 'billing') NOT NULL,
+    source_address_id INT                          NULL,
+    recipient_name    VARCHAR(200)                 NOT NULL,
+    phone             VARCHAR(20)                  NOT NULL,
+    line1             VARCHAR(200)                 NOT NULL,
+    line2             VARCHAR(200)                 NOT NULL DEFAULT '',
+    city              VARCHAR(200)                 NOT NULL,
+    state             VARCHAR(200)                 NOT NULL DEFAULT '',
+    postal_code       VARCHAR(20)                  NOT NULL DEFAULT '',
+    country           CHAR(2)                      NOT NULL,
+    UNIQUE KEY uk_order_addresses (order_id, address_type),
+    FOREIGN KEY (order_id) REFERENCES orders (id)
+);
@@ -0,0 +1,23 @@
+-- 发货单和发货商品
+
+CREATE TABLE IF NOT EXISTS shipments (
+    id              INT AUTO_INCREMENT PRIMARY KEY,
+    order_id        INT                             NOT NULL,
+    carrier         VARCHAR(50)                     NOT NULL,
+    tracking_number VARCHAR(100)                    NOT NULL,
+    status          ENUM ('shipped', 'delivered')   NOT NULL DEFAULT 'shipped',
+    shipped_at      DATETIME                        NOT NULL,
+    delivered_at    DATETIME                        NULL,
+    INDEX idx_shipments_order (order_id),
+    FOREIGN KEY (order_id) REFERENCES orders (id)
+);
+
+CREATE TABLE IF NOT EXISTS shipment_items (
+    id            INT AUTO_INCREMENT PRIMARY KEY,
+    shipment_id   INT NOT NULL,
+    order_item_id INT NOT NULL,
+    quantity      INT NOT NULL,
+    INDEX idx_shipment_items_order_item (order_item_id),
+    FOREIGN KEY (shipment_id) REFERENCES shipments (id),
+    FOREIGN KEY (order_item_id) REFERENCES order_items (id)
+);
@@ -0,0 +1,28 @@
+-- 退货退款单
+
+CREATE TABLE IF NOT EXISTS return_requests (
+    id               INT AUTO_INCREMENT PRIMARY KEY,
+    order_id         INT            NOT NULL,
+    user_id          INT            NOT NULL,
+    status           VARCHAR(20)    NOT NULL,
+    items_amount     DECIMAL(12, 2) NOT NULL,
+    restocking_fee   DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    refund_amount    DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    refund_reference VARCHAR(100)   NOT NULL DEFAULT '',
+    review_note      VARCHAR(500)   NOT NULL DEFAULT '',
+    created_at       DATETIME       NOT NULL,
+    updated_at       DATETIME       NOT NULL,
+    INDEX idx_return_requests_order (order_id),
+    FOREIGN KEY (order_id) REFERENCES orders (id)
+);
+
+CREATE TABLE IF NOT EXISTS return_items (
+    id            INT AUTO_INCREMENT PRIMARY KEY,
+    return_id     INT          NOT NULL,
+    order_item_id INT          NOT NULL,
+    quantity      INT          NOT NULL,
+    reason        VARCHAR(500) NOT NULL,
+    INDEX idx_return_items_order_item (order_item_id),
+    FOREIGN KEY (return_id) REFERENCES return_requests (id),
+    FOREIGN KEY (order_item_id) REFERENCES order_items (id)
+);
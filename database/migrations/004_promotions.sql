@@ -0,0 +1,40 @@
+-- 优惠券、核销记录和订单优惠金额
+
+ALTER TABLE orders
+    ADD COLUMN subtotal    DECIMAL(12, 2) NOT NULL DEFAULT 0 AFTER user_id,
+    ADD COLUMN discount    DECIMAL(12, 2) NOT NULL DEFAULT 0 AFTER subtotal,
+    ADD COLUMN coupon_code VARCHAR(50)    NOT NULL DEFAULT '' AFTER discount;
+
+UPDATE orders SET subtotal = total WHERE subtotal = 0;
+
+ALTER TABLE order_items
+    ADD COLUMN discount DECIMAL(12, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS coupons (
+    id                INT AUTO_INCREMENT PRIMARY KEY,
+    code              VARCHAR(50)                                   NOT NULL UNIQUE,
+    type              ENUM ('percentage', 'fixed', 'buy_x_get_y') NOT NULL,
+    value             DECIMAL(12, 2)                                NOT NULL DEFAULT 0,
+    min_spend         DECIMAL(12, 2)                                NOT NULL DEFAULT 0,
+    buy_quantity      INT                                           NOT NULL DEFAULT 0,
+    get_quantity      INT                                           NOT NULL DEFAULT 0,
+    product_id        INT                                           NOT NULL DEFAULT 0,
+    max_uses_per_user INT                                           NOT NULL DEFAULT 0,
+    max_uses          INT                                           NOT NULL DEFAULT 0,
+    used_count        INT                                           NOT NULL DEFAULT 0,
+    starts_at         DATETIME                                      NULL,
+    ends_at           DATETIME                                      NULL,
+    active            BOOLEAN                                       NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS coupon_redemptions (
+    id          INT AUTO_INCREMENT PRIMARY KEY,
+    coupon_id   INT            NOT NULL,
+    user_id     INT            NOT NULL,
+    order_id    INT            NOT NULL,
+    discount    DECIMAL(12, 2) NOT NULL,
+    redeemed_at DATETIME       NOT NULL,
+    INDEX idx_coupon_redemptions_user (coupon_id, user_id),
+    FOREIGN KEY (coupon_id) REFERENCES coupons (id),
+    FOREIGN KEY (order_id) REFERENCES orders (id)
+);
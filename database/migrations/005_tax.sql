@@ -0,0 +1,23 @@
+-- 商品税类和订单税行
+
+ALTER TABLE orders
+    ADD COLUMN tax_total DECIMAL(12, 2) NOT NULL DEFAULT 0 AFTER coupon_code;
+
+CREATE TABLE IF NOT EXISTS product_tax_classes (
+    product_id INT PRIMARY KEY,
+    tax_class  VARCHAR(50) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS order_tax_lines (
+    id             INT AUTO_INCREMENT PRIMARY KEY,
+    order_id       INT            NOT NULL,
+    product_id     INT            NOT NULL,
+    tax_class      VARCHAR(50)    NOT NULL,
+    jurisdiction   VARCHAR(100)   NOT NULL DEFAULT '',
+    rate           DECIMAL(6, 4)  NOT NULL,
+    taxable_amount DECIMAL(12, 2) NOT NULL,
+    amount         DECIMAL(12, 2) NOT NULL,
+    inclusive      BOOLEAN        NOT NULL,
+    INDEX idx_order_tax_lines_order (order_id),
+    FOREIGN KEY (order_id) REFERENCES orders (id)
+);
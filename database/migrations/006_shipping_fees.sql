@@ -0,0 +1,10 @@
+-- 配送方式、运费和商品重量
+
+ALTER TABLE orders
+    ADD COLUMN delivery_option VARCHAR(20)    NOT NULL DEFAULT 'standard' AFTER tax_total,
+    ADD COLUMN shipping_fee    DECIMAL(12, 2) NOT NULL DEFAULT 0 AFTER delivery_option;
+
+CREATE TABLE IF NOT EXISTS product_weights (
+    product_id   INT PRIMARY KEY,
+    weight_grams INT NOT NULL
+);
@@ -0,0 +1,25 @@
+-- 多币种订单：订单货币、基准货币汇率快照和多币种商品目录价格
+
+ALTER TABLE orders
+    ADD COLUMN currency      CHAR(3)        NOT NULL DEFAULT 'CNY' AFTER total,
+    ADD COLUMN base_currency CHAR(3)        NOT NULL DEFAULT 'CNY' AFTER currency,
+    ADD COLUMN exchange_rate DECIMAL(18, 8) NOT NULL DEFAULT 1 AFTER base_currency,
+    ADD COLUMN total_base    DECIMAL(12, 2) NOT NULL DEFAULT 0 AFTER exchange_rate,
+    ADD INDEX idx_orders_user_currency (user_id, currency);
+
+UPDATE orders SET total_base = total WHERE total_base = 0;
+
+CREATE TABLE IF NOT EXISTS product_prices (
+    product_id INT            NOT NULL,
+    currency   CHAR(3)        NOT NULL,
+    price      DECIMAL(12, 2) NOT NULL,
+    PRIMARY KEY (product_id, currency)
+);
+
+-- 用每个商品最近一次下单的价格回填已有订单货币（基准货币）的目录价格，
+-- 避免上线后没有目录价格的商品无法下单。其他货币的价格需要在开放该货币前导入
+INSERT IGNORE INTO product_prices (product_id, currency, price)
+SELECT oi.product_id, o.currency, oi.price
+FROM order_items oi
+JOIN orders o ON o.id = oi.order_id
+JOIN (SELECT product_id, MAX(id) AS id FROM order_items GROUP BY product_id) latest ON latest.id = oi.id;
@@ -0,0 +1,27 @@
+-- 订单状态历史：每次状态变更在同一事务中追加一条记录，表只允许插入
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+    order_id   INT          NOT NULL,
+    old_status VARCHAR(32)  NOT NULL DEFAULT '', -- 新建订单时为空
+    new_status VARCHAR(32)  NOT NULL,
+    actor      VARCHAR(64)  NOT NULL,            -- user:<id>、admin 或 system
+    source     VARCHAR(16)  NOT NULL,            -- api、consumer 或 admin
+    reason     VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME     NOT NULL,
+    INDEX idx_order_status_history_order (order_id, id),
+    FOREIGN KEY (order_id) REFERENCES orders (id)
+);
+
+CREATE TRIGGER order_status_history_no_update
+    BEFORE UPDATE ON order_status_history
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'order_status_history is append-only';
+
+CREATE TRIGGER order_status_history_no_delete
+    BEFORE DELETE ON order_status_history
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'order_status_history is append-only';
+
+-- 为已有订单补一条初始记录
+INSERT INTO order_status_history (order_id, old_status, new_status, actor, source, reason, created_at)
+SELECT id, '', status, 'system', 'admin', 'backfill', updated_at
+FROM orders;
@@ -0,0 +1,4 @@
+-- 订单乐观锁版本号：每次修改订单行时加一，对外以 ETag 暴露
+
+ALTER TABLE orders
+    ADD COLUMN version INT NOT NULL DEFAULT 1 AFTER status;
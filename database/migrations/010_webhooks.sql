@@ -0,0 +1,42 @@
+-- webhook 订阅、投递记录和每次投递尝试的日志
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id                   INT AUTO_INCREMENT PRIMARY KEY,
+    url                  VARCHAR(2048) NOT NULL,
+    secret               VARCHAR(128)  NOT NULL,
+    event_types          VARCHAR(1024) NOT NULL DEFAULT '', -- 逗号分隔，为空表示所有事件
+    active               BOOLEAN       NOT NULL DEFAULT TRUE,
+    consecutive_failures INT           NOT NULL DEFAULT 0,
+    disabled_at          DATETIME      NULL,
+    created_at           DATETIME      NOT NULL,
+    updated_at           DATETIME      NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              BIGINT AUTO_INCREMENT PRIMARY KEY,
+    subscription_id INT                                     NOT NULL,
+    event_type      VARCHAR(50)                             NOT NULL,
+    order_id        INT                                     NOT NULL,
+    payload         MEDIUMTEXT                              NOT NULL,
+    status          ENUM ('pending', 'succeeded', 'failed') NOT NULL,
+    attempts        INT                                     NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME                                NULL,
+    last_error      VARCHAR(500)                            NOT NULL DEFAULT '',
+    created_at      DATETIME                                NOT NULL,
+    updated_at      DATETIME                                NOT NULL,
+    INDEX idx_webhook_deliveries_due (status, next_attempt_at),
+    INDEX idx_webhook_deliveries_subscription (subscription_id, id),
+    FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions (id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+    delivery_id  BIGINT       NOT NULL,
+    attempt      INT          NOT NULL,
+    status_code  INT          NOT NULL DEFAULT 0,
+    error        VARCHAR(500) NOT NULL DEFAULT '',
+    duration_ms  BIGINT       NOT NULL,
+    attempted_at DATETIME     NOT NULL,
+    INDEX idx_webhook_delivery_attempts_delivery (delivery_id, id),
+    FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries (id)
+);
@@ -0,0 +1,3 @@
+-- 订单列表按 (created_at, id) 键集分页，索引覆盖用户筛选和排序
+
+CREATE INDEX idx_orders_user_created ON orders (user_id, created_at, id);
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log"
+	"net"
+	"order-service/config"
+	"order-service/middlewares"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 读查询的路由原因，作为 order_service_db_read_routes_total 的 reason 标签
+const (
+	RouteReplica           = "replica"            // 健康的从库
+	RouteReadYourWrites    = "read_your_writes"   // 调用方要求读主库
+	RouteNoReplicas        = "no_replicas"        // 未配置从库
+	RouteReplicasUnhealthy = "replicas_unhealthy" // 所有从库都不健康
+	RouteFailover          = "failover"           // 从库连接失败后重试主库
+)
+
+// replica 一个只读从库及其健康状态
+type replica struct {
+	name    string // 用于日志和指标，不含密码
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+var (
+	replicas        []*replica
+	nextReplica     atomic.Uint64
+	stopHealthCheck chan struct{}
+)
+
+type primaryKey struct{}
+
+// WithPrimary 返回要求读主库的 context，用于写入后的读请求（read-your-writes）
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func primaryRequested(ctx context.Context) bool {
+	requested, _ := ctx.Value(primaryKey{}).(bool)
+	return requested
+}
+
+// Read 用只读连接执行 fn：优先轮询健康的从库，ctx 要求读主库、没有可用从库时使用主库。
+// 从库连接失败时将其标记为不健康并在主库上重试，fn 必须是只读且可重复执行的
+func Read(ctx context.Context, fn func(db *sql.DB) error) error {
+	if primaryRequested(ctx) {
+		middlewares.RecordReadRoute("primary", RouteReadYourWrites)
+		return fn(DB)
+	}
+	if len(replicas) == 0 {
+		middlewares.RecordReadRoute("primary", RouteNoReplicas)
+		return fn(DB)
+	}
+	r := pickReplica()
+	if r == nil {
+		middlewares.RecordReadRoute("primary", RouteReplicasUnhealthy)
+		return fn(DB)
+	}
+
+	middlewares.RecordReadRoute("replica", RouteReplica)
+	err := fn(r.db)
+	if err == nil || !isConnectionError(err) || ctx.Err() != nil {
+		return err
+	}
+	log.Printf("Replica %s failed, retrying on primary: %v", r.name, err)
+	r.setHealthy(false)
+	middlewares.RecordReadRoute("primary", RouteFailover)
+	return fn(DB)
+}
+
+// pickReplica 从当前位置开始轮询，返回第一个健康的从库
+func pickReplica() *replica {
+	start := nextReplica.Add(1)
+	for i := range replicas {
+		r := replicas[(start+uint64(i))%uint64(len(replicas))]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// isConnectionError 判断是否为连接层面的错误，SQL 错误不会触发切换
+func isConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func (r *replica) setHealthy(healthy bool) {
+	if r.healthy.Swap(healthy) != healthy {
+		if healthy {
+			log.Printf("Replica %s is healthy", r.name)
+		} else {
+			log.Printf("Replica %s marked unhealthy", r.name)
+		}
+	}
+	middlewares.SetReplicaHealthy(r.name, healthy)
+}
+
+// openReplicas 用与主库相同的连接池、超时和 TLS 配置打开从库，先做一次健康检查，
+// 然后按 DBReplicaCheckSeconds 定期检查。从库不可用不影响启动，读请求会落到主库
+func openReplicas(cfg *config.Config, tlsConfig *tls.Config) error {
+	for _, dsn := range cfg.DBReplicaDSNs {
+		mc, err := parseDSN(dsn)
+		if err != nil {
+			return err
+		}
+		db, err := openDB(mc, cfg, tlsConfig)
+		if err != nil {
+			return err
+		}
+		replicas = append(replicas, &replica{name: mc.Addr, db: db})
+	}
+	if len(replicas) == 0 {
+		return nil
+	}
+
+	interval := seconds(cfg.DBReplicaCheckSeconds)
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	checkReplicas(interval)
+	stopHealthCheck = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				checkReplicas(interval)
+			case <-stop:
+				return
+			}
+		}
+	}(stopHealthCheck)
+	return nil
+}
+
+// checkReplicas 并发 ping 所有从库，超时时间不超过检查间隔
+func checkReplicas(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan struct{}, len(replicas))
+	for _, r := range replicas {
+		go func(r *replica) {
+			r.setHealthy(r.db.PingContext(ctx) == nil)
+			done <- struct{}{}
+		}(r)
+	}
+	for range replicas {
+		<-done
+	}
+}
+
+func closeReplicas() {
+	if stopHealthCheck != nil {
+		close(stopHealthCheck)
+		stopHealthCheck = nil
+	}
+	for _, r := range replicas {
+		r.db.Close()
+	}
+	replicas = nil
+}
@@ -0,0 +1,246 @@
+// Package v1 v1 接口的请求和响应结构。v1 保持版本化之前 /api 的 JSON 结构，
+// 金额为数字；字段只能新增，不能修改或删除
+package v1
+
+import (
+	"order-service/models"
+	"time"
+)
+
+// CreateOrderRequest 下单请求
+type CreateOrderRequest struct {
+	Total                float64         `json:"total"` // 可选，提供时必须与商品行合计一致
+	Items                []OrderItem     `json:"items"`
+	ShippingAddress      *models.Address `json:"shipping_address,omitempty"`
+	ShippingAddressID    *int            `json:"shipping_address_id,omitempty"`
+	BillingAddress       *models.Address `json:"billing_address,omitempty"`
+	BillingAddressID     *int            `json:"billing_address_id,omitempty"`
+	DeliveryInstructions string          `json:"delivery_instructions,omitempty"`
+	Currency             string          `json:"currency,omitempty"`
+	CouponCode           string          `json:"coupon_code,omitempty"`
+	DeliveryOption       string          `json:"delivery_option,omitempty"`
+}
+
+// OrderItem 下单请求中的商品行
+type OrderItem struct {
+	ProductID   int     `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Quantity    int     `json:"quantity"`
+	Price       float64 `json:"price"`
+}
+
+// ToOrder 转换为领域订单
+func (r *CreateOrderRequest) ToOrder() *models.Order {
+	order := &models.Order{
+		Total:                r.Total,
+		Items:                make([]models.OrderItem, len(r.Items)),
+		ShippingAddress:      r.ShippingAddress,
+		ShippingAddressID:    r.ShippingAddressID,
+		BillingAddress:       r.BillingAddress,
+		BillingAddressID:     r.BillingAddressID,
+		DeliveryInstructions: r.DeliveryInstructions,
+		Currency:             r.Currency,
+		CouponCode:           r.CouponCode,
+		DeliveryOption:       r.DeliveryOption,
+	}
+	for i, item := range r.Items {
+		order.Items[i] = models.OrderItem{
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			Quantity:    item.Quantity,
+			Price:       item.Price,
+		}
+	}
+	return order
+}
+
+// Order 订单详情
+type Order struct {
+	ID             int         `json:"id"`
+	UserID         int         `json:"user_id"`
+	Subtotal       float64     `json:"subtotal"`
+	Discount       float64     `json:"discount"`
+	CouponCode     string      `json:"coupon_code,omitempty"`
+	TaxTotal       float64     `json:"tax_total"`
+	DeliveryOption string      `json:"delivery_option"`
+	ShippingFee    float64     `json:"shipping_fee"`
+	Total          float64     `json:"total"`
+	Currency       string      `json:"currency"`
+	BaseCurrency   string      `json:"base_currency"`
+	ExchangeRate   float64     `json:"exchange_rate"`
+	TotalBase      float64     `json:"total_base"`
+	Status         string      `json:"status"`
+	Version        int         `json:"version"`
+	CreatedAt      time.Time   `json:"created_at"`
+	Items          []OrderLine `json:"items"`
+
+	ShippingAddress      *models.Address `json:"shipping_address,omitempty"`
+	BillingAddress       *models.Address `json:"billing_address,omitempty"`
+	DeliveryInstructions string          `json:"delivery_instructions,omitempty"`
+
+	TaxLines []TaxLine `json:"tax_lines,omitempty"`
+}
+
+// OrderLine 订单商品行
+type OrderLine struct {
+	ProductID   int     `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Quantity    int     `json:"quantity"`
+	Price       float64 `json:"price"`
+	Subtotal    float64 `json:"subtotal"`
+	Discount    float64 `json:"discount"`
+}
+
+// TaxLine 商品行税额
+type TaxLine struct {
+	ProductID     int     `json:"product_id"`
+	TaxClass      string  `json:"tax_class"`
+	Jurisdiction  string  `json:"jurisdiction"`
+	Rate          float64 `json:"rate"`
+	TaxableAmount float64 `json:"taxable_amount"`
+	Amount        float64 `json:"amount"`
+	Inclusive     bool    `json:"inclusive"`
+}
+
+// NewOrder 由领域订单生成 v1 响应
+func NewOrder(o *models.OrderResponse) Order {
+	out := Order{
+		ID:                   o.ID,
+		UserID:               o.UserID,
+		Subtotal:             o.Subtotal,
+		Discount:             o.Discount,
+		CouponCode:           o.CouponCode,
+		TaxTotal:             o.TaxTotal,
+		DeliveryOption:       o.DeliveryOption,
+		ShippingFee:          o.ShippingFee,
+		Total:                o.Total,
+		Currency:             o.Currency,
+		BaseCurrency:         o.BaseCurrency,
+		ExchangeRate:         o.ExchangeRate,
+		TotalBase:            o.TotalBase,
+		Status:               o.Status,
+		Version:              o.Version,
+		CreatedAt:            o.CreatedAt,
+		Items:                make([]OrderLine, len(o.Items)),
+		ShippingAddress:      o.ShippingAddress,
+		BillingAddress:       o.BillingAddress,
+		DeliveryInstructions: o.DeliveryInstructions,
+	}
+	for i, item := range o.Items {
+		out.Items[i] = OrderLine{
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			Quantity:    item.Quantity,
+			Price:       item.Price,
+			Subtotal:    item.Subtotal,
+			Discount:    item.Discount,
+		}
+	}
+	for _, t := range o.TaxLines {
+		out.TaxLines = append(out.TaxLines, TaxLine(t))
+	}
+	return out
+}
+
+// NewOrders 由领域订单列表生成 v1 响应
+func NewOrders(orders []models.OrderResponse) []Order {
+	out := make([]Order, len(orders))
+	for i := range orders {
+		out[i] = NewOrder(&orders[i])
+	}
+	return out
+}
+
+// BatchOrders 批量查询订单的响应
+type BatchOrders struct {
+	Orders   []Order `json:"orders"`
+	NotFound []int   `json:"not_found"`
+}
+
+// NewBatchOrders 由批量查询结果生成 v1 响应
+func NewBatchOrders(r *models.BatchOrdersResult) BatchOrders {
+	return BatchOrders{Orders: NewOrders(r.Orders), NotFound: r.NotFound}
+}
+
+// ItemsUpdated 修改商品行的响应
+type ItemsUpdated struct {
+	OrderID     int                      `json:"order_id"`
+	Subtotal    float64                  `json:"subtotal"`
+	Discount    float64                  `json:"discount"`
+	TaxTotal    float64                  `json:"tax_total"`
+	ShippingFee float64                  `json:"shipping_fee"`
+	Total       float64                  `json:"total"`
+	Currency    string                   `json:"currency"`
+	Changes     []models.OrderItemChange `json:"changes"`
+}
+
+// NewItemsUpdated 由修改结果生成 v1 响应
+func NewItemsUpdated(r *models.OrderItemsResult) ItemsUpdated {
+	return ItemsUpdated{
+		OrderID:     r.OrderID,
+		Subtotal:    r.Subtotal,
+		Discount:    r.Discount,
+		TaxTotal:    r.TaxTotal,
+		ShippingFee: r.ShippingFee,
+		Total:       r.Total,
+		Currency:    r.Currency,
+		Changes:     r.Changes,
+	}
+}
+
+// ReturnRequest 退货单
+type ReturnRequest struct {
+	ID              int          `json:"id"`
+	OrderID         int          `json:"order_id"`
+	UserID          int          `json:"user_id"`
+	Status          string       `json:"status"`
+	Currency        string       `json:"currency"`
+	Items           []ReturnItem `json:"items"`
+	ItemsAmount     float64      `json:"items_amount"`
+	RestockingFee   float64      `json:"restocking_fee"`
+	RefundAmount    float64      `json:"refund_amount"`
+	RefundReference string       `json:"refund_reference,omitempty"`
+	ReviewNote      string       `json:"review_note,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// ReturnItem 退货商品行
+type ReturnItem struct {
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	Reason    string  `json:"reason"`
+	Price     float64 `json:"price"`
+}
+
+// NewReturn 由领域退货单生成 v1 响应
+func NewReturn(r *models.ReturnRequest) ReturnRequest {
+	out := ReturnRequest{
+		ID:              r.ID,
+		OrderID:         r.OrderID,
+		UserID:          r.UserID,
+		Status:          r.Status,
+		Currency:        r.Currency,
+		Items:           make([]ReturnItem, len(r.Items)),
+		ItemsAmount:     r.ItemsAmount,
+		RestockingFee:   r.RestockingFee,
+		RefundAmount:    r.RefundAmount,
+		RefundReference: r.RefundReference,
+		ReviewNote:      r.ReviewNote,
+		CreatedAt:       r.CreatedAt,
+		UpdatedAt:       r.UpdatedAt,
+	}
+	for i, item := range r.Items {
+		out.Items[i] = ReturnItem(item)
+	}
+	return out
+}
+
+// NewReturns 由领域退货单列表生成 v1 响应
+func NewReturns(returns []*models.ReturnRequest) []ReturnRequest {
+	out := make([]ReturnRequest, len(returns))
+	for i, r := range returns {
+		out[i] = NewReturn(r)
+	}
+	return out
+}
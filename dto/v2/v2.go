@@ -0,0 +1,309 @@
+// Package v2 v2 接口的请求和响应结构。与 v1 相比，金额以十进制字符串表示（如 "12.30"，小数位数与货币一致），
+// 避免客户端浮点误差；订单商品行带有 line_id
+package v2
+
+import (
+	"fmt"
+	"math"
+	"order-service/apperrors"
+	"order-service/currency"
+	"order-service/models"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Money 十进制金额字符串，小数位数与货币一致，如 "12.30" CNY、"1230" JPY
+type Money string
+
+var moneyPattern = regexp.MustCompile(`^\d{1,10}(\.(\d{1,3}))?$`)
+
+// NewMoney 按货币的小数位数格式化金额
+func NewMoney(amount float64, code string) Money {
+	units := currency.MinorUnits(code)
+	scale := math.Pow10(units)
+	return Money(strconv.FormatFloat(math.Round(amount*scale)/scale, 'f', units, 64))
+}
+
+// Parse 解析金额，只接受非负数且小数位数不超过货币的小数位数
+func (m Money) Parse(code string) (float64, bool) {
+	match := moneyPattern.FindStringSubmatch(string(m))
+	if match == nil || len(match[2]) > currency.MinorUnits(code) {
+		return 0, false
+	}
+	amount, err := strconv.ParseFloat(string(m), 64)
+	return amount, err == nil
+}
+
+// CreateOrderRequest 下单请求
+type CreateOrderRequest struct {
+	Total                Money           `json:"total,omitempty"` // 可选，提供时必须与计算的总额一致
+	Items                []OrderItem     `json:"items"`
+	ShippingAddress      *models.Address `json:"shipping_address,omitempty"`
+	ShippingAddressID    *int            `json:"shipping_address_id,omitempty"`
+	BillingAddress       *models.Address `json:"billing_address,omitempty"`
+	BillingAddressID     *int            `json:"billing_address_id,omitempty"`
+	DeliveryInstructions string          `json:"delivery_instructions,omitempty"`
+	Currency             string          `json:"currency,omitempty"`
+	CouponCode           string          `json:"coupon_code,omitempty"`
+	DeliveryOption       string          `json:"delivery_option,omitempty"`
+}
+
+// OrderItem 下单请求中的商品行，Price 可选，提供时必须与目录价格一致
+type OrderItem struct {
+	ProductID   int    `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Quantity    int    `json:"quantity"`
+	Price       Money  `json:"price,omitempty"`
+}
+
+// ToOrder 解析金额并转换为领域订单，未指定货币时按 defaultCurrency 的小数位数解析
+func (r *CreateOrderRequest) ToOrder(defaultCurrency string) (*models.Order, error) {
+	var fields []apperrors.FieldError
+	code := r.Currency
+	if code == "" {
+		code = defaultCurrency
+	}
+	parse := func(field string, m Money) float64 {
+		if m == "" {
+			return 0
+		}
+		amount, ok := m.Parse(code)
+		if !ok {
+			fields = append(fields, apperrors.FieldError{Field: field,
+				Message: fmt.Sprintf("must be a decimal string with at most %d decimals", currency.MinorUnits(code))})
+		}
+		return amount
+	}
+
+	order := &models.Order{
+		Total:                parse("total", r.Total),
+		Items:                make([]models.OrderItem, len(r.Items)),
+		ShippingAddress:      r.ShippingAddress,
+		ShippingAddressID:    r.ShippingAddressID,
+		BillingAddress:       r.BillingAddress,
+		BillingAddressID:     r.BillingAddressID,
+		DeliveryInstructions: r.DeliveryInstructions,
+		Currency:             r.Currency,
+		CouponCode:           r.CouponCode,
+		DeliveryOption:       r.DeliveryOption,
+	}
+	for i, item := range r.Items {
+		order.Items[i] = models.OrderItem{
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			Quantity:    item.Quantity,
+			Price:       parse(fmt.Sprintf("items[%d].price", i), item.Price),
+		}
+	}
+	if len(fields) > 0 {
+		return nil, apperrors.Validation("validation_failed", "Request validation failed", fields...)
+	}
+	return order, nil
+}
+
+// Order 订单详情
+type Order struct {
+	ID             int         `json:"id"`
+	UserID         int         `json:"user_id"`
+	Subtotal       Money       `json:"subtotal"`
+	Discount       Money       `json:"discount"`
+	CouponCode     string      `json:"coupon_code,omitempty"`
+	TaxTotal       Money       `json:"tax_total"`
+	DeliveryOption string      `json:"delivery_option"`
+	ShippingFee    Money       `json:"shipping_fee"`
+	Total          Money       `json:"total"`
+	Currency       string      `json:"currency"`
+	BaseCurrency   string      `json:"base_currency"`
+	ExchangeRate   float64     `json:"exchange_rate"` // 汇率不是金额，保持数字
+	TotalBase      Money       `json:"total_base"`
+	Status         string      `json:"status"`
+	Version        int         `json:"version"`
+	CreatedAt      time.Time   `json:"created_at"`
+	Items          []OrderLine `json:"items"`
+
+	ShippingAddress      *models.Address `json:"shipping_address,omitempty"`
+	BillingAddress       *models.Address `json:"billing_address,omitempty"`
+	DeliveryInstructions string          `json:"delivery_instructions,omitempty"`
+
+	TaxLines []TaxLine `json:"tax_lines,omitempty"`
+}
+
+// OrderLine 订单商品行，LineID 在订单内稳定，修改数量不会改变
+type OrderLine struct {
+	LineID      int    `json:"line_id"`
+	ProductID   int    `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Quantity    int    `json:"quantity"`
+	Price       Money  `json:"price"`
+	Subtotal    Money  `json:"subtotal"`
+	Discount    Money  `json:"discount"`
+}
+
+// TaxLine 商品行税额
+type TaxLine struct {
+	ProductID     int     `json:"product_id"`
+	TaxClass      string  `json:"tax_class"`
+	Jurisdiction  string  `json:"jurisdiction"`
+	Rate          float64 `json:"rate"`
+	TaxableAmount Money   `json:"taxable_amount"`
+	Amount        Money   `json:"amount"`
+	Inclusive     bool    `json:"inclusive"`
+}
+
+// NewOrder 由领域订单生成 v2 响应
+func NewOrder(o *models.OrderResponse) Order {
+	out := Order{
+		ID:                   o.ID,
+		UserID:               o.UserID,
+		Subtotal:             NewMoney(o.Subtotal, o.Currency),
+		Discount:             NewMoney(o.Discount, o.Currency),
+		CouponCode:           o.CouponCode,
+		TaxTotal:             NewMoney(o.TaxTotal, o.Currency),
+		DeliveryOption:       o.DeliveryOption,
+		ShippingFee:          NewMoney(o.ShippingFee, o.Currency),
+		Total:                NewMoney(o.Total, o.Currency),
+		Currency:             o.Currency,
+		BaseCurrency:         o.BaseCurrency,
+		ExchangeRate:         o.ExchangeRate,
+		TotalBase:            NewMoney(o.TotalBase, o.BaseCurrency),
+		Status:               o.Status,
+		Version:              o.Version,
+		CreatedAt:            o.CreatedAt,
+		Items:                make([]OrderLine, len(o.Items)),
+		ShippingAddress:      o.ShippingAddress,
+		BillingAddress:       o.BillingAddress,
+		DeliveryInstructions: o.DeliveryInstructions,
+	}
+	for i, item := range o.Items {
+		out.Items[i] = OrderLine{
+			LineID:      item.LineID,
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			Quantity:    item.Quantity,
+			Price:       NewMoney(item.Price, o.Currency),
+			Subtotal:    NewMoney(item.Subtotal, o.Currency),
+			Discount:    NewMoney(item.Discount, o.Currency),
+		}
+	}
+	for _, t := range o.TaxLines {
+		out.TaxLines = append(out.TaxLines, TaxLine{
+			ProductID:     t.ProductID,
+			TaxClass:      t.TaxClass,
+			Jurisdiction:  t.Jurisdiction,
+			Rate:          t.Rate,
+			TaxableAmount: NewMoney(t.TaxableAmount, o.Currency),
+			Amount:        NewMoney(t.Amount, o.Currency),
+			Inclusive:     t.Inclusive,
+		})
+	}
+	return out
+}
+
+// NewOrders 由领域订单列表生成 v2 响应
+func NewOrders(orders []models.OrderResponse) []Order {
+	out := make([]Order, len(orders))
+	for i := range orders {
+		out[i] = NewOrder(&orders[i])
+	}
+	return out
+}
+
+// BatchOrders 批量查询订单的响应
+type BatchOrders struct {
+	Orders   []Order `json:"orders"`
+	NotFound []int   `json:"not_found"`
+}
+
+// NewBatchOrders 由批量查询结果生成 v2 响应
+func NewBatchOrders(r *models.BatchOrdersResult) BatchOrders {
+	return BatchOrders{Orders: NewOrders(r.Orders), NotFound: r.NotFound}
+}
+
+// ItemsUpdated 修改商品行的响应
+type ItemsUpdated struct {
+	OrderID     int                      `json:"order_id"`
+	Subtotal    Money                    `json:"subtotal"`
+	Discount    Money                    `json:"discount"`
+	TaxTotal    Money                    `json:"tax_total"`
+	ShippingFee Money                    `json:"shipping_fee"`
+	Total       Money                    `json:"total"`
+	Currency    string                   `json:"currency"`
+	Changes     []models.OrderItemChange `json:"changes"`
+}
+
+// NewItemsUpdated 由修改结果生成 v2 响应
+func NewItemsUpdated(r *models.OrderItemsResult) ItemsUpdated {
+	return ItemsUpdated{
+		OrderID:     r.OrderID,
+		Subtotal:    NewMoney(r.Subtotal, r.Currency),
+		Discount:    NewMoney(r.Discount, r.Currency),
+		TaxTotal:    NewMoney(r.TaxTotal, r.Currency),
+		ShippingFee: NewMoney(r.ShippingFee, r.Currency),
+		Total:       NewMoney(r.Total, r.Currency),
+		Currency:    r.Currency,
+		Changes:     r.Changes,
+	}
+}
+
+// ReturnRequest 退货单
+type ReturnRequest struct {
+	ID              int          `json:"id"`
+	OrderID         int          `json:"order_id"`
+	UserID          int          `json:"user_id"`
+	Status          string       `json:"status"`
+	Currency        string       `json:"currency"`
+	Items           []ReturnItem `json:"items"`
+	ItemsAmount     Money        `json:"items_amount"`
+	RestockingFee   Money        `json:"restocking_fee"`
+	RefundAmount    Money        `json:"refund_amount"`
+	RefundReference string       `json:"refund_reference,omitempty"`
+	ReviewNote      string       `json:"review_note,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// ReturnItem 退货商品行
+type ReturnItem struct {
+	ProductID int    `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+	Reason    string `json:"reason"`
+	Price     Money  `json:"price"`
+}
+
+// NewReturn 由领域退货单生成 v2 响应
+func NewReturn(r *models.ReturnRequest) ReturnRequest {
+	out := ReturnRequest{
+		ID:              r.ID,
+		OrderID:         r.OrderID,
+		UserID:          r.UserID,
+		Status:          r.Status,
+		Currency:        r.Currency,
+		Items:           make([]ReturnItem, len(r.Items)),
+		ItemsAmount:     NewMoney(r.ItemsAmount, r.Currency),
+		RestockingFee:   NewMoney(r.RestockingFee, r.Currency),
+		RefundAmount:    NewMoney(r.RefundAmount, r.Currency),
+		RefundReference: r.RefundReference,
+		ReviewNote:      r.ReviewNote,
+		CreatedAt:       r.CreatedAt,
+		UpdatedAt:       r.UpdatedAt,
+	}
+	for i, item := range r.Items {
+		out.Items[i] = ReturnItem{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			Reason:    item.Reason,
+			Price:     NewMoney(item.Price, r.Currency),
+		}
+	}
+	return out
+}
+
+// NewReturns 由领域退货单列表生成 v2 响应
+func NewReturns(returns []*models.ReturnRequest) []ReturnRequest {
+	out := make([]ReturnRequest, len(returns))
+	for i, r := range returns {
+		out[i] = NewReturn(r)
+	}
+	return out
+}
@@ -0,0 +1,47 @@
+package v2
+
+import "testing"
+
+func TestNewMoney(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     Money
+	}{
+		{12.3, "CNY", "12.30"},
+		{0.005, "USD", "0.01"},
+		{1230, "JPY", "1230"},
+		{1229.6, "jpy", "1230"},
+		{1.2345, "KWD", "1.235"},
+		{5, "", "5.00"},
+	}
+	for _, tt := range tests {
+		if got := NewMoney(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("NewMoney(%v, %q) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyParse(t *testing.T) {
+	tests := []struct {
+		m        Money
+		currency string
+		want     float64
+		wantOK   bool
+	}{
+		{"12.30", "CNY", 12.3, true},
+		{"12", "CNY", 12, true},
+		{"12.345", "CNY", 0, false},
+		{"1230", "JPY", 1230, true},
+		{"1230.5", "JPY", 0, false},
+		{"1.235", "KWD", 1.235, true},
+		{"-1", "CNY", 0, false},
+		{"1e3", "CNY", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.m.Parse(tt.currency)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("Money(%q).Parse(%q) = %v, %v, want %v, %v", tt.m, tt.currency, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net"
+	"order-service/apperrors"
+	"order-service/middlewares"
+	"order-service/orderpb"
+	"order-service/utils"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+type userIDKey struct{}
+
+// userIDFromContext 获取认证拦截器设置的用户ID
+func userIDFromContext(ctx context.Context) (int, error) {
+	userID, ok := ctx.Value(userIDKey{}).(int)
+	if !ok {
+		return 0, status.Error(codes.Unauthenticated, "user not authenticated")
+	}
+	return userID, nil
+}
+
+// authenticate 校验 metadata 中的 authorization: Bearer <JWT>，与 REST 的 AuthMiddleware 使用同一令牌
+func authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || values[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata required")
+	}
+	userID, err := utils.ParseToken(strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil || userID == 0 {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return context.WithValue(ctx, userIDKey{}, userID), nil
+}
+
+// AuthUnaryInterceptor JWT 认证拦截器
+func AuthUnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authStream 替换流的 context 以携带用户ID
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
+
+// AuthStreamInterceptor 流式调用的 JWT 认证拦截器
+func AuthStreamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+}
+
+// methodRoutes gRPC 方法对应的 REST 限流路由名，两种入口共用限流规则和令牌桶
+var methodRoutes = map[string]string{
+	orderpb.OrderService_CreateOrder_FullMethodName:       "create_order",
+	orderpb.OrderService_GetUserOrders_FullMethodName:     "query_orders",
+	orderpb.OrderService_GetOrderDetails_FullMethodName:   "query_orders",
+	orderpb.OrderService_UpdateOrderStatus_FullMethodName: "update_status",
+	orderpb.OrderService_WatchOrder_FullMethodName:        "query_orders",
+}
+
+// RateLimiter 按路由名取限流规则，store 为空时不限流
+type RateLimiter struct {
+	Store middlewares.RateLimitStore
+	Rule  func(route string) middlewares.RateLimitRule
+}
+
+// take 按方法对应的规则取令牌，被拒绝时在响应头中返回 retry-after
+func (l RateLimiter) take(ctx context.Context, method string) error {
+	route, ok := methodRoutes[method]
+	if !ok || l.Store == nil {
+		return nil
+	}
+	userID, _ := ctx.Value(userIDKey{}).(int)
+	result, allowed := middlewares.TakeRateLimit(l.Store, l.Rule(route), userID, clientIP(ctx))
+	if allowed {
+		return nil
+	}
+	retryAfter := strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds())))
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", retryAfter))
+	return apperrors.TooManyRequests("rate_limited", "Too many requests")
+}
+
+// clientIP 取连接的对端地址
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// UnaryInterceptor 限流拦截器，需要放在认证拦截器之后才能按用户限流
+func (l RateLimiter) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := l.take(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor 流式调用的限流拦截器，只在建立流时取一次令牌
+func (l RateLimiter) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := l.take(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// RecoveryUnaryInterceptor 捕获处理过程中的 panic 并返回 Internal，避免一个请求导致整个进程退出。
+// 需要放在拦截器链的最外层
+func RecoveryUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(info.FullMethod, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// RecoveryStreamInterceptor 流式调用的 panic 恢复拦截器
+func RecoveryStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(info.FullMethod, r)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+func recovered(method string, r interface{}) error {
+	log.Printf("%s: panic: %v\n%s", method, r, debug.Stack())
+	return status.Error(codes.Internal, "internal error")
+}
+
+// MetricsUnaryInterceptor 记录请求数和耗时，按方法和状态码分类
+func MetricsUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	middlewares.ObserveGRPCRequest(info.FullMethod, status.Code(err).String(), time.Since(start))
+	return resp, err
+}
+
+// MetricsStreamInterceptor 记录流式调用数和持续时间
+func MetricsStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	middlewares.ObserveGRPCRequest(info.FullMethod, status.Code(err).String(), time.Since(start))
+	return err
+}
+
+// ErrorUnaryInterceptor 将领域错误转换为 gRPC 状态
+func ErrorUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	return resp, toStatus(info.FullMethod, err)
+}
+
+// ErrorStreamInterceptor 将流式调用的领域错误转换为 gRPC 状态
+func ErrorStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return toStatus(info.FullMethod, handler(srv, ss))
+}
+
+var kindCodes = map[apperrors.Kind]codes.Code{
+	apperrors.KindInternal:             codes.Internal,
+	apperrors.KindBadRequest:           codes.InvalidArgument,
+	apperrors.KindValidation:           codes.InvalidArgument,
+	apperrors.KindUnauthorized:         codes.Unauthenticated,
+	apperrors.KindForbidden:            codes.PermissionDenied,
+	apperrors.KindNotFound:             codes.NotFound,
+	apperrors.KindConflict:             codes.FailedPrecondition,
+	apperrors.KindTooManyRequests:      codes.ResourceExhausted,
+	apperrors.KindUnavailable:          codes.Unavailable,
+	apperrors.KindPreconditionFailed:   codes.Aborted,
+	apperrors.KindPreconditionRequired: codes.FailedPrecondition,
+	apperrors.KindNotAcceptable:        codes.InvalidArgument,
+}
+
+// toStatus 转换领域错误，字段错误放入 BadRequest 详情。内部原因只记录不返回
+func toStatus(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	appErr := apperrors.From(err)
+	code, ok := kindCodes[appErr.Kind]
+	if !ok {
+		code = codes.Internal
+	}
+	if code == codes.Internal {
+		log.Printf("%s: %v", method, appErr)
+	}
+
+	st := status.New(code, appErr.Message)
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: appErr.Code, Domain: "order-service"}}
+	if len(appErr.Fields) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, f := range appErr.Fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations,
+				&errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message})
+		}
+		details = append(details, badRequest)
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
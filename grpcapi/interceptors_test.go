@@ -0,0 +1,95 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"order-service/middlewares"
+	"order-service/orderpb"
+	"order-service/utils"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimiterUnaryInterceptor(t *testing.T) {
+	store := middlewares.NewMemoryRateLimitStore(time.Minute)
+	limiter := RateLimiter{
+		Store: store,
+		Rule: func(route string) middlewares.RateLimitRule {
+			return middlewares.RateLimitRule{Route: route, PerUser: middlewares.RateLimit{Rate: 0.001, Burst: 2}}
+		},
+	}
+	ctx := peer.NewContext(context.WithValue(context.Background(), userIDKey{}, 7),
+		&peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	call := func(method string) error {
+		info := &grpc.UnaryServerInfo{FullMethod: method}
+		_, err := ErrorUnaryInterceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return limiter.UnaryInterceptor(ctx, req, info, handler)
+		})
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := call(orderpb.OrderService_CreateOrder_FullMethodName); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if err := call(orderpb.OrderService_CreateOrder_FullMethodName); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("third call error = %v, want ResourceExhausted", err)
+	}
+	// 不同路由使用不同的桶
+	if err := call(orderpb.OrderService_GetOrderDetails_FullMethodName); err != nil {
+		t.Errorf("other route: %v", err)
+	}
+	// REST 中间件使用同一个桶
+	if result, allowed := middlewares.TakeRateLimit(store, limiter.Rule("create_order"), 7, "10.0.0.1"); allowed {
+		t.Errorf("REST bucket not shared with gRPC: %+v", result)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 443}})
+	if got := clientIP(ctx); got != "192.0.2.1" {
+		t.Errorf("clientIP() = %q, want 192.0.2.1", got)
+	}
+	if got := clientIP(context.Background()); got != "" {
+		t.Errorf("clientIP() without peer = %q, want empty", got)
+	}
+}
+
+func TestRecoveryUnaryInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: orderpb.OrderService_GetOrderDetails_FullMethodName}
+	_, err := RecoveryUnaryInterceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		panic("boom")
+	})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("error = %v, want Internal", err)
+	}
+}
+
+// 签名有效但没有数字 user_id 的令牌返回 Unauthenticated，不能让进程崩溃
+func TestAuthenticateRejectsTokenWithoutUserID(t *testing.T) {
+	utils.SetJWTSecret("test-secret")
+	for name, claims := range map[string]jwt.MapClaims{
+		"missing user_id": {"sub": "42"},
+		"string user_id":  {"user_id": "42"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+			if _, err := authenticate(ctx); status.Code(err) != codes.Unauthenticated {
+				t.Errorf("authenticate() error = %v, want Unauthenticated", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"order-service/apperrors"
+	"order-service/controllers"
+	"order-service/middlewares"
+	"order-service/models"
+	"order-service/orderpb"
+	"order-service/orderquery"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server 订单服务 gRPC 实现，业务逻辑与 REST 控制器共用
+type Server struct {
+	orderpb.UnimplementedOrderServiceServer
+}
+
+// NewServer 创建 gRPC 服务，依次应用 panic 恢复、指标、错误转换、JWT 认证和限流拦截器。
+// opts 用于传入 TLS 证书等服务端选项
+func NewServer(limiter RateLimiter, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(RecoveryUnaryInterceptor, MetricsUnaryInterceptor, ErrorUnaryInterceptor, AuthUnaryInterceptor, limiter.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(RecoveryStreamInterceptor, MetricsStreamInterceptor, ErrorStreamInterceptor, AuthStreamInterceptor, limiter.StreamInterceptor),
+	)
+	server := grpc.NewServer(opts...)
+	orderpb.RegisterOrderServiceServer(server, &Server{})
+	return server
+}
+
+func (s *Server) CreateOrder(ctx context.Context, req *orderpb.CreateOrderRequest) (resp *orderpb.CreateOrderResponse, err error) {
+	defer func() { middlewares.RecordOrderOperation("create", err == nil) }()
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	order := models.Order{
+		Total:                req.GetTotal(),
+		ShippingAddress:      addressFromProto(req.GetShippingAddress()),
+		BillingAddress:       addressFromProto(req.GetBillingAddress()),
+		DeliveryInstructions: req.GetDeliveryInstructions(),
+		Currency:             req.GetCurrency(),
+		CouponCode:           req.GetCouponCode(),
+		DeliveryOption:       req.GetDeliveryOption(),
+	}
+	if req.ShippingAddressId != nil {
+		id := int(req.GetShippingAddressId())
+		order.ShippingAddressID = &id
+	}
+	if req.BillingAddressId != nil {
+		id := int(req.GetBillingAddressId())
+		order.BillingAddressID = &id
+	}
+	for _, item := range req.GetItems() {
+		order.Items = append(order.Items, models.OrderItem{
+			ProductID:   int(item.GetProductId()),
+			ProductName: item.GetProductName(),
+			Quantity:    int(item.GetQuantity()),
+			Price:       item.GetPrice(),
+		})
+	}
+
+	// 与 /api 默认版本（v1）一致，收货地址可选
+	orderID, err := controllers.PlaceOrder(ctx, userID, &order, false)
+	if err != nil {
+		return nil, err
+	}
+	return &orderpb.CreateOrderResponse{OrderId: orderID}, nil
+}
+
+func (s *Server) GetUserOrders(ctx context.Context, req *orderpb.GetUserOrdersRequest) (resp *orderpb.GetUserOrdersResponse, err error) {
+	defer func() { middlewares.RecordOrderOperation("list", err == nil) }()
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetPageSize() < 0 || req.GetPageSize() > orderquery.MaxPageSize {
+		return nil, apperrors.Validation("invalid_page_size", "Invalid page size", apperrors.FieldError{
+			Field: "page_size", Message: fmt.Sprintf("must be between 0 and %d", orderquery.MaxPageSize)})
+	}
+	page, err := controllers.ListUserOrders(ctx, userID, orderquery.ListOptions{
+		Currency: req.GetCurrency(),
+		PageSize: int(req.GetPageSize()),
+		Cursor:   req.GetPageToken(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp = &orderpb.GetUserOrdersResponse{
+		Orders:        make([]*orderpb.Order, len(page.Orders)),
+		NextPageToken: page.NextCursor,
+	}
+	for i := range page.Orders {
+		resp.Orders[i] = orderToProto(&page.Orders[i])
+	}
+	return resp, nil
+}
+
+func (s *Server) GetOrderDetails(ctx context.Context, req *orderpb.GetOrderDetailsRequest) (resp *orderpb.Order, err error) {
+	defer func() { middlewares.RecordOrderOperation("details", err == nil) }()
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := controllers.LoadOrderDetails(ctx, userID, int(req.GetOrderId()))
+	if err != nil {
+		return nil, err
+	}
+	return orderToProto(order), nil
+}
+
+func (s *Server) UpdateOrderStatus(ctx context.Context, req *orderpb.UpdateOrderStatusRequest) (resp *orderpb.UpdateOrderStatusResponse, err error) {
+	defer func() { middlewares.RecordOrderOperation("update_status", err == nil) }()
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetExpectedVersion() <= 0 {
+		return nil, apperrors.PreconditionRequired("if_match_required", "expected_version with the current order version is required")
+	}
+
+	version, err := controllers.ChangeOrderStatus(ctx, userID, int(req.GetOrderId()), int(req.GetExpectedVersion()),
+		req.GetStatus(), req.GetReason())
+	if err != nil {
+		return nil, err
+	}
+	return &orderpb.UpdateOrderStatusResponse{OrderId: req.GetOrderId(), Version: int32(version)}, nil
+}
+
+func (s *Server) WatchOrder(req *orderpb.WatchOrderRequest, stream orderpb.OrderService_WatchOrderServer) error {
+	ctx := stream.Context()
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 校验订单归属
+	orderID := int(req.GetOrderId())
+	if _, err := controllers.LoadOrderDetails(ctx, userID, orderID); err != nil {
+		return err
+	}
+
+	middlewares.StreamOpened()
+	defer middlewares.StreamClosed()
+	return controllers.WatchStatusHistory(ctx, userID, orderID, int(req.GetLastEventId()), req.GetLastEventId() > 0,
+		func(entry models.OrderHistoryEntry) error {
+			return stream.Send(&orderpb.OrderStatusEvent{
+				Id:        int64(entry.ID),
+				OrderId:   int64(entry.OrderID),
+				OldStatus: entry.OldStatus,
+				NewStatus: entry.NewStatus,
+				Actor:     entry.Actor,
+				Source:    entry.Source,
+				Reason:    entry.Reason,
+				CreatedAt: timestamppb.New(entry.CreatedAt),
+			})
+		},
+		// HTTP/2 连接自带保活，gRPC 流不需要应用层心跳
+		func() error { return nil },
+	)
+}
+
+func addressFromProto(a *orderpb.Address) *models.Address {
+	if a == nil {
+		return nil
+	}
+	return &models.Address{
+		RecipientName: a.GetRecipientName(),
+		Phone:         a.GetPhone(),
+		Line1:         a.GetLine1(),
+		Line2:         a.GetLine2(),
+		City:          a.GetCity(),
+		State:         a.GetState(),
+		PostalCode:    a.GetPostalCode(),
+		Country:       a.GetCountry(),
+	}
+}
+
+func addressToProto(a *models.Address) *orderpb.Address {
+	if a == nil {
+		return nil
+	}
+	return &orderpb.Address{
+		RecipientName: a.RecipientName,
+		Phone:         a.Phone,
+		Line1:         a.Line1,
+		Line2:         a.Line2,
+		City:          a.City,
+		State:         a.State,
+		PostalCode:    a.PostalCode,
+		Country:       a.Country,
+	}
+}
+
+func orderToProto(o *models.OrderResponse) *orderpb.Order {
+	pb := &orderpb.Order{
+		Id:                   int64(o.ID),
+		UserId:               int64(o.UserID),
+		Subtotal:             o.Subtotal,
+		Discount:             o.Discount,
+		CouponCode:           o.CouponCode,
+		TaxTotal:             o.TaxTotal,
+		DeliveryOption:       o.DeliveryOption,
+		ShippingFee:          o.ShippingFee,
+		Total:                o.Total,
+		Currency:             o.Currency,
+		BaseCurrency:         o.BaseCurrency,
+		ExchangeRate:         o.ExchangeRate,
+		TotalBase:            o.TotalBase,
+		Status:               o.Status,
+		Version:              int32(o.Version),
+		CreatedAt:            timestamppb.New(o.CreatedAt),
+		ShippingAddress:      addressToProto(o.ShippingAddress),
+		BillingAddress:       addressToProto(o.BillingAddress),
+		DeliveryInstructions: o.DeliveryInstructions,
+	}
+	for _, item := range o.Items {
+		pb.Items = append(pb.Items, &orderpb.OrderItemDetail{
+			ProductId:   int32(item.ProductID),
+			ProductName: item.ProductName,
+			Quantity:    int32(item.Quantity),
+			Price:       item.Price,
+			Subtotal:    item.Subtotal,
+			Discount:    item.Discount,
+		})
+	}
+	for _, t := range o.TaxLines {
+		pb.TaxLines = append(pb.TaxLines, &orderpb.TaxLine{
+			ProductId:     int32(t.ProductID),
+			TaxClass:      t.TaxClass,
+			Jurisdiction:  t.Jurisdiction,
+			Rate:          t.Rate,
+			TaxableAmount: t.TaxableAmount,
+			Amount:        t.Amount,
+			Inclusive:     t.Inclusive,
+		})
+	}
+	return pb
+}
@@ -0,0 +1,125 @@
+package history
+
+import (
+	"database/sql"
+	"order-service/models"
+	"strconv"
+	"time"
+)
+
+// 状态变更来源
+const (
+	SourceAPI      = "api"
+	SourceConsumer = "consumer"
+	SourceAdmin    = "admin"
+)
+
+// SystemActor 消费者等后台任务的操作人
+const SystemActor = "system"
+
+// Execer 由 *sql.Tx 实现，历史记录必须与状态变更在同一事务中写入
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Querier 由 *sql.DB 和 *sql.Tx 实现
+type Querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// QueryRower 由 *sql.DB 和 *sql.Tx 实现
+type QueryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// UserActor 用户操作人标识
+func UserActor(userID int) string {
+	return "user:" + strconv.Itoa(userID)
+}
+
+// Record 追加一条状态变更记录，表只允许插入。新建订单时 OldStatus 为空
+func Record(tx Execer, entry models.OrderHistoryEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	_, err := tx.Exec(`
+		INSERT INTO order_status_history (order_id, old_status, new_status, actor, source, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, entry.OrderID, entry.OldStatus, entry.NewStatus, entry.Actor, entry.Source, entry.Reason, entry.CreatedAt)
+	return err
+}
+
+// List 按时间顺序读取订单状态历史
+func List(q Querier, orderID int) ([]models.OrderHistoryEntry, error) {
+	rows, err := q.Query(`
+		SELECT id, order_id, old_status, new_status, actor, source, reason, created_at
+		FROM order_status_history
+		WHERE order_id = ?
+		ORDER BY id
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.OrderHistoryEntry{}
+	for rows.Next() {
+		var e models.OrderHistoryEntry
+		if err := rows.Scan(&e.ID, &e.OrderID, &e.OldStatus, &e.NewStatus,
+			&e.Actor, &e.Source, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// ListForUser 读取用户订单中 ID 大于 afterID 的状态历史，orderID 为 0 时包含用户的所有订单
+func ListForUser(q Querier, userID, orderID, afterID, limit int) ([]models.OrderHistoryEntry, error) {
+	query := `
+		SELECT h.id, h.order_id, h.old_status, h.new_status, h.actor, h.source, h.reason, h.created_at
+		FROM order_status_history h
+		JOIN orders o ON o.id = h.order_id
+		WHERE o.user_id = ? AND h.id > ?`
+	args := []interface{}{userID, afterID}
+	if orderID != 0 {
+		query += " AND h.order_id = ?"
+		args = append(args, orderID)
+	}
+	query += " ORDER BY h.id LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.OrderHistoryEntry
+	for rows.Next() {
+		var e models.OrderHistoryEntry
+		if err := rows.Scan(&e.ID, &e.OrderID, &e.OldStatus, &e.NewStatus,
+			&e.Actor, &e.Source, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// LatestID 返回用户订单状态历史的最大 ID，orderID 为 0 时包含用户的所有订单
+func LatestID(q QueryRower, userID, orderID int) (int, error) {
+	query := `
+		SELECT COALESCE(MAX(h.id), 0)
+		FROM order_status_history h
+		JOIN orders o ON o.id = h.order_id
+		WHERE o.user_id = ?`
+	args := []interface{}{userID}
+	if orderID != 0 {
+		query += " AND h.order_id = ?"
+		args = append(args, orderID)
+	}
+	var id int
+	err := q.QueryRow(query, args...).Scan(&id)
+	return id, err
+}
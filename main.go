@@ -1,28 +1,68 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"order-service/apperrors"
 	"order-service/config"
 	"order-service/consumers"
 	"order-service/controllers"
+	"order-service/currency"
 	"order-service/database"
+	"order-service/grpcapi"
 	"order-service/middlewares"
+	"order-service/openapi"
 	"order-service/rabbitmq"
+	"order-service/shipping"
+	"order-service/streaming"
+	"order-service/tax"
+	"order-service/utils"
+	"order-service/validation"
+	"order-service/webhooks"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
+	// 加载配置：内置默认值 → 配置文件 → 环境变量 → 命令行参数
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.PrintConfig {
+		if err := cfg.Dump(os.Stdout); err != nil {
+			log.Fatalf("Failed to print config: %v", err)
+		}
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	utils.SetJWTSecret(cfg.JWTSecret)
+
 	// 初始化数据库
-	if err := database.InitDB(); err != nil {
+	if err := database.InitDB(cfg); err != nil {
 		log.Fatalf("Database initialization failed: %v", err)
 	}
 	defer database.CloseDB()
 
-	// 加载配置
-	cfg := config.LoadConfig()
+	// 注册数据库连接池指标
+	middlewares.RegisterDBStatsCollector(database.DB, cfg.DBName)
 
 	// 初始化RabbitMQ
 	rmq, err := rabbitmq.NewRabbitMQ(cfg)
@@ -39,40 +79,308 @@ func main() {
 	// 启动消息消费者
 	go consumers.StartOrderConsumer(rmq.Channel, cfg)
 
+	// 启动 webhook 投递
+	dispatcher := webhooks.NewDispatcher(database.DB, webhooks.Options{
+		Timeout:      time.Duration(cfg.WebhookTimeoutSeconds) * time.Second,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		BaseBackoff:  webhooks.DefaultOptions.BaseBackoff,
+		MaxBackoff:   webhooks.DefaultOptions.MaxBackoff,
+		DisableAfter: cfg.WebhookDisableAfter,
+		PollInterval: webhooks.DefaultOptions.PollInterval,
+	})
+	if err := dispatcher.Start(context.Background(), rmq.Conn, cfg.OrderExchange, cfg.WebhookQueue); err != nil {
+		log.Fatalf("Failed to start webhook dispatcher: %v", err)
+	}
+
+	// 订单事件流（SSE）：每个实例使用独占队列接收全部订单事件
+	hub := streaming.NewHub()
+	if err := hub.StartFanout(rmq.Conn, cfg.OrderExchange); err != nil {
+		log.Fatalf("Failed to start order event stream: %v", err)
+	}
+	controllers.SetEventHub(hub, time.Duration(cfg.StreamHeartbeatSeconds)*time.Second)
+
 	// 设置RabbitMQ实例到控制器
 	controllers.SetRabbitMQ(rmq)
+	controllers.SetOrderRules(validation.OrderRules{
+		MaxLines:             cfg.MaxOrderLines,
+		MaxLineQuantity:      cfg.MaxLineQuantity,
+		MaxProductNameLength: cfg.MaxProductNameLength,
+		MergeDuplicateLines:  cfg.MergeDuplicateLines,
+		DefaultCurrency:      cfg.BaseCurrency,
+		Currencies:           cfg.SupportedCurrencies,
+	})
+	controllers.SetRestockingFeePercent(cfg.RestockingFeePercent)
+	controllers.SetPaymentTimeout(time.Duration(cfg.PaymentTimeoutMinutes) * time.Minute)
+	controllers.SetReadYourWritesWindow(time.Duration(cfg.ReadYourWritesSeconds) * time.Second)
+	if cfg.TaxRulesFile != "" {
+		calc, err := tax.LoadTableCalculator(cfg.TaxRulesFile)
+		if err != nil {
+			log.Fatalf("Failed to load tax rules: %v", err)
+		}
+		controllers.SetTaxCalculator(calc)
+	}
+	if cfg.ShippingRulesFile != "" {
+		provider, err := shipping.LoadTableRateProvider(cfg.ShippingRulesFile)
+		if err != nil {
+			log.Fatalf("Failed to load shipping rules: %v", err)
+		}
+		controllers.SetShippingRateProvider(provider)
+	}
+	controllers.SetPickupLocation(tax.Destination{Country: cfg.PickupCountry, State: cfg.PickupState})
+	controllers.SetDefaultWeightGrams(cfg.DefaultWeightGrams)
+	if cfg.ExchangeRatesFile != "" {
+		source, err := currency.NewFileRateSource(cfg.ExchangeRatesFile)
+		if err != nil {
+			log.Fatalf("Failed to load exchange rates: %v", err)
+		}
+		controllers.SetRateSource(source, cfg.BaseCurrency)
+	} else {
+		controllers.SetRateSource(currency.StaticRateSource{Base: cfg.BaseCurrency}, cfg.BaseCurrency)
+	}
+
+	// 限流后端（进程内），多副本共享限流可替换为 RateLimitStore 的共享实现。
+	// REST 和 gRPC 共用同一组令牌桶
+	limiter := middlewares.NewMemoryRateLimitStore(10 * time.Minute)
+	rateLimit := newRateLimiter(cfg, limiter)
 
 	// 创建Gin路由
+	r, err := newRouter(cfg, rateLimit)
+	if err != nil {
+		log.Fatalf("Failed to configure router: %v", err)
+	}
+
+	// 管理端口：死信等内部端点
+	adminServer, err := newAdminServer(cfg, rateLimit("dead_letter"))
+	if err != nil {
+		log.Fatalf("Failed to configure admin server: %v", err)
+	}
+	go func() {
+		log.Printf("Admin server starting on port %s", adminServer.Addr)
+		var err error
+		if adminServer.TLSConfig != nil {
+			err = adminServer.ListenAndServeTLS(cfg.AdminTLSCertFile, cfg.AdminTLSKeyFile)
+		} else {
+			err = adminServer.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start admin server: %v", err)
+		}
+	}()
+
+	// gRPC 服务
+	if cfg.GRPCPort != "" {
+		listener, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+		if err != nil {
+			log.Fatalf("Failed to listen on gRPC port: %v", err)
+		}
+		var opts []grpc.ServerOption
+		if cfg.GRPCTLSCertFile != "" {
+			creds, err := credentials.NewServerTLSFromFile(cfg.GRPCTLSCertFile, cfg.GRPCTLSKeyFile)
+			if err != nil {
+				log.Fatalf("Failed to load gRPC TLS certificate: %v", err)
+			}
+			opts = append(opts, grpc.Creds(creds))
+		}
+		grpcServer := grpcapi.NewServer(grpcapi.RateLimiter{
+			Store: limiter,
+			Rule:  func(route string) middlewares.RateLimitRule { return rateLimitRule(cfg, route) },
+		}, opts...)
+		go func() {
+			log.Printf("gRPC server starting on port %s", listener.Addr())
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatalf("Failed to start gRPC server: %v", err)
+			}
+		}()
+		defer grpcServer.GracefulStop()
+	}
+
+	// 启动服务器
+	server := &http.Server{Addr: ":" + cfg.HTTPPort, Handler: r}
+	applyServerTimeouts(server, cfg)
+	log.Printf("Order services starting on port %s", server.Addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
+
+// applyServerTimeouts 设置 HTTP 服务的读、写和空闲超时，0 表示不限制
+func applyServerTimeouts(server *http.Server, cfg *config.Config) {
+	server.ReadTimeout = time.Duration(cfg.HTTPReadTimeoutSeconds) * time.Second
+	server.WriteTimeout = time.Duration(cfg.HTTPWriteTimeoutSeconds) * time.Second
+	server.IdleTimeout = time.Duration(cfg.HTTPIdleTimeoutSeconds) * time.Second
+}
+
+// newRateLimiter 创建按路由名取限流规则的中间件工厂，所有路由共享同一个限流后端
+func newRateLimiter(cfg *config.Config, limiter middlewares.RateLimitStore) func(route string) gin.HandlerFunc {
+	return func(route string) gin.HandlerFunc {
+		return middlewares.RateLimitMiddleware(limiter, rateLimitRule(cfg, route))
+	}
+}
+
+// rateLimitRule 按路由名取配置的限流规则
+func rateLimitRule(cfg *config.Config, route string) middlewares.RateLimitRule {
+	setting := cfg.RateLimits[route]
+	return middlewares.RateLimitRule{
+		Route:   route,
+		PerUser: middlewares.RateLimit{Rate: setting.UserRate, Burst: setting.UserBurst},
+		PerIP:   middlewares.RateLimit{Rate: setting.IPRate, Burst: setting.IPBurst},
+	}
+}
+
+// newRouter 创建对外端口的路由。路由变化时需要同步更新 openapi/openapi.json
+func newRouter(cfg *config.Config, rateLimit func(route string) gin.HandlerFunc) (*gin.Engine, error) {
 	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, err
+	}
 
-	// 应用Prometheus中间件
-	r.Use(middlewares.PrometheusMiddleware())
+	// 应用Prometheus中间件和统一错误响应中间件
+	r.Use(middlewares.PrometheusMiddleware(), middlewares.ErrorMiddleware())
 
-	// 暴露Prometheus指标端点
-	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	// 接口版本：/api/v1、/api/v2 按路径确定版本，/api 按 Accept 头协商，默认 v1
+	r.Use(middlewares.APIVersionMiddleware(map[int]middlewares.VersionLifecycle{
+		middlewares.APIVersion1: {DeprecatedAt: cfg.APIV1DeprecatedAt, SunsetAt: cfg.APIV1SunsetAt},
+	}))
+
+	// 非生产环境可按 OpenAPI 规范校验请求和响应
+	if cfg.OpenAPIValidation {
+		if cfg.Environment == "production" {
+			log.Printf("Warning: OPENAPI_VALIDATION is ignored in production")
+		} else {
+			validator, err := openapi.NewValidator()
+			if err != nil {
+				return nil, err
+			}
+			r.Use(middlewares.ContractValidationMiddleware(validator))
+		}
+	}
+
+	// 暴露Prometheus指标端点（可配置为只在管理端口暴露）
+	if !cfg.MetricsAdminOnly {
+		r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	}
 
 	// 健康检查端点
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
-	// 需要认证的路由组
-	authGroup := r.Group("/api")
-	authGroup.Use(middlewares.AuthMiddleware())
-	{
-		authGroup.POST("/orders", controllers.CreateOrder)
-		authGroup.GET("/orders", controllers.GetUserOrders)
-		authGroup.GET("/orders/:id", controllers.GetOrderDetails)
-		authGroup.PUT("/orders/:id/status", controllers.UpdateOrderStatus)
+	// 接口规范和文档页面
+	r.GET("/openapi.json", openapi.ServeSpec)
+	r.GET("/docs", openapi.ServeDocs)
+
+	// 需要认证的路由组，未带版本的 /api 保持现有客户端兼容
+	for _, prefix := range []string{"/api", "/api/v1", "/api/v2"} {
+		registerAPIRoutes(r.Group(prefix), rateLimit)
 	}
 
-	// 死信队列处理端点
-	r.POST("/dead-letter", controllers.HandleDeadLetter)
+	return r, nil
+}
 
-	// 启动服务器
-	port := ":8080"
-	log.Printf("Order services starting on port %s", port)
-	if err := r.Run(port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+// registerAPIRoutes 注册需要认证的用户接口，各版本共用处理器，由处理器按版本转换请求和响应
+func registerAPIRoutes(group *gin.RouterGroup, rateLimit func(route string) gin.HandlerFunc) {
+	group.Use(middlewares.AuthMiddleware())
+
+	group.POST("/orders", rateLimit("create_order"), controllers.CreateOrder)
+	group.POST("/orders:batchGet", customMethod("batchGet"), rateLimit("query_orders"), controllers.BatchGetOrders)
+	group.GET("/orders", rateLimit("query_orders"), controllers.GetUserOrders)
+	group.GET("/orders/stream", rateLimit("query_orders"), controllers.StreamUserOrders)
+	group.GET("/orders/:id", rateLimit("query_orders"), controllers.GetOrderDetails)
+	group.PUT("/orders/:id/status", rateLimit("update_status"), controllers.UpdateOrderStatus)
+	group.PATCH("/orders/:id/items", rateLimit("update_status"), controllers.UpdateOrderItems)
+	group.GET("/orders/:id/history", rateLimit("query_orders"), controllers.GetOrderHistory)
+	group.GET("/orders/:id/events", rateLimit("query_orders"), controllers.StreamOrderEvents)
+
+	group.POST("/orders/:id/returns", rateLimit("update_status"), controllers.RequestReturn)
+	group.GET("/orders/:id/returns", rateLimit("query_orders"), controllers.ListOrderReturns)
+
+	group.POST("/addresses", rateLimit("manage_addresses"), controllers.CreateAddress)
+	group.GET("/addresses", rateLimit("query_orders"), controllers.ListAddresses)
+}
+
+// customMethod 用于 /orders:batchGet 形式的自定义方法路由。gin 会把冒号之后的部分当作路径参数，
+// 参数值带有冒号，这里只放行方法名完全一致的请求，其他值返回 404
+func customMethod(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param(name) != ":"+name {
+			middlewares.AbortWithError(c, apperrors.NotFound("route_not_found", "Resource not found"))
+			return
+		}
+		c.Next()
 	}
 }
+
+// newAdminServer 创建管理端口服务。配置了客户端 CA 时使用 mTLS 认证，
+// 否则要求请求携带 HMAC 签名
+func newAdminServer(cfg *config.Config, deadLetterLimit gin.HandlerFunc) (*http.Server, error) {
+	admin := gin.New()
+	if err := admin.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, err
+	}
+	admin.Use(gin.Logger(), gin.Recovery(), middlewares.PrometheusMiddleware(), middlewares.ErrorMiddleware())
+
+	admin.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	admin.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	server := &http.Server{
+		Addr:    ":" + cfg.AdminPort,
+		Handler: admin,
+	}
+	applyServerTimeouts(server, cfg)
+
+	internal := admin.Group("/")
+	if cfg.AdminClientCAFile != "" {
+		caPEM, err := os.ReadFile(cfg.AdminClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.AdminClientCAFile)
+		}
+		server.TLSConfig = &tls.Config{
+			ClientCAs:  pool,
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			MinVersion: tls.VersionTLS12,
+		}
+	} else {
+		if cfg.AdminHMACSecret == "" {
+			log.Printf("Warning: ADMIN_HMAC_SECRET not set, internal admin endpoints will reject all requests")
+		}
+		internal.Use(middlewares.AdminHMACMiddleware(cfg.AdminHMACSecret, time.Duration(cfg.AdminSignatureMaxAgeSeconds)*time.Second))
+	}
+	{
+		// 死信队列处理端点
+		internal.POST("/dead-letter", deadLetterLimit, controllers.HandleDeadLetter)
+
+		// 批量修改订单状态
+		internal.POST("/api/orders:batchUpdateStatus", customMethod("batchUpdateStatus"), controllers.BatchUpdateOrderStatus)
+
+		// 发货管理
+		internal.GET("/admin/orders/:id/shipments", controllers.ListShipments)
+		internal.POST("/admin/orders/:id/shipments", controllers.CreateShipment)
+		internal.PUT("/admin/shipments/:shipment_id", controllers.UpdateShipmentTracking)
+
+		// 退货退款管理
+		internal.POST("/admin/returns/:return_id/review", controllers.ReviewReturn)
+		internal.POST("/admin/returns/:return_id/receive", controllers.ReceiveReturn)
+		internal.POST("/admin/returns/:return_id/refund", controllers.RetryRefund)
+
+		// 优惠券管理
+		internal.GET("/admin/coupons", controllers.ListCoupons)
+		internal.POST("/admin/coupons", controllers.CreateCoupon)
+
+		// webhook 订阅和投递记录
+		internal.GET("/admin/webhooks", controllers.ListWebhooks)
+		internal.POST("/admin/webhooks", controllers.CreateWebhook)
+		internal.PUT("/admin/webhooks/:webhook_id", controllers.UpdateWebhook)
+		internal.DELETE("/admin/webhooks/:webhook_id", controllers.DeleteWebhook)
+		internal.GET("/admin/webhooks/:webhook_id/deliveries", controllers.ListWebhookDeliveries)
+		internal.GET("/admin/webhook-deliveries/:delivery_id", controllers.GetWebhookDelivery)
+		internal.POST("/admin/webhook-deliveries/:delivery_id/redeliver", controllers.RedeliverWebhook)
+	}
+
+	return server, nil
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"order-service/config"
+	"order-service/controllers"
+	v1 "order-service/dto/v1"
+	v2 "order-service/dto/v2"
+	"order-service/history"
+	"order-service/middlewares"
+	"order-service/models"
+	"order-service/openapi"
+	"order-service/promotions"
+	"order-service/utils"
+	"order-service/webhooks"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testRouters(t *testing.T, configure ...func(*config.Config)) (*gin.Engine, *gin.Engine, *config.Config) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Environment = "test"
+	cfg.OpenAPIValidation = true
+	cfg.MetricsAdminOnly = false
+	cfg.AdminClientCAFile = ""
+	for _, f := range configure {
+		f(cfg)
+	}
+	utils.SetJWTSecret(cfg.JWTSecret)
+
+	rateLimit := newRateLimiter(cfg, middlewares.NewMemoryRateLimitStore(time.Minute))
+	router, err := newRouter(cfg, rateLimit)
+	if err != nil {
+		t.Fatalf("newRouter: %v", err)
+	}
+	adminServer, err := newAdminServer(cfg, rateLimit("dead_letter"))
+	if err != nil {
+		t.Fatalf("newAdminServer: %v", err)
+	}
+	return router, adminServer.Handler.(*gin.Engine), cfg
+}
+
+func newTestValidator(t *testing.T) *openapi.Validator {
+	t.Helper()
+	validator, err := openapi.NewValidator()
+	if err != nil {
+		t.Fatalf("compile openapi spec: %v", err)
+	}
+	return validator
+}
+
+// TestOpenAPICoversRoutes 规范中的操作必须与两个端口上注册的路由一一对应
+func TestOpenAPICoversRoutes(t *testing.T) {
+	router, admin, _ := testRouters(t)
+	validator := newTestValidator(t)
+
+	registered := make(map[string]bool)
+	for _, engine := range []*gin.Engine{router, admin} {
+		for _, route := range engine.Routes() {
+			registered[route.Method+" "+openapi.PathFromRoute(route.Path)] = true
+		}
+	}
+	documented := make(map[string]bool)
+	for _, op := range validator.Operations() {
+		documented[op] = true
+	}
+
+	var missing, stale []string
+	for op := range registered {
+		if !documented[op] {
+			missing = append(missing, op)
+		}
+	}
+	for op := range documented {
+		if !registered[op] {
+			stale = append(stale, op)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	if len(missing) > 0 {
+		t.Errorf("routes missing from openapi.json: %s", strings.Join(missing, ", "))
+	}
+	if len(stale) > 0 {
+		t.Errorf("operations in openapi.json without a route: %s", strings.Join(stale, ", "))
+	}
+}
+
+// enumValues 为规范中带枚举的字符串字段提供合法取值，键为 类型名.字段名
+var enumValues = map[string]string{
+	"Order.Status":                models.OrderStatusPending,
+	"Order.DeliveryOption":        "standard",
+	"OrderHistoryEntry.OldStatus": models.OrderStatusPending,
+	"OrderHistoryEntry.NewStatus": models.OrderStatusProcessing,
+	"OrderHistoryEntry.Source":    history.SourceAPI,
+	"ReturnRequest.Status":        models.ReturnStatusRequested,
+	"Shipment.Status":             models.ShipmentStatusShipped,
+	"Coupon.Type":                 promotions.TypePercentage,
+	"Delivery.Status":             webhooks.DeliveryPending,
+
+	"OrderStatusUpdateResult.Result":    models.BatchResultUpdated,
+	"OrderStatusUpdateResult.OldStatus": models.OrderStatusPending,
+	"OrderStatusUpdateResult.NewStatus": models.OrderStatusProcessing,
+}
+
+var moneyType = reflect.TypeOf(v2.Money(""))
+
+// fill 用非零值填满结构体的所有字段（包括 omitempty 字段），切片填入一个元素
+func fill(v reflect.Value, owner string, field string) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), owner, field)
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			v.Set(reflect.ValueOf(time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i), v.Type().Name(), v.Type().Field(i).Name)
+			}
+		}
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0), owner, field)
+	case reflect.String:
+		if value, ok := enumValues[owner+"."+field]; ok {
+			v.SetString(value)
+		} else if v.Type() == moneyType {
+			v.SetString("1.50")
+		} else {
+			v.SetString("CN")
+		}
+	case reflect.Int, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Float64:
+		v.SetFloat(1.5)
+	case reflect.Bool:
+		v.SetBool(true)
+	}
+}
+
+// minimal 只填入非 omitempty 的枚举字段和空切片，用于检查 omitempty 字段没有被规范标为必填
+func minimal(v reflect.Value, owner string, field string) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.IsExported() && !strings.Contains(f.Tag.Get("json"), ",omitempty") {
+				minimal(v.Field(i), v.Type().Name(), f.Name)
+			}
+		}
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	case reflect.String:
+		if value, ok := enumValues[owner+"."+field]; ok {
+			v.SetString(value)
+		} else if v.Type() == moneyType {
+			v.SetString("0.00")
+		}
+	}
+}
+
+// TestOpenAPIResponseShapes 处理器返回的结构体序列化后必须符合规范中对应操作的响应 schema。
+// 结构体增删字段、修改 JSON 名称或类型、修改 omitempty 而没有同步更新规范时测试失败
+func TestOpenAPIResponseShapes(t *testing.T) {
+	validator := newTestValidator(t)
+
+	type responseCase struct {
+		operation string
+		status    int
+		sample    any
+		list      bool
+		mediaType string
+	}
+	cases := []responseCase{
+		{"GET /api/orders/{id}/history", http.StatusOK, models.OrderHistoryEntry{}, true, ""},
+		{"GET /api/addresses", http.StatusOK, models.UserAddress{}, true, ""},
+		{"POST /api/addresses", http.StatusCreated, models.UserAddress{}, false, ""},
+		{"GET /admin/orders/{id}/shipments", http.StatusOK, models.Shipment{}, true, ""},
+		{"POST /admin/orders/{id}/shipments", http.StatusCreated, models.Shipment{}, false, ""},
+		{"PUT /admin/shipments/{shipment_id}", http.StatusOK, models.Shipment{}, false, ""},
+		{"POST /admin/returns/{return_id}/review", http.StatusOK, models.ReturnRequest{}, false, ""},
+		{"POST /admin/returns/{return_id}/receive", http.StatusOK, models.ReturnRequest{}, false, ""},
+		{"POST /admin/returns/{return_id}/refund", http.StatusOK, models.ReturnRequest{}, false, ""},
+		{"GET /admin/coupons", http.StatusOK, promotions.Coupon{}, true, ""},
+		{"POST /admin/coupons", http.StatusCreated, promotions.Coupon{}, false, ""},
+		{"GET /admin/webhooks", http.StatusOK, webhooks.Subscription{}, true, ""},
+		{"POST /admin/webhooks", http.StatusCreated, webhooks.Subscription{}, false, ""},
+		{"PUT /admin/webhooks/{webhook_id}", http.StatusOK, webhooks.Subscription{}, false, ""},
+		{"GET /admin/webhooks/{webhook_id}/deliveries", http.StatusOK, webhooks.Delivery{}, true, ""},
+		{"GET /admin/webhook-deliveries/{delivery_id}", http.StatusOK, webhooks.Delivery{}, false, ""},
+		{"POST /api/orders:batchUpdateStatus", http.StatusOK, models.BatchStatusUpdateResponse{}, false, ""},
+	}
+	// 按版本区分结构的响应：/api 下按协商的媒体类型，/api/v1、/api/v2 下按路径
+	versioned := []struct {
+		method, path string
+		status       int
+		v1, v2       any
+		list         bool
+	}{
+		{"GET", "/orders", http.StatusOK, v1.Order{}, v2.Order{}, true},
+		{"GET", "/orders/{id}", http.StatusOK, v1.Order{}, v2.Order{}, false},
+		{"POST", "/orders:batchGet", http.StatusOK, v1.BatchOrders{}, v2.BatchOrders{}, false},
+		{"PATCH", "/orders/{id}/items", http.StatusOK, v1.ItemsUpdated{}, v2.ItemsUpdated{}, false},
+		{"GET", "/orders/{id}/returns", http.StatusOK, v1.ReturnRequest{}, v2.ReturnRequest{}, true},
+		{"POST", "/orders/{id}/returns", http.StatusCreated, v1.ReturnRequest{}, v2.ReturnRequest{}, false},
+	}
+	for _, vc := range versioned {
+		cases = append(cases,
+			responseCase{vc.method + " /api" + vc.path, vc.status, vc.v1, vc.list, ""},
+			responseCase{vc.method + " /api" + vc.path, vc.status, vc.v1, vc.list, middlewares.VendorMediaType(middlewares.APIVersion1)},
+			responseCase{vc.method + " /api" + vc.path, vc.status, vc.v2, vc.list, middlewares.VendorMediaType(middlewares.APIVersion2)},
+			responseCase{vc.method + " /api/v1" + vc.path, vc.status, vc.v1, vc.list, ""},
+			responseCase{vc.method + " /api/v2" + vc.path, vc.status, vc.v2, vc.list, ""},
+		)
+	}
+
+	for _, tc := range cases {
+		method, path, _ := strings.Cut(tc.operation, " ")
+		mediaType := tc.mediaType
+		if mediaType == "" {
+			mediaType = "application/json"
+		}
+		for variant, populate := range map[string]func(reflect.Value, string, string){"full": fill, "minimal": minimal} {
+			value := reflect.New(reflect.TypeOf(tc.sample)).Elem()
+			populate(value, "", "")
+			var body any = value.Interface()
+			if tc.list {
+				list := reflect.MakeSlice(reflect.SliceOf(value.Type()), 0, 1)
+				body = reflect.Append(list, value).Interface()
+			}
+			data, err := json.Marshal(body)
+			if err != nil {
+				t.Fatalf("%s: marshal: %v", tc.operation, err)
+			}
+			if err := validator.ValidateResponse(method, path, tc.status, mediaType, data); err != nil {
+				t.Errorf("%s (%s %T, %s): response drifted from openapi.json: %v", tc.operation, variant, tc.sample, mediaType, err)
+			}
+		}
+	}
+}
+
+// TestOpenAPIHandlerResponses 不依赖数据库的处理器和错误路径，直接检查实际响应
+func TestOpenAPIHandlerResponses(t *testing.T) {
+	router, _, cfg := testRouters(t)
+	validator := newTestValidator(t)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 42}).SignedString([]byte(cfg.JWTSecret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	cases := []struct {
+		name      string
+		method    string
+		target    string
+		body      string
+		ifMatch   string
+		auth      bool
+		operation string
+		status    int
+	}{
+		{"health", http.MethodGet, "/health", "", "", false, "GET /health", http.StatusOK},
+		{"missing token", http.MethodGet, "/api/orders", "", "", false, "GET /api/orders", http.StatusUnauthorized},
+		{"invalid order id", http.MethodGet, "/api/orders/abc", "", "", true, "GET /api/orders/{id}", http.StatusBadRequest},
+		{"missing If-Match", http.MethodPut, "/api/orders/1/status", `{"status":"cancelled"}`, "", true,
+			"PUT /api/orders/{id}/status", http.StatusPreconditionRequired},
+		{"malformed If-Match", http.MethodPatch, "/api/orders/1/items", `{"items":[{"product_id":1,"quantity":2}]}`, `"v1"`, true,
+			"PATCH /api/orders/{id}/items", http.StatusPreconditionFailed},
+		{"invalid page size", http.MethodGet, "/api/orders?page_size=0", "", "", true, "GET /api/orders", http.StatusBadRequest},
+		{"invalid page token", http.MethodGet, "/api/orders?page_token=%21", "", "", true, "GET /api/orders", http.StatusBadRequest},
+		{"empty batch", http.MethodPost, "/api/orders:batchGet", `{"ids":[]}`, "", true,
+			"POST /api/orders:batchGet", http.StatusBadRequest},
+		{"unknown custom method", http.MethodPost, "/api/orders:batchDelete", `{"ids":[1]}`, "", true,
+			"POST /api/orders:batchGet", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			if tc.auth {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.status, w.Body.String())
+			}
+			method, path, _ := strings.Cut(tc.operation, " ")
+			if err := validator.ValidateResponse(method, path, w.Code, w.Header().Get("Content-Type"), w.Body.Bytes()); err != nil {
+				t.Errorf("response drifted from openapi.json: %v\n%s", err, w.Body.String())
+			}
+		})
+	}
+
+	// 死信端点在管理端口需要签名，这里直接调用处理器
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/dead-letter", strings.NewReader(`{"order_id":1,"reason":"test"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	controllers.HandleDeadLetter(c)
+	if err := validator.ValidateResponse(http.MethodPost, "/dead-letter", w.Code, w.Header().Get("Content-Type"), w.Body.Bytes()); err != nil {
+		t.Errorf("dead letter response drifted from openapi.json: %v", err)
+	}
+}
+
+// TestOpenAPIRequestValidation 开启校验时不符合规范的请求返回字段错误
+func TestOpenAPIRequestValidation(t *testing.T) {
+	router, _, _ := testRouters(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(`{"items":[{"product_id":0,"quantity":"2"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var problem struct {
+		Code   string `json:"code"`
+		Errors []struct {
+			Field string `json:"field"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if w.Code != http.StatusBadRequest || problem.Code != "contract_violation" {
+		t.Fatalf("got %d %s, want 400 contract_violation", w.Code, problem.Code)
+	}
+	fields := make(map[string]bool)
+	for _, e := range problem.Errors {
+		fields[e.Field] = true
+	}
+	for _, want := range []string{"items[0].product_id", "items[0].quantity"} {
+		if !fields[want] {
+			t.Errorf("missing field error for %s in %+v", want, problem.Errors)
+		}
+	}
+}
+
+// TestAPIVersionNegotiation 版本由路径或 Accept 头确定，已弃用的版本返回 Deprecation、Sunset 和 Link 头
+func TestAPIVersionNegotiation(t *testing.T) {
+	deprecatedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunsetAt := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	router, _, cfg := testRouters(t, func(cfg *config.Config) {
+		cfg.APIV1DeprecatedAt = deprecatedAt
+		cfg.APIV1SunsetAt = sunsetAt
+	})
+	validator := newTestValidator(t)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 42}).SignedString([]byte(cfg.JWTSecret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	cases := []struct {
+		name       string
+		target     string
+		accept     string
+		operation  string
+		status     int
+		version    string
+		deprecated bool
+	}{
+		{"default", "/api/orders/abc", "", "GET /api/orders/{id}", http.StatusBadRequest, "v1", true},
+		{"accept v2", "/api/orders/abc", "application/vnd.order-service.v2+json", "GET /api/orders/{id}", http.StatusBadRequest, "v2", false},
+		{"accept list", "/api/orders/abc", "application/vnd.order-service.v9+json, application/vnd.order-service.v2+json;q=0.9",
+			"GET /api/orders/{id}", http.StatusBadRequest, "v2", false},
+		{"path v1", "/api/v1/orders/abc", "", "GET /api/v1/orders/{id}", http.StatusBadRequest, "v1", true},
+		{"path wins over accept", "/api/v2/orders/abc", "application/vnd.order-service.v1+json",
+			"GET /api/v2/orders/{id}", http.StatusBadRequest, "v2", false},
+		{"unsupported version", "/api/orders/abc", "application/vnd.order-service.v3+json", "GET /api/orders/{id}", http.StatusNotAcceptable, "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.status, w.Body.String())
+			}
+			if got := w.Header().Get("API-Version"); got != tc.version {
+				t.Errorf("API-Version = %q, want %q", got, tc.version)
+			}
+			if tc.deprecated {
+				if got, want := w.Header().Get("Deprecation"), "@1767225600"; got != want {
+					t.Errorf("Deprecation = %q, want %q", got, want)
+				}
+				if got, want := w.Header().Get("Sunset"), "Thu, 31 Dec 2026 00:00:00 GMT"; got != want {
+					t.Errorf("Sunset = %q, want %q", got, want)
+				}
+				if got, want := w.Header().Get("Link"), `</api/v2/orders/abc>; rel="successor-version"`; got != want {
+					t.Errorf("Link = %q, want %q", got, want)
+				}
+			} else if w.Header().Get("Deprecation") != "" || w.Header().Get("Sunset") != "" {
+				t.Errorf("unexpected deprecation headers on %s", tc.version)
+			}
+			method, path, _ := strings.Cut(tc.operation, " ")
+			if err := validator.ValidateResponse(method, path, w.Code, w.Header().Get("Content-Type"), w.Body.Bytes()); err != nil {
+				t.Errorf("response drifted from openapi.json: %v\n%s", err, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestRateLimitIgnoresSpoofedForwardedFor 未配置可信代理时，伪造的 X-Forwarded-For 不能换到新的按 IP 限流桶
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	_, admin, _ := testRouters(t, func(cfg *config.Config) {
+		cfg.AdminHMACSecret = "test-secret"
+		cfg.RateLimits["dead_letter"] = config.RateLimitSetting{IPRate: 0.001, IPBurst: 2}
+	})
+
+	for i := 0; i < 3; i++ {
+		body := fmt.Sprintf(`{"order_id":1,"reason":"spoof %d"}`, i)
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req := httptest.NewRequest(http.MethodPost, "/dead-letter", strings.NewReader(body))
+		req.RemoteAddr = "203.0.113.7:40000"
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i+1))
+		req.Header.Set(middlewares.SignatureTimestampHeader, timestamp)
+		req.Header.Set(middlewares.SignatureHeader,
+			middlewares.SignAdminRequest("test-secret", timestamp, http.MethodPost, "/dead-letter", []byte(body)))
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, req)
+
+		if want := i < 2; (w.Code != http.StatusTooManyRequests) != want {
+			t.Fatalf("request %d: status = %d: %s", i, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package middlewares
+
+import (
+	"net/http"
+	"order-service/apperrors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 接口版本
+const (
+	APIVersion1      = 1
+	APIVersion2      = 2
+	LatestAPIVersion = APIVersion2
+)
+
+const (
+	apiVersionKey   = "apiVersion"
+	apiMediaTypeKey = "apiMediaType"
+
+	// VendorMediaTypePrefix 通过 Accept 头协商版本的媒体类型前缀，完整格式为 application/vnd.order-service.v2+json
+	VendorMediaTypePrefix = "application/vnd.order-service.v"
+	vendorMediaTypeSuffix = "+json"
+)
+
+// VersionLifecycle 接口版本的弃用和下线时间，零值表示未设置
+type VersionLifecycle struct {
+	DeprecatedAt time.Time
+	SunsetAt     time.Time
+}
+
+// VendorMediaType 返回版本对应的媒体类型
+func VendorMediaType(version int) string {
+	return VendorMediaTypePrefix + strconv.Itoa(version) + vendorMediaTypeSuffix
+}
+
+// APIVersionMiddleware 确定 /api 下请求使用的接口版本：/api/v1、/api/v2 按路径确定，
+// 未带版本的 /api 按 Accept 头协商（application/vnd.order-service.v2+json），未指定时为 v1。
+// 协商得到的版本同时决定请求体结构，响应使用相同的媒体类型。
+// 已弃用的版本在响应中返回 Deprecation、Sunset 头和指向最新版本的 Link 头
+func APIVersionMiddleware(lifecycles map[int]VersionLifecycle) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if path != "/api" && !strings.HasPrefix(path, "/api/") {
+			c.Next()
+			return
+		}
+
+		version, rest, fromPath := pathVersion(path)
+		mediaType := "application/json"
+		if !fromPath {
+			c.Header("Vary", "Accept")
+			negotiated, ok := negotiateVersion(c.GetHeader("Accept"))
+			if !ok {
+				AbortWithError(c, apperrors.NotAcceptable("unsupported_api_version",
+					"Supported versions are "+VendorMediaType(APIVersion1)+" and "+VendorMediaType(APIVersion2)))
+				return
+			}
+			if negotiated != 0 {
+				version = negotiated
+				mediaType = VendorMediaType(version)
+			}
+		}
+		c.Set(apiVersionKey, version)
+		c.Set(apiMediaTypeKey, mediaType)
+		c.Header("API-Version", "v"+strconv.Itoa(version))
+
+		if lifecycle, ok := lifecycles[version]; ok {
+			if !lifecycle.DeprecatedAt.IsZero() {
+				// RFC 9745：@ 加 Unix 时间戳
+				c.Header("Deprecation", "@"+strconv.FormatInt(lifecycle.DeprecatedAt.Unix(), 10))
+				c.Header("Link", `</api/v`+strconv.Itoa(LatestAPIVersion)+rest+`>; rel="successor-version"`)
+			}
+			if !lifecycle.SunsetAt.IsZero() {
+				c.Header("Sunset", lifecycle.SunsetAt.UTC().Format(http.TimeFormat))
+			}
+		}
+		c.Next()
+	}
+}
+
+// pathVersion 解析 /api/v<N> 前缀，返回版本、去掉前缀后的路径以及是否由路径指定
+func pathVersion(path string) (int, string, bool) {
+	rest := strings.TrimPrefix(path, "/api")
+	for _, version := range []int{APIVersion1, APIVersion2} {
+		prefix := "/v" + strconv.Itoa(version)
+		if rest == prefix || strings.HasPrefix(rest, prefix+"/") {
+			return version, strings.TrimPrefix(rest, prefix), true
+		}
+	}
+	return APIVersion1, rest, false
+}
+
+// negotiateVersion 从 Accept 头中找出第一个支持的厂商媒体类型版本，没有时返回 0 使用默认版本。
+// 只接受不支持的厂商媒体类型时返回 false
+func negotiateVersion(accept string) (int, bool) {
+	unsupported, other := false, false
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if !strings.HasPrefix(mediaType, VendorMediaTypePrefix) || !strings.HasSuffix(mediaType, vendorMediaTypeSuffix) {
+			other = true
+			continue
+		}
+		version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(mediaType, VendorMediaTypePrefix), vendorMediaTypeSuffix))
+		if err == nil && (version == APIVersion1 || version == APIVersion2) {
+			return version, true
+		}
+		unsupported = true
+	}
+	return 0, other || !unsupported
+}
+
+// APIVersion 返回 APIVersionMiddleware 确定的接口版本，未经过该中间件的请求为 v1
+func APIVersion(c *gin.Context) int {
+	if version, ok := c.Get(apiVersionKey); ok {
+		return version.(int)
+	}
+	return APIVersion1
+}
+
+// APIMediaType 返回请求协商的媒体类型，响应体使用该类型
+func APIMediaType(c *gin.Context) string {
+	if mediaType, ok := c.Get(apiMediaTypeKey); ok {
+		return mediaType.(string)
+	}
+	return "application/json"
+}
@@ -1,7 +1,7 @@
 package middlewares
 
 import (
-	"net/http"
+	"order-service/apperrors"
 	"order-service/utils"
 	"strings"
 
@@ -12,14 +12,14 @@ func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			AbortWithError(c, apperrors.Unauthorized("missing_token", "Authorization header required"))
 			return
 		}
 
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
 		userID, err := utils.ParseToken(tokenString)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			AbortWithError(c, apperrors.Unauthorized("invalid_token", "Invalid token"))
 			return
 		}
 
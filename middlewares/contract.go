@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"order-service/apperrors"
+	"order-service/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseRecorder 在写出响应的同时保存 JSON 响应体。是否保存在第一次写入时按 Content-Type 决定，
+// SSE 等流式响应不会被缓存
+type responseRecorder struct {
+	gin.ResponseWriter
+	decided bool
+	capture bool
+	body    bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseRecorder) record(data []byte) {
+	if !w.decided {
+		w.decided = true
+		w.capture = openapi.IsJSONMediaType(w.Header().Get("Content-Type"))
+	}
+	if w.capture {
+		w.body.Write(data)
+	}
+}
+
+// ContractValidationMiddleware 按 OpenAPI 规范校验请求体和响应体，只应在非生产环境启用。
+// 请求不符合规范时返回 400；处理器的响应不符合规范时只记录日志，用于在测试环境发现接口漂移。
+// 需要注册在 ErrorMiddleware 之后，统一错误响应不经过校验
+func ContractValidationMiddleware(validator *openapi.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+		method := c.Request.Method
+		path := openapi.PathFromRoute(route)
+
+		// 请求体结构由协商的接口版本决定，按协商的媒体类型选择 schema
+		if c.Request.Body != nil && openapi.IsJSONMediaType(c.GetHeader("Content-Type")) {
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				AbortWithError(c, apperrors.BadRequest("malformed_body", "Failed to read request body").Wrap(err))
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			if err := validator.ValidateRequest(method, path, APIMediaType(c), body); err != nil {
+				AbortWithError(c, apperrors.Validation("contract_violation",
+					"Request does not match the API specification", openapi.FieldErrors(err)...))
+				return
+			}
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		c.Writer = recorder.ResponseWriter
+
+		if !recorder.capture {
+			return
+		}
+		contentType := recorder.Header().Get("Content-Type")
+		if err := validator.ValidateResponse(method, path, recorder.Status(), contentType, recorder.body.Bytes()); err != nil {
+			log.Printf("Contract violation: %s %s responded %d: %v", method, route, recorder.Status(), err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"order-service/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType RFC 7807 错误响应类型
+const ProblemContentType = "application/problem+json"
+
+// Problem RFC 7807 错误响应体
+type Problem struct {
+	Type     string                 `json:"type"`
+	Title    string                 `json:"title"`
+	Status   int                    `json:"status"`
+	Detail   string                 `json:"detail,omitempty"`
+	Instance string                 `json:"instance,omitempty"`
+	Code     string                 `json:"code"`
+	Errors   []apperrors.FieldError `json:"errors,omitempty"`
+}
+
+// ErrorMiddleware 统一渲染处理器通过 c.Error 返回的错误，
+// 处理器出错时只需调用 c.Error 并返回，不要自己写响应
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 {
+			return
+		}
+
+		appErr := apperrors.From(c.Errors.Last().Err)
+		if appErr.Kind == apperrors.KindInternal {
+			log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, appErr)
+		}
+		if c.Writer.Written() {
+			return
+		}
+
+		status := appErr.Status()
+		body, err := json.Marshal(Problem{
+			Type:     "urn:order-service:problem:" + appErr.Code,
+			Title:    http.StatusText(status),
+			Status:   status,
+			Detail:   appErr.Message,
+			Instance: c.Request.URL.Path,
+			Code:     appErr.Code,
+			Errors:   appErr.Fields,
+		})
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Data(status, ProblemContentType, body)
+	}
+}
+
+// AbortWithError 记录错误并中止后续处理器，由 ErrorMiddleware 渲染响应
+func AbortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
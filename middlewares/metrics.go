@@ -0,0 +1,196 @@
+package middlewares
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	eventsPublished = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_events_published_total",
+			Help: "Total number of order events published to RabbitMQ",
+		},
+		[]string{"event_type", "priority", "status"},
+	)
+
+	eventProcessingDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "order_service_event_processing_duration_seconds",
+			Help:    "Duration of order event processing in the consumer",
+			Buckets: []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+		},
+		[]string{"event_type", "outcome"},
+	)
+
+	eventRedeliveries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_event_redeliveries_total",
+			Help: "Total number of redelivered order events",
+		},
+		[]string{"event_type"},
+	)
+
+	deadLetters = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_dead_letters_total",
+			Help: "Total number of dead letters received",
+		},
+		[]string{"source"},
+	)
+
+	autoCancellations = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "order_service_auto_cancellations_total",
+			Help: "Total number of orders auto-cancelled due to non-payment",
+		},
+	)
+
+	orderValue = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "order_service_order_value",
+			Help:    "Total value of created orders in the base currency",
+			Buckets: []float64{10, 50, 100, 250, 500, 1000, 2500, 5000, 10000},
+		},
+	)
+
+	orderItems = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "order_service_order_items",
+			Help:    "Number of items per created order",
+			Buckets: []float64{1, 2, 3, 5, 10, 20, 50, 100},
+		},
+	)
+
+	webhookDeliveries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_webhook_deliveries_total",
+			Help: "Total number of webhook delivery attempts",
+		},
+		[]string{"event_type", "outcome"},
+	)
+
+	grpcRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_grpc_requests_total",
+			Help: "Total number of gRPC requests",
+		},
+		[]string{"method", "code"},
+	)
+
+	grpcRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "order_service_grpc_request_duration_seconds",
+			Help:    "Duration of gRPC requests, including the lifetime of streams",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method"},
+	)
+
+	streamConnections = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "order_service_stream_connections",
+			Help: "Number of open order event stream (SSE) connections",
+		},
+	)
+
+	dbReadRoutes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_service_db_read_routes_total",
+			Help: "Total number of read queries by target database and routing reason",
+		},
+		[]string{"target", "reason"},
+	)
+
+	dbReplicaHealthy = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "order_service_db_replica_healthy",
+			Help: "Whether a read replica passed its last health check (1) or not (0)",
+		},
+		[]string{"replica"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(collectors.NewBuildInfoCollector())
+}
+
+// RecordEventPublished 记录消息发布指标
+func RecordEventPublished(eventType string, priority int, success bool) {
+	status := "success"
+	if !success {
+		status = "error"
+	}
+	eventsPublished.WithLabelValues(eventType, strconv.Itoa(priority), status).Inc()
+}
+
+// ObserveEventProcessing 记录消费者处理耗时和结果
+func ObserveEventProcessing(eventType, outcome string, duration time.Duration) {
+	eventProcessingDuration.WithLabelValues(eventType, outcome).Observe(duration.Seconds())
+}
+
+// RecordEventRedelivery 记录重投递消息
+func RecordEventRedelivery(eventType string) {
+	eventRedeliveries.WithLabelValues(eventType).Inc()
+}
+
+// RecordDeadLetter 记录死信，source 为 queue 或 http
+func RecordDeadLetter(source string) {
+	deadLetters.WithLabelValues(source).Inc()
+}
+
+// RecordAutoCancellation 记录超时未支付自动取消
+func RecordAutoCancellation() {
+	autoCancellations.Inc()
+}
+
+// ObserveOrderCreated 记录新订单金额和商品数
+func ObserveOrderCreated(total float64, items int) {
+	orderValue.Observe(total)
+	orderItems.Observe(float64(items))
+}
+
+// RecordWebhookDelivery 记录 webhook 投递尝试，outcome 为 success、retry 或 failed
+func RecordWebhookDelivery(eventType, outcome string) {
+	webhookDeliveries.WithLabelValues(eventType, outcome).Inc()
+}
+
+// ObserveGRPCRequest 记录 gRPC 请求的状态码和耗时
+func ObserveGRPCRequest(method, code string, duration time.Duration) {
+	grpcRequests.WithLabelValues(method, code).Inc()
+	grpcRequestDuration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+// StreamOpened 记录打开的 SSE 连接
+func StreamOpened() {
+	streamConnections.Inc()
+}
+
+// StreamClosed 记录关闭的 SSE 连接
+func StreamClosed() {
+	streamConnections.Dec()
+}
+
+// RegisterDBStatsCollector 注册数据库连接池指标
+func RegisterDBStatsCollector(db *sql.DB, dbName string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// RecordReadRoute 记录读查询的路由，target 为 primary 或 replica
+func RecordReadRoute(target, reason string) {
+	dbReadRoutes.WithLabelValues(target, reason).Inc()
+}
+
+// SetReplicaHealthy 记录从库健康检查结果
+func SetReplicaHealthy(replica string, healthy bool) {
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	dbReplicaHealthy.WithLabelValues(replica).Set(value)
+}
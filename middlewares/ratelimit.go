@@ -0,0 +1,214 @@
+package middlewares
+
+import (
+	"math"
+	"order-service/apperrors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var rateLimitRejections = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "order_service_rate_limit_rejections_total",
+		Help: "Total number of requests rejected by rate limiting",
+	},
+	[]string{"route", "scope"},
+)
+
+// RateLimit 令牌桶参数：Rate 为每秒补充的令牌数，Burst 为桶容量
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled 判断是否启用限流
+func (l RateLimit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// RateLimitRule 单个路由的限流规则
+type RateLimitRule struct {
+	Route   string    // 路由名，用于区分桶和指标标签
+	PerUser RateLimit // 按 AuthMiddleware 设置的 userID 限流
+	PerIP   RateLimit // 按客户端 IP 限流
+}
+
+// RateLimitResult 一次取令牌的结果
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // 被拒绝时距离下一个令牌的时间
+	ResetAfter time.Duration // 距离桶重新装满的时间
+}
+
+// RateLimitStore 限流后端。多副本部署时可以实现为共享存储（如 Redis），
+// 让所有副本共用同一组令牌桶。Refund 归还一个已取出的令牌
+type RateLimitStore interface {
+	Take(key string, limit RateLimit) (RateLimitResult, error)
+	Refund(key string, limit RateLimit) error
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryRateLimitStore 进程内令牌桶实现，每个副本独立计数
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	idleTTL time.Duration
+}
+
+// NewMemoryRateLimitStore 创建进程内限流后端，并定期清理空闲的桶
+func NewMemoryRateLimitStore(idleTTL time.Duration) *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{
+		buckets: make(map[string]*tokenBucket),
+		idleTTL: idleTTL,
+	}
+	go s.cleanup()
+	return s
+}
+
+func (s *MemoryRateLimitStore) Take(key string, limit RateLimit) (RateLimitResult, error) {
+	now := time.Now()
+	burst := float64(limit.Burst)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		s.buckets[key] = b
+	}
+
+	// 按流逝时间补充令牌
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	result := RateLimitResult{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / limit.Rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = secondsToDuration((burst - b.tokens) / limit.Rate)
+	return result, nil
+}
+
+func (s *MemoryRateLimitStore) Refund(key string, limit RateLimit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.buckets[key]; ok {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+1)
+	}
+	return nil
+}
+
+func (s *MemoryRateLimitStore) cleanup() {
+	ticker := time.NewTicker(s.idleTTL)
+	defer ticker.Stop()
+	for range ticker.C {
+		cutoff := time.Now().Add(-s.idleTTL)
+		s.mu.Lock()
+		for key, b := range s.buckets {
+			if b.last.Before(cutoff) {
+				delete(s.buckets, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+type rateLimitCheck struct {
+	scope string
+	key   string
+	limit RateLimit
+}
+
+// RateLimitMiddleware 按用户和客户端 IP 进行令牌桶限流，
+// 需要放在 AuthMiddleware 之后才能按用户限流
+func RateLimitMiddleware(store RateLimitStore, rule RateLimitRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		id, _ := userID.(int)
+		result, allowed := TakeRateLimit(store, rule, id, c.ClientIP())
+		if !allowed {
+			setRateLimitHeaders(c, *result)
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+			AbortWithError(c, apperrors.TooManyRequests("rate_limited", "Too many requests"))
+			return
+		}
+
+		if result != nil {
+			setRateLimitHeaders(c, *result)
+		}
+		c.Next()
+	}
+}
+
+// TakeRateLimit 按规则从用户和 IP 的桶中取令牌，userID 为 0 时不按用户限流。
+// 供 gRPC 等非 Gin 入口与 REST 共用同一组令牌桶。被拒绝时记录指标并返回拒绝的结果，
+// 放行时返回剩余令牌最少的结果，未启用限流时为 nil
+func TakeRateLimit(store RateLimitStore, rule RateLimitRule, userID int, ip string) (*RateLimitResult, bool) {
+	var checks []rateLimitCheck
+	if rule.PerUser.Enabled() && userID != 0 {
+		checks = append(checks, rateLimitCheck{"user", rule.Route + ":user:" + strconv.Itoa(userID), rule.PerUser})
+	}
+	if rule.PerIP.Enabled() {
+		checks = append(checks, rateLimitCheck{"ip", rule.Route + ":ip:" + ip, rule.PerIP})
+	}
+
+	result, rejected := takeAll(store, checks)
+	if rejected != nil {
+		rateLimitRejections.WithLabelValues(rule.Route, rejected.scope).Inc()
+		return result, false
+	}
+	return result, true
+}
+
+// takeAll 依次从各个桶取令牌。某个桶拒绝时归还之前已取的令牌，
+// 返回拒绝的检查项及其结果，避免被拒绝的请求消耗其他桶的额度；
+// 全部放行时返回剩余令牌最少的结果。限流后端出错的桶视为放行
+func takeAll(store RateLimitStore, checks []rateLimitCheck) (*RateLimitResult, *rateLimitCheck) {
+	var tightest *RateLimitResult
+	var taken []rateLimitCheck
+	for i, check := range checks {
+		result, err := store.Take(check.key, check.limit)
+		if err != nil {
+			// 限流后端不可用时放行，避免影响正常下单
+			continue
+		}
+		if !result.Allowed {
+			for _, t := range taken {
+				_ = store.Refund(t.key, t.limit)
+			}
+			return &result, &checks[i]
+		}
+		taken = append(taken, check)
+		if tightest == nil || result.Remaining < tightest.Remaining {
+			r := result
+			tightest = &r
+		}
+	}
+	return tightest, nil
+}
+
+func setRateLimitHeaders(c *gin.Context, result RateLimitResult) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(result.ResetAfter.Seconds()))))
+}
@@ -0,0 +1,158 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMemoryRateLimitStoreTake(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     RateLimit
+		takes     int
+		allowed   int
+		remaining int
+	}{
+		{"within burst", RateLimit{Rate: 1, Burst: 3}, 2, 2, 1},
+		{"exactly burst", RateLimit{Rate: 1, Burst: 3}, 3, 3, 0},
+		{"over burst", RateLimit{Rate: 1, Burst: 3}, 5, 3, 0},
+		{"burst of one", RateLimit{Rate: 0.5, Burst: 1}, 2, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryRateLimitStore(time.Hour)
+			allowed := 0
+			var last RateLimitResult
+			for i := 0; i < tt.takes; i++ {
+				result, err := store.Take("key", tt.limit)
+				if err != nil {
+					t.Fatalf("Take: %v", err)
+				}
+				if result.Allowed {
+					allowed++
+				}
+				last = result
+			}
+			if allowed != tt.allowed {
+				t.Errorf("allowed = %d, want %d", allowed, tt.allowed)
+			}
+			if last.Remaining != tt.remaining {
+				t.Errorf("remaining = %d, want %d", last.Remaining, tt.remaining)
+			}
+			if last.Limit != tt.limit.Burst {
+				t.Errorf("limit = %d, want %d", last.Limit, tt.limit.Burst)
+			}
+			if !last.Allowed && last.RetryAfter <= 0 {
+				t.Errorf("rejected result has no RetryAfter")
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreRefund(t *testing.T) {
+	store := NewMemoryRateLimitStore(time.Hour)
+	limit := RateLimit{Rate: 0.001, Burst: 1}
+	if result, _ := store.Take("key", limit); !result.Allowed {
+		t.Fatal("first take rejected")
+	}
+	if err := store.Refund("key", limit); err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if result, _ := store.Take("key", limit); !result.Allowed {
+		t.Error("take after refund rejected")
+	}
+	// 归还不会超过桶容量
+	_ = store.Refund("key", limit)
+	_ = store.Refund("key", limit)
+	store.Take("key", limit)
+	if result, _ := store.Take("key", limit); result.Allowed {
+		t.Error("refund exceeded burst")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		rule   RateLimitRule
+		userID int
+		codes  []int
+	}{
+		{
+			name:   "user limit",
+			rule:   RateLimitRule{Route: "r", PerUser: RateLimit{Rate: 0.001, Burst: 2}},
+			userID: 1,
+			codes:  []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:  "ip limit",
+			rule:  RateLimitRule{Route: "r", PerIP: RateLimit{Rate: 0.001, Burst: 1}},
+			codes: []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:   "disabled",
+			rule:   RateLimitRule{Route: "r"},
+			userID: 1,
+			codes:  []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newRateLimitRouter(NewMemoryRateLimitStore(time.Hour), tt.rule, tt.userID)
+			for i, want := range tt.codes {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+				if w.Code != want {
+					t.Fatalf("request %d: status = %d, want %d", i+1, w.Code, want)
+				}
+				if want == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+					t.Errorf("request %d: missing Retry-After", i+1)
+				}
+			}
+		})
+	}
+}
+
+// IP 桶拒绝的请求不应消耗用户桶的令牌
+func TestRateLimitMiddlewareRefundsUserOnIPRejection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := NewMemoryRateLimitStore(time.Hour)
+	rule := RateLimitRule{
+		Route:   "r",
+		PerUser: RateLimit{Rate: 0.001, Burst: 2},
+		PerIP:   RateLimit{Rate: 0.001, Burst: 1},
+	}
+	router := newRateLimitRouter(store, rule, 7)
+	for _, want := range []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != want {
+			t.Fatalf("status = %d, want %d", w.Code, want)
+		}
+	}
+
+	result, _ := store.Take("r:user:7", rule.PerUser)
+	if !result.Allowed || result.Remaining != 0 {
+		t.Errorf("user bucket after IP rejections: allowed=%v remaining=%d, want one token left", result.Allowed, result.Remaining)
+	}
+}
+
+func newRateLimitRouter(store RateLimitStore, rule RateLimitRule, userID int) *gin.Engine {
+	router := gin.New()
+	router.Use(ErrorMiddleware())
+	router.GET("/", func(c *gin.Context) {
+		if userID != 0 {
+			c.Set("userID", userID)
+		}
+		c.Next()
+	}, RateLimitMiddleware(store, rule), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Address 收货/账单地址。下单时以快照形式保存在订单上，
+// 之后修改用户地址簿不影响已下订单
+type Address struct {
+	RecipientName string `json:"recipient_name"`
+	Phone         string `json:"phone"`
+	Line1         string `json:"line1"`
+	Line2         string `json:"line2,omitempty"`
+	City          string `json:"city"`
+	State         string `json:"state,omitempty"`
+	PostalCode    string `json:"postal_code,omitempty"`
+	Country       string `json:"country"` // ISO 3166-1 alpha-2
+}
+
+// UserAddress 用户地址簿中保存的地址
+type UserAddress struct {
+	ID      int     `json:"id"`
+	UserID  int     `json:"user_id"`
+	Label   string  `json:"label,omitempty"`
+	Address Address `json:"address"`
+}
+
+// Masked 返回脱敏后的地址副本，用于日志输出
+func (a Address) Masked() Address {
+	return Address{
+		RecipientName: maskKeep(a.RecipientName, 1, 0),
+		Phone:         maskKeep(a.Phone, 0, 4),
+		Line1:         maskKeep(a.Line1, 0, 0),
+		Line2:         maskKeep(a.Line2, 0, 0),
+		City:          a.City,
+		State:         a.State,
+		PostalCode:    maskKeep(a.PostalCode, 2, 0),
+		Country:       a.Country,
+	}
+}
+
+// String 实现 fmt.Stringer，始终输出脱敏内容，避免个人信息写入日志
+func (a Address) String() string {
+	m := a.Masked()
+	return fmt.Sprintf("%s, %s, %s %s %s %s", m.RecipientName, m.Phone, m.Line1, m.City, m.PostalCode, m.Country)
+}
+
+// maskKeep 保留开头 head 个和结尾 tail 个字符，其余替换为 *
+func maskKeep(s string, head, tail int) string {
+	n := utf8.RuneCountInString(s)
+	if n == 0 {
+		return ""
+	}
+	if head+tail >= n {
+		return strings.Repeat("*", n)
+	}
+	runes := []rune(s)
+	return string(runes[:head]) + strings.Repeat("*", n-head-tail) + string(runes[n-tail:])
+}
@@ -0,0 +1,49 @@
+package models
+
+import "testing"
+
+func TestAddressMasked(t *testing.T) {
+	addr := Address{
+		RecipientName: "张三丰",
+		Phone:         "13812345678",
+		Line1:         "人民路 1 号",
+		City:          "上海",
+		State:         "上海",
+		PostalCode:    "200000",
+		Country:       "CN",
+	}
+	want := Address{
+		RecipientName: "张**",
+		Phone:         "*******5678",
+		Line1:         "*******",
+		City:          "上海",
+		State:         "上海",
+		PostalCode:    "20****",
+		Country:       "CN",
+	}
+	if got := addr.Masked(); got != want {
+		t.Errorf("Masked() = %+v, want %+v", got, want)
+	}
+	if got, wantString := addr.String(), "张**, *******5678, ******* 上海 20**** CN"; got != wantString {
+		t.Errorf("String() = %q, want %q", got, wantString)
+	}
+}
+
+func TestMaskKeep(t *testing.T) {
+	tests := []struct {
+		s          string
+		head, tail int
+		want       string
+	}{
+		{"", 1, 1, ""},
+		{"ab", 1, 1, "**"},
+		{"abc", 1, 1, "a*c"},
+		{"abcdef", 0, 4, "**cdef"},
+		{"张三", 1, 0, "张*"},
+	}
+	for _, tt := range tests {
+		if got := maskKeep(tt.s, tt.head, tt.tail); got != tt.want {
+			t.Errorf("maskKeep(%q, %d, %d) = %q, want %q", tt.s, tt.head, tt.tail, got, tt.want)
+		}
+	}
+}
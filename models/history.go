@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OrderHistoryEntry 订单状态变更记录
+type OrderHistoryEntry struct {
+	ID        int       `json:"id"`
+	OrderID   int       `json:"order_id"`
+	OldStatus string    `json:"old_status,omitempty"`
+	NewStatus string    `json:"new_status"`
+	Actor     string    `json:"actor"`
+	Source    string    `json:"source"` // api、consumer 或 admin
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -4,44 +4,225 @@ import (
 	"time"
 )
 
+// 订单状态
+const (
+	OrderStatusPending          = "pending"
+	OrderStatusProcessing       = "processing"
+	OrderStatusPartiallyShipped = "partially_shipped"
+	OrderStatusShipped          = "shipped"
+	OrderStatusDelivered        = "delivered"
+	OrderStatusCancelled        = "cancelled"
+)
+
+// OrderStatuses 全部订单状态，修改状态的请求只接受其中的值
+var OrderStatuses = []string{
+	OrderStatusPending,
+	OrderStatusProcessing,
+	OrderStatusPartiallyShipped,
+	OrderStatusShipped,
+	OrderStatusDelivered,
+	OrderStatusCancelled,
+}
+
+// IsOrderStatus 判断 status 是否为已知的订单状态
+func IsOrderStatus(status string) bool {
+	for _, s := range OrderStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// orderStatusTransitions 订单状态机，键为当前状态，值为可以转换到的状态。delivered 和 cancelled 为终态
+var orderStatusTransitions = map[string][]string{
+	OrderStatusPending:          {OrderStatusProcessing, OrderStatusCancelled},
+	OrderStatusProcessing:       {OrderStatusPartiallyShipped, OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusPartiallyShipped: {OrderStatusShipped},
+	OrderStatusShipped:          {OrderStatusDelivered},
+}
+
+// CanTransitionOrder 判断订单能否从 from 状态转换到 to 状态
+func CanTransitionOrder(from, to string) bool {
+	for _, status := range orderStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
 type Order struct {
 	ID        int         `json:"id"`
 	UserID    int         `json:"user_id"`
-	Total     float64     `json:"total" binding:"required"`
+	Total     float64     `json:"total"` // 可选，提供时必须与商品行合计一致
 	Status    string      `json:"status"`
 	CreatedAt time.Time   `json:"created_at"`
 	UpdatedAt time.Time   `json:"updated_at"`
-	Items     []OrderItem `json:"items" binding:"required"`
+	Items     []OrderItem `json:"items"`
+
+	// 收货地址：直接提供地址快照，或引用用户地址簿中的地址
+	ShippingAddress   *Address `json:"shipping_address,omitempty"`
+	ShippingAddressID *int     `json:"shipping_address_id,omitempty"`
+	// 账单地址：不提供时与收货地址相同
+	BillingAddress       *Address `json:"billing_address,omitempty"`
+	BillingAddressID     *int     `json:"billing_address_id,omitempty"`
+	DeliveryInstructions string   `json:"delivery_instructions,omitempty"`
+
+	Currency       string    `json:"currency,omitempty"` // ISO 4217，默认基准货币
+	CouponCode     string    `json:"coupon_code,omitempty"`
+	DeliveryOption string    `json:"delivery_option,omitempty"` // standard、express 或 pickup，默认 standard
+	Subtotal       float64   `json:"-"`                         // 商品行合计，由服务端计算
+	Discount       float64   `json:"-"`                         // 优惠总额，由服务端计算
+	TaxTotal       float64   `json:"-"`                         // 价外税合计，由服务端计算
+	TaxLines       []TaxLine `json:"-"`
+	ShippingFee    float64   `json:"-"`
+	BaseCurrency   string    `json:"-"` // 下单时的汇率快照
+	ExchangeRate   float64   `json:"-"`
+	TotalBase      float64   `json:"-"`
 }
 
+// OrderItem 订单商品行，字段校验见 validation.NormalizeOrder
 type OrderItem struct {
-	ProductID   int     `json:"product_id" binding:"required"`
-	ProductName string  `json:"product_name" binding:"required"`
-	Quantity    int     `json:"quantity" binding:"required"`
-	Price       float64 `json:"price" binding:"required"`
+	ProductID   int     `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Quantity    int     `json:"quantity"`
+	Price       float64 `json:"price"`
+	Discount    float64 `json:"-"` // 分摊到该行的优惠，由服务端计算
+	TaxClass    string  `json:"-"` // 商品税类，由服务端查询
+	WeightGrams int     `json:"-"` // 单件重量，由服务端查询
+}
+
+// OrderItemUpdate 修改订单商品行：数量为 0 时删除该行，商品不在订单中时新增
+type OrderItemUpdate struct {
+	ProductID   int    `json:"product_id" binding:"required,gt=0"`
+	ProductName string `json:"product_name"` // 新增商品时必填
+	Quantity    *int   `json:"quantity" binding:"required,gte=0"`
+}
+
+// OrderItemChange 商品行数量变化，OldQuantity 为 0 表示新增，NewQuantity 为 0 表示删除
+type OrderItemChange struct {
+	ProductID   int    `json:"product_id"`
+	ProductName string `json:"product_name"`
+	OldQuantity int    `json:"old_quantity"`
+	NewQuantity int    `json:"new_quantity"`
+}
+
+// OrderItemsResult 修改商品行并重新定价后的订单金额和商品行变化
+type OrderItemsResult struct {
+	OrderID     int               `json:"order_id"`
+	Subtotal    float64           `json:"subtotal"`
+	Discount    float64           `json:"discount"`
+	TaxTotal    float64           `json:"tax_total"`
+	ShippingFee float64           `json:"shipping_fee"`
+	Total       float64           `json:"total"`
+	Currency    string            `json:"currency"`
+	Changes     []OrderItemChange `json:"changes"`
+}
+
+// BatchOrdersResult 批量查询订单的结果。Orders 按请求中的顺序排列，
+// NotFound 为不存在或不属于当前用户的订单ID
+type BatchOrdersResult struct {
+	Orders   []OrderResponse `json:"orders"`
+	NotFound []int           `json:"not_found"`
+}
+
+// 批量修改状态中单个订单的处理结果
+const (
+	BatchResultUpdated   = "updated"
+	BatchResultUnchanged = "unchanged"
+	BatchResultFailed    = "failed"
+)
+
+// OrderStatusUpdate 批量修改状态中的单个订单。Version 可选，提供时必须与订单当前版本一致
+type OrderStatusUpdate struct {
+	OrderID int    `json:"order_id" binding:"required,min=1"`
+	Status  string `json:"status" binding:"required,oneof=pending processing partially_shipped shipped delivered cancelled"`
+	Version int    `json:"version,omitempty" binding:"min=0"`
+	Reason  string `json:"reason,omitempty" binding:"max=255"`
+}
+
+// OrderStatusUpdateResult 批量修改状态中单个订单的结果，失败时带有错误码
+type OrderStatusUpdateResult struct {
+	OrderID   int    `json:"order_id"`
+	Result    string `json:"result"` // updated、unchanged 或 failed
+	OldStatus string `json:"old_status,omitempty"`
+	NewStatus string `json:"new_status,omitempty"`
+	Version   int    `json:"version,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BatchStatusUpdateResponse 批量修改状态的结果，Results 与请求顺序一致
+type BatchStatusUpdateResponse struct {
+	Results   []OrderStatusUpdateResult `json:"results"`
+	Updated   int                       `json:"updated"`
+	Unchanged int                       `json:"unchanged"`
+	Failed    int                       `json:"failed"`
 }
 
 type OrderResponse struct {
-	ID        int               `json:"id"`
-	UserID    int               `json:"user_id"`
-	Total     float64           `json:"total"`
-	Status    string            `json:"status"`
-	CreatedAt time.Time         `json:"created_at"`
-	Items     []OrderItemDetail `json:"items"`
+	ID             int               `json:"id"`
+	UserID         int               `json:"user_id"`
+	Subtotal       float64           `json:"subtotal"`
+	Discount       float64           `json:"discount"`
+	CouponCode     string            `json:"coupon_code,omitempty"`
+	TaxTotal       float64           `json:"tax_total"`
+	DeliveryOption string            `json:"delivery_option"`
+	ShippingFee    float64           `json:"shipping_fee"`
+	Total          float64           `json:"total"`
+	Currency       string            `json:"currency"`
+	BaseCurrency   string            `json:"base_currency"`
+	ExchangeRate   float64           `json:"exchange_rate"`
+	TotalBase      float64           `json:"total_base"`
+	Status         string            `json:"status"`
+	Version        int               `json:"version"` // 乐观锁版本号，与 ETag 一致
+	CreatedAt      time.Time         `json:"created_at"`
+	Items          []OrderItemDetail `json:"items"`
+
+	ShippingAddress      *Address `json:"shipping_address,omitempty"`
+	BillingAddress       *Address `json:"billing_address,omitempty"`
+	DeliveryInstructions string   `json:"delivery_instructions,omitempty"`
+
+	TaxLines []TaxLine `json:"tax_lines,omitempty"`
+}
+
+// TaxLine 订单商品行的税额。Inclusive 为 true 时税额已含在价格中
+type TaxLine struct {
+	ProductID     int     `json:"product_id"`
+	TaxClass      string  `json:"tax_class"`
+	Jurisdiction  string  `json:"jurisdiction"`
+	Rate          float64 `json:"rate"`
+	TaxableAmount float64 `json:"taxable_amount"`
+	Amount        float64 `json:"amount"`
+	Inclusive     bool    `json:"inclusive"`
 }
 
 type OrderItemDetail struct {
+	LineID      int     `json:"-"` // order_items.id，只在 v2 接口中返回
 	ProductID   int     `json:"product_id"`
 	ProductName string  `json:"product_name"`
 	Quantity    int     `json:"quantity"`
 	Price       float64 `json:"price"`
 	Subtotal    float64 `json:"subtotal"`
+	Discount    float64 `json:"discount"`
 }
+
 type OrderEvent struct {
 	OrderID  int       `json:"order_id"`
 	UserID   int       `json:"user_id"`
-	Type     string    `json:"type"` // created, updated, canceled, completed
+	Type     string    `json:"type"` // created, status_updated, items_changed, payment_check, shipment_created, return_*
 	Status   string    `json:"status"`
 	Total    float64   `json:"total"`
+	Currency string    `json:"currency,omitempty"`
 	Occurred time.Time `json:"occurred"`
+
+	ShippingAddress      *Address       `json:"shipping_address,omitempty"`
+	DeliveryInstructions string         `json:"delivery_instructions,omitempty"`
+	DeliveryOption       string         `json:"delivery_option,omitempty"`
+	ShippingFee          float64        `json:"shipping_fee,omitempty"`
+	Shipment             *Shipment      `json:"shipment,omitempty"`
+	Return               *ReturnRequest `json:"return,omitempty"`
+
+	ItemChanges []OrderItemChange `json:"item_changes,omitempty"`
 }
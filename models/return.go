@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// 退货单状态
+const (
+	ReturnStatusRequested    = "requested"
+	ReturnStatusApproved     = "approved"
+	ReturnStatusRejected     = "rejected"
+	ReturnStatusReceived     = "received"
+	ReturnStatusRefunding    = "refunding" // 已占用退款，正在调用支付渠道
+	ReturnStatusRefunded     = "refunded"
+	ReturnStatusRefundFailed = "refund_failed"
+)
+
+// ReturnRequest 退货退款单
+type ReturnRequest struct {
+	ID              int          `json:"id"`
+	OrderID         int          `json:"order_id"`
+	UserID          int          `json:"user_id"`
+	Status          string       `json:"status"`
+	Currency        string       `json:"currency"` // 订单货币，金额均以该货币计
+	Items           []ReturnItem `json:"items"`
+	ItemsAmount     float64      `json:"items_amount"`
+	RestockingFee   float64      `json:"restocking_fee"`
+	RefundAmount    float64      `json:"refund_amount"`
+	RefundReference string       `json:"refund_reference,omitempty"`
+	ReviewNote      string       `json:"review_note,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// ReturnItem 退货商品行，按 product_id 引用订单商品
+type ReturnItem struct {
+	ProductID int     `json:"product_id" binding:"required"`
+	Quantity  int     `json:"quantity" binding:"required,gt=0"`
+	Reason    string  `json:"reason" binding:"required,max=500"`
+	Price     float64 `json:"price"` // 扣除优惠后的单价
+}
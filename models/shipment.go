@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// 发货单状态
+const (
+	ShipmentStatusShipped   = "shipped"
+	ShipmentStatusDelivered = "delivered"
+)
+
+// Shipment 发货单，一个订单可以分多次发货
+type Shipment struct {
+	ID             int            `json:"id"`
+	OrderID        int            `json:"order_id"`
+	Carrier        string         `json:"carrier"`
+	TrackingNumber string         `json:"tracking_number"`
+	Status         string         `json:"status"`
+	ShippedAt      time.Time      `json:"shipped_at"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty"`
+	Items          []ShipmentItem `json:"items"`
+}
+
+// ShipmentItem 发货单包含的订单商品及数量。订单内同一商品只有一行，
+// 因此按 product_id 引用订单商品行
+type ShipmentItem struct {
+	ProductID int `json:"product_id" binding:"required"`
+	Quantity  int `json:"quantity" binding:"required,gt=0"`
+}
@@ -0,0 +1,45 @@
+// Package openapi 订单服务 REST 接口的 OpenAPI 3.1 规范、文档页面以及按规范校验请求和响应
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openapi.json 是接口契约的唯一来源，修改路由或响应结构时必须同步更新，
+// 根目录的 main_test.go 会检查路由和响应结构与规范一致
+//
+//go:embed openapi.json
+var spec []byte
+
+//go:embed docs.html
+var docsPage []byte
+
+// Spec 返回规范原文
+func Spec() []byte {
+	return spec
+}
+
+// ServeSpec 返回 OpenAPI 规范
+func ServeSpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", spec)
+}
+
+// ServeDocs 返回接口文档页面，页面从 /openapi.json 读取规范并在浏览器中渲染
+func ServeDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
+
+// PathFromRoute 将 gin 路由转换为规范中的路径模板，如 /api/orders/:id 转为 /api/orders/{id}
+func PathFromRoute(route string) string {
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
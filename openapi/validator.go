@@ -0,0 +1,232 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"order-service/apperrors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+const specURL = "urn:order-service:openapi"
+
+// IsJSONMediaType 判断是否为 JSON 媒体类型（application/json 或 +json 后缀），
+// 其他类型（SSE、HTML、Prometheus 文本）不做校验
+func IsJSONMediaType(mediaType string) bool {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func baseMediaType(mediaType string) string {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+var methods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// Validator 按规范校验 JSON 请求体和响应体。Schema 在创建时全部编译，校验时只读，可以并发使用
+type Validator struct {
+	operations map[string]*operation
+}
+
+// operation 一个操作的 schema，按媒体类型区分；同一路径可以按协商的版本返回不同结构
+type operation struct {
+	request   map[string]*jsonschema.Schema
+	responses map[string]map[string]*jsonschema.Schema // 键为状态码或 default
+}
+
+// NewValidator 编译规范中所有操作的请求和响应 schema
+func NewValidator() (*Validator, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(spec))
+	if err != nil {
+		return nil, fmt.Errorf("parse openapi spec: %w", err)
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	if err := compiler.AddResource(specURL, doc); err != nil {
+		return nil, fmt.Errorf("load openapi spec: %w", err)
+	}
+
+	root, _ := doc.(map[string]any)
+	paths, _ := root["paths"].(map[string]any)
+	v := &Validator{operations: make(map[string]*operation)}
+	for path, item := range paths {
+		pathItem, _ := item.(map[string]any)
+		for _, method := range methods {
+			opDoc, ok := pathItem[strings.ToLower(method)].(map[string]any)
+			if !ok {
+				continue
+			}
+			pointer := "/paths/" + escapePointer(path) + "/" + strings.ToLower(method)
+			op := &operation{responses: make(map[string]map[string]*jsonschema.Schema)}
+
+			if body, ok := opDoc["requestBody"].(map[string]any); ok {
+				if op.request, err = compileContent(compiler, root, body, pointer+"/requestBody"); err != nil {
+					return nil, fmt.Errorf("%s %s request: %w", method, path, err)
+				}
+			}
+
+			responses, _ := opDoc["responses"].(map[string]any)
+			for code, resp := range responses {
+				respDoc, _ := resp.(map[string]any)
+				schemas, err := compileContent(compiler, root, respDoc, pointer+"/responses/"+escapePointer(code))
+				if err != nil {
+					return nil, fmt.Errorf("%s %s response %s: %w", method, path, code, err)
+				}
+				// 没有 JSON 内容的响应（如 204）记为空表，表示该状态码已声明但没有 JSON 响应体
+				op.responses[code] = schemas
+			}
+			v.operations[method+" "+path] = op
+		}
+	}
+	return v, nil
+}
+
+// compileContent 按媒体类型编译请求体或响应对象中 JSON 内容的 schema，支持引用 components 中的对象
+func compileContent(compiler *jsonschema.Compiler, root, object map[string]any, pointer string) (map[string]*jsonschema.Schema, error) {
+	if ref, ok := object["$ref"].(string); ok {
+		pointer = strings.TrimPrefix(ref, "#")
+		object = resolvePointer(root, pointer)
+		if object == nil {
+			return nil, fmt.Errorf("unresolved reference %s", ref)
+		}
+	}
+	content, _ := object["content"].(map[string]any)
+	schemas := make(map[string]*jsonschema.Schema)
+	for mediaType := range content {
+		if !IsJSONMediaType(mediaType) {
+			continue
+		}
+		sch, err := compiler.Compile(specURL + "#" + pointer + "/content/" + escapePointer(mediaType) + "/schema")
+		if err != nil {
+			return nil, err
+		}
+		schemas[baseMediaType(mediaType)] = sch
+	}
+	return schemas, nil
+}
+
+func resolvePointer(root map[string]any, pointer string) map[string]any {
+	var node any = root
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		object, ok := node.(map[string]any)
+		if !ok {
+			return nil
+		}
+		node = object[strings.NewReplacer("~1", "/", "~0", "~").Replace(token)]
+	}
+	object, _ := node.(map[string]any)
+	return object
+}
+
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// Operations 返回规范中的全部操作，格式为 "METHOD /path"，按字母排序
+func (v *Validator) Operations() []string {
+	ops := make([]string, 0, len(v.operations))
+	for key := range v.operations {
+		ops = append(ops, key)
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// ValidateRequest 校验请求体。path 为规范中的路径模板，mediaType 为请求体的媒体类型。
+// 未声明的操作或媒体类型不校验；请求体不是合法 JSON 时不报错，由处理器的参数绑定返回错误
+func (v *Validator) ValidateRequest(method, path, mediaType string, body []byte) error {
+	op, ok := v.operations[method+" "+path]
+	if !ok || len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	sch, ok := op.request[baseMediaType(mediaType)]
+	if !ok {
+		return nil
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	return sch.Validate(instance)
+}
+
+// ValidateResponse 校验 JSON 响应体。状态码或媒体类型未在规范中声明时返回错误，
+// 未声明的状态码使用 default 响应
+func (v *Validator) ValidateResponse(method, path string, status int, mediaType string, body []byte) error {
+	op, ok := v.operations[method+" "+path]
+	if !ok {
+		return fmt.Errorf("operation %s %s is not in the spec", method, path)
+	}
+	schemas, ok := op.responses[strconv.Itoa(status)]
+	if !ok {
+		if schemas, ok = op.responses["default"]; !ok {
+			return fmt.Errorf("status %d is not documented", status)
+		}
+	}
+	if len(bytes.TrimSpace(body)) == 0 && len(schemas) == 0 {
+		return nil
+	}
+	sch, ok := schemas[baseMediaType(mediaType)]
+	if !ok {
+		return fmt.Errorf("media type %q is not documented for status %d", mediaType, status)
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("response body is not valid JSON: %w", err)
+	}
+	return sch.Validate(instance)
+}
+
+var printer = message.NewPrinter(language.English)
+
+// FieldErrors 将校验错误转换为字段错误，字段路径格式与参数绑定错误一致，如 items[2].quantity
+func FieldErrors(err error) []apperrors.FieldError {
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []apperrors.FieldError{{Field: "body", Message: err.Error()}}
+	}
+	var fields []apperrors.FieldError
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			fields = append(fields, apperrors.FieldError{
+				Field:   fieldPath(e.InstanceLocation),
+				Message: e.ErrorKind.LocalizedString(printer),
+			})
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(validationErr)
+	return fields
+}
+
+func fieldPath(location []string) string {
+	if len(location) == 0 {
+		return "body"
+	}
+	var sb strings.Builder
+	for _, token := range location {
+		if _, err := strconv.Atoi(token); err == nil {
+			sb.WriteString("[" + token + "]")
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(token)
+	}
+	return sb.String()
+}
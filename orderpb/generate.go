@@ -0,0 +1,4 @@
+// Package orderpb 订单服务 gRPC 接口的 protobuf 定义和生成代码
+package orderpb
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative orderpb/order.proto
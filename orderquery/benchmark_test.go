@@ -0,0 +1,303 @@
+package orderquery
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"order-service/models"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// 基准测试需要 MySQL：ORDERQUERY_BENCH_DSN 指向服务器（不带库名），如
+// root:secret@tcp(127.0.0.1:3306)/
+// 测试会创建并在结束时删除 orderquery_bench 库，未设置时跳过
+const benchDatabase = "orderquery_bench"
+
+// 数据集：一个有 2000 个订单的活跃用户，以及 199 个各有 50 个订单的普通用户。
+// 每个订单 0 到 8 个商品行，约一成订单没有商品行，部分订单创建时间相同
+const (
+	benchUserID      = 1
+	benchUserOrders  = 2000
+	otherUsers       = 199
+	otherUserOrders  = 50
+	maxItemsPerOrder = 8
+)
+
+var (
+	benchOnce sync.Once
+	benchDB   *sql.DB
+	benchErr  error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if benchDB != nil {
+		benchDB.Exec("DROP DATABASE IF EXISTS " + benchDatabase)
+		benchDB.Close()
+	}
+	os.Exit(code)
+}
+
+func openBenchDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	dsn := os.Getenv("ORDERQUERY_BENCH_DSN")
+	if dsn == "" {
+		tb.Skip("ORDERQUERY_BENCH_DSN not set")
+	}
+	benchOnce.Do(func() {
+		benchDB, benchErr = seed(dsn)
+	})
+	if benchErr != nil {
+		tb.Fatalf("seed benchmark dataset: %v", benchErr)
+	}
+	return benchDB
+}
+
+func seed(dsn string) (*sql.DB, error) {
+	admin, err := sql.Open("mysql", dsn+"?multiStatements=true")
+	if err != nil {
+		return nil, err
+	}
+	defer admin.Close()
+	if _, err := admin.Exec(`
+		DROP DATABASE IF EXISTS ` + benchDatabase + `;
+		CREATE DATABASE ` + benchDatabase + `;
+		CREATE TABLE ` + benchDatabase + `.orders (
+			id                    INT AUTO_INCREMENT PRIMARY KEY,
+			user_id               INT            NOT NULL,
+			subtotal              DECIMAL(10, 2) NOT NULL,
+			discount              DECIMAL(10, 2) NOT NULL DEFAULT 0,
+			coupon_code           VARCHAR(50)    NOT NULL DEFAULT '',
+			tax_total             DECIMAL(10, 2) NOT NULL DEFAULT 0,
+			delivery_option       VARCHAR(16)    NOT NULL DEFAULT 'standard',
+			shipping_fee          DECIMAL(10, 2) NOT NULL DEFAULT 0,
+			total                 DECIMAL(10, 2) NOT NULL,
+			currency              CHAR(3)        NOT NULL,
+			base_currency         CHAR(3)        NOT NULL,
+			exchange_rate         DECIMAL(18, 8) NOT NULL DEFAULT 1,
+			total_base            DECIMAL(10, 2) NOT NULL,
+			status                VARCHAR(32)    NOT NULL,
+			version               INT            NOT NULL DEFAULT 1,
+			delivery_instructions VARCHAR(500)   NOT NULL DEFAULT '',
+			created_at            DATETIME       NOT NULL,
+			INDEX idx_orders_user_created (user_id, created_at, id)
+		);
+		CREATE TABLE ` + benchDatabase + `.order_items (
+			id           INT AUTO_INCREMENT PRIMARY KEY,
+			order_id     INT            NOT NULL,
+			product_id   INT            NOT NULL,
+			product_name VARCHAR(255)   NOT NULL,
+			quantity     INT            NOT NULL,
+			price        DECIMAL(10, 2) NOT NULL,
+			discount     DECIMAL(10, 2) NOT NULL DEFAULT 0,
+			INDEX idx_order_items_order (order_id)
+		);`); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("mysql", strings.TrimSuffix(dsn, "/")+"/"+benchDatabase+"?parseTime=true")
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(42))
+	statuses := []string{"pending", "processing", "shipped", "delivered", "cancelled"}
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	var orders, items []string
+	var orderArgs, itemArgs []interface{}
+	orderID := 0
+	var previous time.Time
+	flush := func(force bool) error {
+		if len(orders) >= 500 || (force && len(orders) > 0) {
+			if _, err := db.Exec(`INSERT INTO orders (id, user_id, subtotal, total, currency, base_currency, total_base, status, created_at)
+				VALUES `+strings.Join(orders, ","), orderArgs...); err != nil {
+				return err
+			}
+			orders, orderArgs = orders[:0], orderArgs[:0]
+		}
+		if len(items) >= 500 || (force && len(items) > 0) {
+			if _, err := db.Exec(`INSERT INTO order_items (order_id, product_id, product_name, quantity, price)
+				VALUES `+strings.Join(items, ","), itemArgs...); err != nil {
+				return err
+			}
+			items, itemArgs = items[:0], itemArgs[:0]
+		}
+		return nil
+	}
+	addOrders := func(userID, count int) error {
+		for i := 0; i < count; i++ {
+			orderID++
+			// 约 5% 的订单与上一个订单创建时间相同，用于检查分页处理并列排序
+			createdAt := start.Add(time.Duration(rng.Intn(2*365*24*3600)) * time.Second)
+			if i > 0 && rng.Intn(20) == 0 {
+				createdAt = previous
+			}
+			previous = createdAt
+			itemCount := 0
+			if rng.Intn(10) != 0 {
+				itemCount = 1 + rng.Intn(maxItemsPerOrder)
+			}
+			total := 0.0
+			for j := 0; j < itemCount; j++ {
+				price := float64(100+rng.Intn(20000)) / 100
+				quantity := 1 + rng.Intn(3)
+				total += price * float64(quantity)
+				items = append(items, "(?, ?, ?, ?, ?)")
+				itemArgs = append(itemArgs, orderID, 1+rng.Intn(5000), fmt.Sprintf("Product %d", j), quantity, price)
+			}
+			orders = append(orders, "(?, ?, ?, ?, 'CNY', 'CNY', ?, ?, ?)")
+			orderArgs = append(orderArgs, orderID, userID, total, total, total, statuses[rng.Intn(len(statuses))], createdAt)
+			if err := flush(false); err != nil {
+				return err
+			}
+		}
+		return flush(true)
+	}
+
+	if err := addOrders(benchUserID, benchUserOrders); err != nil {
+		db.Close()
+		return nil, err
+	}
+	for userID := benchUserID + 1; userID <= benchUserID+otherUsers; userID++ {
+		if err := addOrders(userID, otherUserOrders); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// TestListUserOrdersPagination 逐页读取活跃用户的全部订单：顺序严格递减、没有重复或遗漏，没有商品行的订单也返回
+func TestListUserOrdersPagination(t *testing.T) {
+	db := openBenchDB(t)
+	ctx := context.Background()
+
+	seen := make(map[int]bool)
+	var last *time.Time
+	lastID, empty := 0, 0
+	cursor := ""
+	for {
+		page, err := ListUserOrders(ctx, db, benchUserID, ListOptions{PageSize: 137, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("ListUserOrders: %v", err)
+		}
+		for _, o := range page.Orders {
+			if seen[o.ID] {
+				t.Fatalf("order %d returned twice", o.ID)
+			}
+			seen[o.ID] = true
+			if last != nil && (o.CreatedAt.After(*last) || (o.CreatedAt.Equal(*last) && o.ID > lastID)) {
+				t.Fatalf("order %d (%s) out of order after %d (%s)", o.ID, o.CreatedAt, lastID, *last)
+			}
+			createdAt := o.CreatedAt
+			last, lastID = &createdAt, o.ID
+			if len(o.Items) == 0 {
+				empty++
+			}
+			for i := 1; i < len(o.Items); i++ {
+				if o.Items[i].LineID <= o.Items[i-1].LineID {
+					t.Fatalf("items of order %d out of order", o.ID)
+				}
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(seen) != benchUserOrders {
+		t.Errorf("got %d orders, want %d", len(seen), benchUserOrders)
+	}
+	if empty == 0 {
+		t.Error("orders without items are missing")
+	}
+}
+
+func BenchmarkListUserOrdersFirstPage(b *testing.B) {
+	db := openBenchDB(b)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ListUserOrders(ctx, db, benchUserID, ListOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkListUserOrdersDeepPage(b *testing.B) {
+	db := openBenchDB(b)
+	ctx := context.Background()
+	cursor := ""
+	for i := 0; i < 30; i++ {
+		page, err := ListUserOrders(ctx, db, benchUserID, ListOptions{Cursor: cursor})
+		if err != nil {
+			b.Fatal(err)
+		}
+		cursor = page.NextCursor
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ListUserOrders(ctx, db, benchUserID, ListOptions{Cursor: cursor}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkListUserOrdersLegacyJoin 原来的实现：一次 JOIN 读取用户全部订单和商品行，用于对比
+func BenchmarkListUserOrdersLegacyJoin(b *testing.B) {
+	db := openBenchDB(b)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows, err := db.QueryContext(ctx, `
+			SELECT `+headerColumns+`, `+itemColumns+`
+			FROM orders o
+			JOIN order_items oi ON o.id = oi.order_id
+			WHERE o.user_id = ?
+			ORDER BY o.created_at DESC, oi.id ASC`, benchUserID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for rows.Next() {
+			var o models.OrderResponse
+			var line nullableItem
+			if err := rows.Scan(append(headerDest(&o), line.dest()...)...); err != nil {
+				b.Fatal(err)
+			}
+		}
+		rows.Close()
+	}
+}
+
+func BenchmarkGetOrder(b *testing.B) {
+	db := openBenchDB(b)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := GetOrder(ctx, db, benchUserID, 1+i%benchUserOrders); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetOrders100(b *testing.B) {
+	db := openBenchDB(b)
+	ctx := context.Background()
+	ids := make([]int, 100)
+	for i := range ids {
+		ids[i] = 1 + i*(benchUserOrders/len(ids))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := GetOrders(ctx, db, benchUserID, ids); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
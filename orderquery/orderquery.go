@@ -0,0 +1,312 @@
+// Package orderquery 订单读模型。列表先分页读取订单头，再用一次 WHERE order_id IN (...) 加载商品行；
+// 结果保持查询顺序，没有商品行的订单也会返回，扫描错误作为失败返回
+package orderquery
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"order-service/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 分页大小
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+var (
+	// ErrInvalidCursor 分页游标无法解析
+	ErrInvalidCursor = errors.New("invalid page cursor")
+	// ErrNotFound 订单不存在或不属于该用户
+	ErrNotFound = errors.New("order not found")
+)
+
+// Querier 由 *sql.DB 和 *sql.Tx 实现
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// ListOptions 订单列表的筛选和分页参数
+type ListOptions struct {
+	Currency string // 按货币筛选，为空时不筛选
+	PageSize int    // 0 使用默认值，超过上限时取上限
+	Cursor   string // 上一页返回的 NextCursor，为空时从第一页开始
+}
+
+// Page 一页订单，按创建时间倒序
+type Page struct {
+	Orders     []models.OrderResponse
+	NextCursor string // 为空表示没有下一页
+}
+
+const headerColumns = `o.id, o.user_id, o.subtotal, o.discount, o.coupon_code, o.tax_total, o.delivery_option,
+		       o.shipping_fee, o.total, o.currency, o.base_currency, o.exchange_rate, o.total_base,
+		       o.status, o.version, o.delivery_instructions, o.created_at`
+
+const itemColumns = `oi.id, oi.product_id, oi.product_name, oi.quantity, oi.price, oi.discount`
+
+func headerDest(o *models.OrderResponse) []interface{} {
+	return []interface{}{
+		&o.ID, &o.UserID, &o.Subtotal, &o.Discount, &o.CouponCode, &o.TaxTotal, &o.DeliveryOption,
+		&o.ShippingFee, &o.Total, &o.Currency, &o.BaseCurrency, &o.ExchangeRate, &o.TotalBase,
+		&o.Status, &o.Version, &o.DeliveryInstructions, &o.CreatedAt,
+	}
+}
+
+// ListUserOrders 按创建时间倒序分页读取用户的订单及其商品行，使用 (created_at, id) 键集分页
+func ListUserOrders(ctx context.Context, q Querier, userID int, opts ListOptions) (*Page, error) {
+	size := opts.PageSize
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+
+	query := `
+		SELECT ` + headerColumns + `
+		FROM orders o
+		WHERE o.user_id = ?`
+	args := []interface{}{userID}
+	if currencyCode := strings.ToUpper(strings.TrimSpace(opts.Currency)); currencyCode != "" {
+		query += " AND o.currency = ?"
+		args = append(args, currencyCode)
+	}
+	if opts.Cursor != "" {
+		createdAt, id, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query += " AND (o.created_at < ? OR (o.created_at = ? AND o.id < ?))"
+		args = append(args, createdAt, createdAt, id)
+	}
+	// 多取一行用于判断是否还有下一页
+	query += `
+		ORDER BY o.created_at DESC, o.id DESC
+		LIMIT ?`
+	args = append(args, size+1)
+
+	orders, err := queryHeaders(ctx, q, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Orders: orders}
+	if len(orders) > size {
+		page.Orders = orders[:size]
+		last := page.Orders[size-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	if err := attachItems(ctx, q, page.Orders); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetOrders 读取用户的多个订单及其商品行，按 orderIDs 的顺序返回，不存在的订单不出现在结果中。
+// orderIDs 不能有重复
+func GetOrders(ctx context.Context, q Querier, userID int, orderIDs []int) ([]models.OrderResponse, error) {
+	if len(orderIDs) == 0 {
+		return []models.OrderResponse{}, nil
+	}
+	args := make([]interface{}, 0, len(orderIDs)+1)
+	args = append(args, userID)
+	for _, id := range orderIDs {
+		args = append(args, id)
+	}
+	headers, err := queryHeaders(ctx, q, `
+		SELECT `+headerColumns+`
+		FROM orders o
+		WHERE o.user_id = ? AND o.id IN (`+placeholders(len(orderIDs))+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]models.OrderResponse, len(headers))
+	for _, o := range headers {
+		byID[o.ID] = o
+	}
+	orders := make([]models.OrderResponse, 0, len(headers))
+	for _, id := range orderIDs {
+		if o, ok := byID[id]; ok {
+			orders = append(orders, o)
+		}
+	}
+	if err := attachItems(ctx, q, orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// GetOrder 用一次查询读取用户的单个订单及其商品行，不包括地址快照和税行
+func GetOrder(ctx context.Context, q Querier, userID, orderID int) (*models.OrderResponse, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+headerColumns+`, `+itemColumns+`
+		FROM orders o
+		LEFT JOIN order_items oi ON oi.order_id = o.id
+		WHERE o.id = ? AND o.user_id = ?
+		ORDER BY oi.id`, orderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var order *models.OrderResponse
+	for rows.Next() {
+		var o models.OrderResponse
+		var line nullableItem
+		if err := rows.Scan(append(headerDest(&o), line.dest()...)...); err != nil {
+			return nil, err
+		}
+		if order == nil {
+			o.Items = []models.OrderItemDetail{}
+			order = &o
+		}
+		if item, ok := line.item(); ok {
+			order.Items = append(order.Items, item)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrNotFound
+	}
+	return order, nil
+}
+
+// LoadItems 用一次 IN 查询读取多个订单的商品行，按订单ID分组，组内按商品行ID排序
+func LoadItems(ctx context.Context, q Querier, orderIDs []int) (map[int][]models.OrderItemDetail, error) {
+	items := make(map[int][]models.OrderItemDetail, len(orderIDs))
+	if len(orderIDs) == 0 {
+		return items, nil
+	}
+	args := make([]interface{}, len(orderIDs))
+	for i, id := range orderIDs {
+		args[i] = id
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT oi.order_id, `+itemColumns+`
+		FROM order_items oi
+		WHERE oi.order_id IN (`+placeholders(len(orderIDs))+`)
+		ORDER BY oi.order_id, oi.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID int
+		var item models.OrderItemDetail
+		if err := rows.Scan(&orderID, &item.LineID, &item.ProductID, &item.ProductName,
+			&item.Quantity, &item.Price, &item.Discount); err != nil {
+			return nil, err
+		}
+		item.Subtotal = item.Price * float64(item.Quantity)
+		items[orderID] = append(items[orderID], item)
+	}
+	return items, rows.Err()
+}
+
+func queryHeaders(ctx context.Context, q Querier, query string, args ...interface{}) ([]models.OrderResponse, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []models.OrderResponse{}
+	for rows.Next() {
+		var o models.OrderResponse
+		if err := rows.Scan(headerDest(&o)...); err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+// attachItems 为订单加载商品行，没有商品行的订单为空切片
+func attachItems(ctx context.Context, q Querier, orders []models.OrderResponse) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	ids := make([]int, len(orders))
+	for i, o := range orders {
+		ids[i] = o.ID
+	}
+	items, err := LoadItems(ctx, q, ids)
+	if err != nil {
+		return err
+	}
+	for i := range orders {
+		orders[i].Items = items[orders[i].ID]
+		if orders[i].Items == nil {
+			orders[i].Items = []models.OrderItemDetail{}
+		}
+	}
+	return nil
+}
+
+// nullableItem LEFT JOIN 中可能为空的商品行列
+type nullableItem struct {
+	id, productID, quantity sql.NullInt64
+	productName             sql.NullString
+	price, discount         sql.NullFloat64
+}
+
+func (n *nullableItem) dest() []interface{} {
+	return []interface{}{&n.id, &n.productID, &n.productName, &n.quantity, &n.price, &n.discount}
+}
+
+func (n *nullableItem) item() (models.OrderItemDetail, bool) {
+	if !n.id.Valid {
+		return models.OrderItemDetail{}, false
+	}
+	item := models.OrderItemDetail{
+		LineID:      int(n.id.Int64),
+		ProductID:   int(n.productID.Int64),
+		ProductName: n.productName.String,
+		Quantity:    int(n.quantity.Int64),
+		Price:       n.price.Float64,
+		Discount:    n.discount.Float64,
+	}
+	item.Subtotal = item.Price * float64(item.Quantity)
+	return item, true
+}
+
+// placeholders 生成 IN 子句的占位符，如 ?,?,?
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// encodeCursor 游标为上一页最后一个订单的创建时间和ID
+func encodeCursor(createdAt time.Time, id int) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + strconv.Itoa(id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	nanos, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	orderID, err := strconv.Atoi(id)
+	if err != nil || orderID <= 0 {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return time.Unix(0, n).UTC(), orderID, nil
+}
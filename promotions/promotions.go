@@ -0,0 +1,120 @@
+package promotions
+
+import (
+	"math"
+	"order-service/apperrors"
+	"order-service/models"
+	"order-service/validation"
+	"time"
+)
+
+// 优惠券类型
+const (
+	TypePercentage = "percentage"  // 按比例折扣，Value 为百分比
+	TypeFixed      = "fixed"       // 固定金额减免，Value 为金额
+	TypeBuyXGetY   = "buy_x_get_y" // 买 X 送 Y，适用于 ProductID 指定的商品，为 0 时适用于所有商品
+)
+
+// Coupon 优惠券定义
+type Coupon struct {
+	ID             int        `json:"id"`
+	Code           string     `json:"code" binding:"required,max=50"`
+	Type           string     `json:"type" binding:"required,oneof=percentage fixed buy_x_get_y"`
+	Value          float64    `json:"value" binding:"gte=0"`
+	MinSpend       float64    `json:"min_spend" binding:"gte=0"`
+	BuyQuantity    int        `json:"buy_quantity" binding:"gte=0"`
+	GetQuantity    int        `json:"get_quantity" binding:"gte=0"`
+	ProductID      int        `json:"product_id" binding:"gte=0"`
+	MaxUsesPerUser int        `json:"max_uses_per_user" binding:"gte=0"` // 0 表示不限
+	MaxUses        int        `json:"max_uses" binding:"gte=0"`          // 0 表示不限
+	UsedCount      int        `json:"used_count"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	Active         bool       `json:"active"`
+}
+
+// Result 优惠计算结果，LineDiscounts 与订单商品行一一对应
+type Result struct {
+	LineDiscounts []float64
+	OrderDiscount float64
+}
+
+// Apply 计算优惠券对订单商品行的折扣。固定金额折扣按行金额比例分摊到各行，
+// 分摊的舍入误差计入最后一行，保证行折扣之和等于订单折扣
+func Apply(coupon *Coupon, items []models.OrderItem) Result {
+	result := Result{LineDiscounts: make([]float64, len(items))}
+
+	lineTotals := make([]float64, len(items))
+	var subtotal float64
+	for i, item := range items {
+		lineTotals[i] = item.Price * float64(item.Quantity)
+		subtotal += lineTotals[i]
+	}
+
+	switch coupon.Type {
+	case TypePercentage:
+		percent := math.Min(coupon.Value, 100)
+		for i := range items {
+			result.LineDiscounts[i] = validation.RoundMoney(lineTotals[i] * percent / 100)
+		}
+	case TypeFixed:
+		amount := math.Min(coupon.Value, subtotal)
+		var allocated float64
+		last := -1
+		for i := range items {
+			if lineTotals[i] <= 0 {
+				continue
+			}
+			result.LineDiscounts[i] = validation.RoundMoney(amount * lineTotals[i] / subtotal)
+			allocated += result.LineDiscounts[i]
+			last = i
+		}
+		if last >= 0 {
+			result.LineDiscounts[last] = validation.RoundMoney(result.LineDiscounts[last] + amount - allocated)
+		}
+	case TypeBuyXGetY:
+		group := coupon.BuyQuantity + coupon.GetQuantity
+		if coupon.BuyQuantity <= 0 || coupon.GetQuantity <= 0 {
+			break
+		}
+		for i, item := range items {
+			if coupon.ProductID != 0 && item.ProductID != coupon.ProductID {
+				continue
+			}
+			free := item.Quantity / group * coupon.GetQuantity
+			result.LineDiscounts[i] = validation.RoundMoney(float64(free) * item.Price)
+		}
+	}
+
+	for _, d := range result.LineDiscounts {
+		result.OrderDiscount += d
+	}
+	result.OrderDiscount = validation.RoundMoney(result.OrderDiscount)
+	return result
+}
+
+// Validate 校验优惠券定义
+func (c *Coupon) Validate() []apperrors.FieldError {
+	var fields []apperrors.FieldError
+	switch c.Type {
+	case TypePercentage:
+		if c.Value <= 0 || c.Value > 100 {
+			fields = append(fields, apperrors.FieldError{Field: "value", Message: "must be between 0 and 100"})
+		}
+	case TypeFixed:
+		if c.Value <= 0 {
+			fields = append(fields, apperrors.FieldError{Field: "value", Message: "must be greater than 0"})
+		}
+	case TypeBuyXGetY:
+		if c.BuyQuantity <= 0 {
+			fields = append(fields, apperrors.FieldError{Field: "buy_quantity", Message: "must be greater than 0"})
+		}
+		if c.GetQuantity <= 0 {
+			fields = append(fields, apperrors.FieldError{Field: "get_quantity", Message: "must be greater than 0"})
+		}
+	}
+	if c.StartsAt != nil && c.EndsAt != nil && !c.EndsAt.After(*c.StartsAt) {
+		fields = append(fields, apperrors.FieldError{Field: "ends_at", Message: "must be after starts_at"})
+	}
+	return fields
+}
@@ -0,0 +1,124 @@
+package promotions
+
+import (
+	"order-service/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApply(t *testing.T) {
+	items := []models.OrderItem{
+		{ProductID: 1, Quantity: 2, Price: 10},   // 20
+		{ProductID: 2, Quantity: 1, Price: 30},   // 30
+		{ProductID: 3, Quantity: 3, Price: 3.33}, // 9.99
+	}
+
+	tests := []struct {
+		name  string
+		c     Coupon
+		items []models.OrderItem
+		want  Result
+	}{
+		{
+			name:  "percentage",
+			c:     Coupon{Type: TypePercentage, Value: 10},
+			items: items,
+			want:  Result{LineDiscounts: []float64{2, 3, 1}, OrderDiscount: 6},
+		},
+		{
+			name:  "percentage capped at 100",
+			c:     Coupon{Type: TypePercentage, Value: 150},
+			items: items[:1],
+			want:  Result{LineDiscounts: []float64{20}, OrderDiscount: 20},
+		},
+		{
+			name:  "fixed split by line total",
+			c:     Coupon{Type: TypeFixed, Value: 10},
+			items: items,
+			want:  Result{LineDiscounts: []float64{3.33, 5, 1.67}, OrderDiscount: 10},
+		},
+		{
+			name: "fixed rounding residue on last line",
+			c:    Coupon{Type: TypeFixed, Value: 10},
+			items: []models.OrderItem{
+				{ProductID: 1, Quantity: 1, Price: 10},
+				{ProductID: 2, Quantity: 1, Price: 10},
+				{ProductID: 3, Quantity: 1, Price: 10},
+			},
+			want: Result{LineDiscounts: []float64{3.33, 3.33, 3.34}, OrderDiscount: 10},
+		},
+		{
+			name:  "fixed capped at subtotal",
+			c:     Coupon{Type: TypeFixed, Value: 100},
+			items: items[:2],
+			want:  Result{LineDiscounts: []float64{20, 30}, OrderDiscount: 50},
+		},
+		{
+			name: "fixed skips free lines",
+			c:    Coupon{Type: TypeFixed, Value: 5},
+			items: []models.OrderItem{
+				{ProductID: 1, Quantity: 1, Price: 10},
+				{ProductID: 2, Quantity: 1, Price: 0},
+			},
+			want: Result{LineDiscounts: []float64{5, 0}, OrderDiscount: 5},
+		},
+		{
+			name:  "buy 2 get 1 on one product",
+			c:     Coupon{Type: TypeBuyXGetY, BuyQuantity: 2, GetQuantity: 1, ProductID: 3},
+			items: items,
+			want:  Result{LineDiscounts: []float64{0, 0, 3.33}, OrderDiscount: 3.33},
+		},
+		{
+			name: "buy 1 get 1 on all products",
+			c:    Coupon{Type: TypeBuyXGetY, BuyQuantity: 1, GetQuantity: 1},
+			items: []models.OrderItem{
+				{ProductID: 1, Quantity: 5, Price: 2},
+				{ProductID: 2, Quantity: 1, Price: 7},
+			},
+			want: Result{LineDiscounts: []float64{4, 0}, OrderDiscount: 4},
+		},
+		{
+			name:  "buy x get y without quantities",
+			c:     Coupon{Type: TypeBuyXGetY},
+			items: items[:1],
+			want:  Result{LineDiscounts: []float64{0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Apply(&tt.c, tt.items)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCouponValidate(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(-time.Hour)
+
+	tests := []struct {
+		name string
+		c    Coupon
+		want []string
+	}{
+		{"valid percentage", Coupon{Type: TypePercentage, Value: 15}, nil},
+		{"percentage over 100", Coupon{Type: TypePercentage, Value: 101}, []string{"value"}},
+		{"zero fixed", Coupon{Type: TypeFixed}, []string{"value"}},
+		{"buy x get y quantities", Coupon{Type: TypeBuyXGetY}, []string{"buy_quantity", "get_quantity"}},
+		{"ends before start", Coupon{Type: TypeFixed, Value: 5, StartsAt: &start, EndsAt: &end}, []string{"ends_at"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range tt.c.Validate() {
+				got = append(got, f.Field)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package promotions
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"order-service/apperrors"
+	"strings"
+	"time"
+)
+
+const couponColumns = `id, code, type, value, min_spend, buy_quantity, get_quantity, product_id,
+	max_uses_per_user, max_uses, used_count, starts_at, ends_at, active`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCoupon(row scanner) (*Coupon, error) {
+	var c Coupon
+	var startsAt, endsAt sql.NullTime
+	if err := row.Scan(&c.ID, &c.Code, &c.Type, &c.Value, &c.MinSpend, &c.BuyQuantity, &c.GetQuantity, &c.ProductID,
+		&c.MaxUsesPerUser, &c.MaxUses, &c.UsedCount, &startsAt, &endsAt, &c.Active); err != nil {
+		return nil, err
+	}
+	if startsAt.Valid {
+		c.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		c.EndsAt = &endsAt.Time
+	}
+	return &c, nil
+}
+
+// couponError 优惠券不可用时返回的校验错误
+func couponError(message string) error {
+	return apperrors.Validation("invalid_coupon", "Coupon cannot be applied",
+		apperrors.FieldError{Field: "coupon_code", Message: message})
+}
+
+// LockCoupon 在事务中锁定并校验优惠券：是否存在、是否生效、总次数、用户使用次数和最低消费
+func LockCoupon(tx *sql.Tx, code string, userID int, subtotal float64) (*Coupon, error) {
+	coupon, err := scanCoupon(tx.QueryRow(
+		"SELECT "+couponColumns+" FROM coupons WHERE code = ? FOR UPDATE", strings.TrimSpace(code),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, couponError("does not exist")
+	}
+	if err != nil {
+		return nil, apperrors.Internal("database_error", "Failed to load coupon", err)
+	}
+
+	now := time.Now()
+	switch {
+	case !coupon.Active:
+		return nil, couponError("is not active")
+	case coupon.StartsAt != nil && now.Before(*coupon.StartsAt):
+		return nil, couponError("is not yet valid")
+	case coupon.EndsAt != nil && now.After(*coupon.EndsAt):
+		return nil, couponError("has expired")
+	case coupon.MaxUses > 0 && coupon.UsedCount >= coupon.MaxUses:
+		return nil, couponError("has been fully redeemed")
+	case subtotal < coupon.MinSpend:
+		return nil, couponError(fmt.Sprintf("requires a minimum spend of %.2f", coupon.MinSpend))
+	}
+
+	if coupon.MaxUsesPerUser > 0 {
+		var used int
+		if err := tx.QueryRow(
+			"SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = ? AND user_id = ?", coupon.ID, userID,
+		).Scan(&used); err != nil {
+			return nil, apperrors.Internal("database_error", "Failed to check coupon usage", err)
+		}
+		if used >= coupon.MaxUsesPerUser {
+			return nil, couponError("usage limit reached for this user")
+		}
+	}
+	return coupon, nil
+}
+
+// Redeem 在下单事务中记录优惠券使用
+func Redeem(tx *sql.Tx, coupon *Coupon, userID int, orderID int64, discount float64) error {
+	if _, err := tx.Exec(
+		"INSERT INTO coupon_redemptions (coupon_id, user_id, order_id, discount, redeemed_at) VALUES (?, ?, ?, ?, NOW())",
+		coupon.ID, userID, orderID, discount,
+	); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE coupons SET used_count = used_count + 1 WHERE id = ?", coupon.ID)
+	return err
+}
+
+// Release 撤销订单的优惠券核销，修改订单时先撤销再按新的商品行重新校验和核销
+func Release(tx *sql.Tx, orderID int) error {
+	var redemptionID, couponID int
+	err := tx.QueryRow(
+		"SELECT id, coupon_id FROM coupon_redemptions WHERE order_id = ? FOR UPDATE", orderID,
+	).Scan(&redemptionID, &couponID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM coupon_redemptions WHERE id = ?", redemptionID); err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE coupons SET used_count = used_count - 1 WHERE id = ? AND used_count > 0", couponID)
+	return err
+}
+
+// CreateCoupon 新建优惠券
+func CreateCoupon(db *sql.DB, c *Coupon) error {
+	result, err := db.Exec(`
+		INSERT INTO coupons (code, type, value, min_spend, buy_quantity, get_quantity, product_id,
+		                     max_uses_per_user, max_uses, used_count, starts_at, ends_at, active)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?)
+	`, c.Code, c.Type, c.Value, c.MinSpend, c.BuyQuantity, c.GetQuantity, c.ProductID,
+		c.MaxUsesPerUser, c.MaxUses, c.StartsAt, c.EndsAt, c.Active)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	c.ID = int(id)
+	return nil
+}
+
+// ListCoupons 列出所有优惠券
+func ListCoupons(db *sql.DB) ([]Coupon, error) {
+	rows, err := db.Query("SELECT " + couponColumns + " FROM coupons ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coupons := []Coupon{}
+	for rows.Next() {
+		c, err := scanCoupon(rows)
+		if err != nil {
+			return nil, err
+		}
+		coupons = append(coupons, *c)
+	}
+	return coupons, rows.Err()
+}
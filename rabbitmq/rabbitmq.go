@@ -1,8 +1,11 @@
 package rabbitmq
 
 import (
+	"encoding/json"
 	"log"
 	"order-service/config"
+	"order-service/middlewares"
+	"order-service/models"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -118,46 +121,66 @@ func (r *RabbitMQ) SetupQueues() error {
 	return nil
 }
 
-func (r *RabbitMQ) PublishOrderEvent(orderID int, priority int, eventType string) error {
-	body := []byte(string(rune(orderID)) + "|" + eventType)
+// PublishOrderEvent 发布订单事件，消息体为 JSON 编码的 models.OrderEvent
+func (r *RabbitMQ) PublishOrderEvent(event models.OrderEvent, priority int) error {
+	if event.Occurred.IsZero() {
+		event.Occurred = time.Now()
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		middlewares.RecordEventPublished(event.Type, priority, false)
+		return err
+	}
 
 	msg := amqp.Publishing{
 		DeliveryMode: amqp.Persistent,
-		Timestamp:    time.Now(),
-		ContentType:  "text/plain",
+		Timestamp:    event.Occurred,
+		ContentType:  "application/json",
+		Type:         event.Type,
 		Body:         body,
 		Priority:     uint8(priority),
 	}
 
-	return r.Channel.Publish(
+	err = r.Channel.Publish(
 		r.Cfg.OrderExchange,
 		"",
 		false, // mandatory
 		false, // immediate
 		msg,
 	)
+	middlewares.RecordEventPublished(event.Type, priority, err == nil)
+	return err
 }
 
+// PublishDelayedEvent 发布延迟事件，delay 之后才会投递到订单队列
 func (r *RabbitMQ) PublishDelayedEvent(orderID int, delay time.Duration, eventType string) error {
-	body := []byte(string(rune(orderID)) + "|" + eventType)
+	event := models.OrderEvent{OrderID: orderID, Type: eventType, Occurred: time.Now()}
+	body, err := json.Marshal(event)
+	if err != nil {
+		middlewares.RecordEventPublished(eventType, 0, false)
+		return err
+	}
 
 	msg := amqp.Publishing{
 		DeliveryMode: amqp.Persistent,
-		Timestamp:    time.Now(),
-		ContentType:  "text/plain",
+		Timestamp:    event.Occurred,
+		ContentType:  "application/json",
+		Type:         eventType,
 		Body:         body,
 		Headers: amqp.Table{
 			"x-delay": delay.Milliseconds(), // 延迟时间（毫秒）
 		},
 	}
 
-	return r.Channel.Publish(
+	err = r.Channel.Publish(
 		r.Cfg.DelayExchange,
 		"",
 		false, // mandatory
 		false, // immediate
 		msg,
 	)
+	middlewares.RecordEventPublished(eventType, 0, err == nil)
+	return err
 }
 
 func (r *RabbitMQ) Close() {
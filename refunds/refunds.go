@@ -0,0 +1,47 @@
+package refunds
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Request 退款请求。IdempotencyKey 按退货单生成，支付渠道据此去重，
+// 同一退货单的重试不会重复退款
+type Request struct {
+	OrderID        int
+	ReturnID       int
+	UserID         int
+	Amount         float64
+	Currency       string // 订单货币，ISO 4217
+	Reason         string
+	IdempotencyKey string
+}
+
+// IdempotencyKey 返回退货单的退款幂等键
+func IdempotencyKey(returnID int) string {
+	return fmt.Sprintf("return-%d", returnID)
+}
+
+// Result 退款结果，Reference 为支付渠道返回的退款单号
+type Result struct {
+	Reference  string
+	RefundedAt time.Time
+}
+
+// Refunder 退款执行接口，对接具体支付渠道时实现该接口
+type Refunder interface {
+	Refund(ctx context.Context, req Request) (Result, error)
+}
+
+// LogRefunder 只记录日志的退款实现，用于尚未接入支付渠道的环境
+type LogRefunder struct{}
+
+func (LogRefunder) Refund(_ context.Context, req Request) (Result, error) {
+	log.Printf("Refund %.2f %s for order %d (return %d, key %s)", req.Amount, req.Currency, req.OrderID, req.ReturnID, req.IdempotencyKey)
+	return Result{
+		Reference:  fmt.Sprintf("manual-%d-%d", req.OrderID, req.ReturnID),
+		RefundedAt: time.Now(),
+	}, nil
+}
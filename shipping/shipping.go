@@ -0,0 +1,145 @@
+package shipping
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// 配送方式
+const (
+	OptionStandard = "standard"
+	OptionExpress  = "express"
+	OptionPickup   = "pickup"
+)
+
+// ValidOption 判断配送方式是否有效
+func ValidOption(option string) bool {
+	switch option {
+	case OptionStandard, OptionExpress, OptionPickup:
+		return true
+	}
+	return false
+}
+
+// Destination 配送目的地，自提时为空
+type Destination struct {
+	Country string
+	State   string
+}
+
+// Item 计算运费的商品，WeightGrams 为单件重量
+type Item struct {
+	ProductID   int
+	Quantity    int
+	WeightGrams int
+}
+
+// Quote 运费报价
+type Quote struct {
+	Option        string
+	Fee           float64
+	EstimatedDays int
+}
+
+// RateProvider 运费计算接口。merchandiseTotal 为扣除优惠后的商品金额，用于判断包邮
+type RateProvider interface {
+	Quote(ctx context.Context, option string, dest Destination, items []Item, merchandiseTotal float64) (Quote, error)
+}
+
+// Rule 运费规则。Country 为空表示匹配所有地区，FreeThreshold 为 0 表示不包邮
+type Rule struct {
+	Option        string  `json:"option"`
+	Country       string  `json:"country,omitempty"`
+	BaseFee       float64 `json:"base_fee"`
+	PerKgFee      float64 `json:"per_kg_fee"` // 首公斤之后每公斤（不足一公斤按一公斤）
+	FreeThreshold float64 `json:"free_threshold,omitempty"`
+	EstimatedDays int     `json:"estimated_days"`
+}
+
+// DefaultRules 默认运费表
+var DefaultRules = []Rule{
+	{Option: OptionStandard, BaseFee: 10, PerKgFee: 2, FreeThreshold: 99, EstimatedDays: 5},
+	{Option: OptionStandard, Country: "CN", BaseFee: 8, PerKgFee: 1, FreeThreshold: 88, EstimatedDays: 3},
+	{Option: OptionExpress, BaseFee: 25, PerKgFee: 5, EstimatedDays: 2},
+	{Option: OptionExpress, Country: "CN", BaseFee: 18, PerKgFee: 3, FreeThreshold: 299, EstimatedDays: 1},
+	{Option: OptionPickup, BaseFee: 0, EstimatedDays: 1},
+}
+
+// TableRateProvider 基于规则表的运费计算，国家精确匹配优先于通配规则
+type TableRateProvider struct {
+	rules []Rule
+}
+
+// NewTableRateProvider 创建规则表运费计算器
+func NewTableRateProvider(rules []Rule) *TableRateProvider {
+	return &TableRateProvider{rules: rules}
+}
+
+// LoadTableRateProvider 从 JSON 文件加载运费规则表
+func LoadTableRateProvider(path string) (*TableRateProvider, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(content, &rules); err != nil {
+		return nil, err
+	}
+	return NewTableRateProvider(rules), nil
+}
+
+// ErrNoRate 目的地不支持该配送方式
+type ErrNoRate struct {
+	Option  string
+	Country string
+}
+
+func (e *ErrNoRate) Error() string {
+	return fmt.Sprintf("no %s shipping rate for %q", e.Option, e.Country)
+}
+
+func (p *TableRateProvider) Quote(_ context.Context, option string, dest Destination, items []Item, merchandiseTotal float64) (Quote, error) {
+	rule, ok := p.match(option, dest.Country)
+	if !ok {
+		return Quote{}, &ErrNoRate{Option: option, Country: dest.Country}
+	}
+
+	quote := Quote{Option: option, EstimatedDays: rule.EstimatedDays}
+	if rule.FreeThreshold > 0 && merchandiseTotal >= rule.FreeThreshold {
+		return quote, nil
+	}
+
+	var grams int
+	for _, item := range items {
+		grams += item.WeightGrams * item.Quantity
+	}
+	extraKg := math.Max(0, math.Ceil(float64(grams)/1000)-1)
+	quote.Fee = math.Round((rule.BaseFee+extraKg*rule.PerKgFee)*100) / 100
+	return quote, nil
+}
+
+func (p *TableRateProvider) match(option, country string) (Rule, bool) {
+	var fallback *Rule
+	for i, rule := range p.rules {
+		if rule.Option != option {
+			continue
+		}
+		if rule.Country == "" {
+			if fallback == nil {
+				fallback = &p.rules[i]
+			}
+			continue
+		}
+		if strings.EqualFold(rule.Country, country) {
+			return rule, true
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return Rule{}, false
+}
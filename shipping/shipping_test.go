@@ -0,0 +1,100 @@
+package shipping
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTableRateProviderQuote(t *testing.T) {
+	provider := NewTableRateProvider(DefaultRules)
+
+	tests := []struct {
+		name     string
+		option   string
+		dest     Destination
+		items    []Item
+		total    float64
+		wantFee  float64
+		wantDays int
+	}{
+		{
+			name:     "country rule, first kilogram",
+			option:   OptionStandard,
+			dest:     Destination{Country: "CN"},
+			items:    []Item{{Quantity: 2, WeightGrams: 500}},
+			total:    50,
+			wantFee:  8,
+			wantDays: 3,
+		},
+		{
+			name:     "extra kilograms round up",
+			option:   OptionStandard,
+			dest:     Destination{Country: "cn"},
+			items:    []Item{{Quantity: 3, WeightGrams: 700}},
+			total:    50,
+			wantFee:  10,
+			wantDays: 3,
+		},
+		{
+			name:     "free over threshold",
+			option:   OptionStandard,
+			dest:     Destination{Country: "CN"},
+			items:    []Item{{Quantity: 10, WeightGrams: 1000}},
+			total:    88,
+			wantFee:  0,
+			wantDays: 3,
+		},
+		{
+			name:     "fallback rule for other countries",
+			option:   OptionExpress,
+			dest:     Destination{Country: "US"},
+			items:    []Item{{Quantity: 1, WeightGrams: 2500}},
+			total:    1000,
+			wantFee:  35,
+			wantDays: 2,
+		},
+		{
+			name:     "pickup without destination",
+			option:   OptionPickup,
+			items:    []Item{{Quantity: 5, WeightGrams: 5000}},
+			total:    10,
+			wantFee:  0,
+			wantDays: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := provider.Quote(context.Background(), tt.option, tt.dest, tt.items, tt.total)
+			if err != nil {
+				t.Fatalf("Quote: %v", err)
+			}
+			if quote.Fee != tt.wantFee || quote.EstimatedDays != tt.wantDays || quote.Option != tt.option {
+				t.Errorf("Quote() = %+v, want fee %v in %d days", quote, tt.wantFee, tt.wantDays)
+			}
+		})
+	}
+}
+
+func TestTableRateProviderNoRate(t *testing.T) {
+	provider := NewTableRateProvider([]Rule{{Option: OptionExpress, Country: "CN", BaseFee: 18}})
+	_, err := provider.Quote(context.Background(), OptionExpress, Destination{Country: "US"}, nil, 0)
+	var noRate *ErrNoRate
+	if !errors.As(err, &noRate) || noRate.Country != "US" || noRate.Option != OptionExpress {
+		t.Errorf("Quote() error = %v, want ErrNoRate for express to US", err)
+	}
+}
+
+func TestValidOption(t *testing.T) {
+	for option, want := range map[string]bool{
+		OptionStandard: true,
+		OptionExpress:  true,
+		OptionPickup:   true,
+		"drone":        false,
+		"":             false,
+	} {
+		if got := ValidOption(option); got != want {
+			t.Errorf("ValidOption(%q) = %v, want %v", option, got, want)
+		}
+	}
+}
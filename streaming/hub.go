@@ -0,0 +1,95 @@
+package streaming
+
+import (
+	"encoding/json"
+	"log"
+	"order-service/models"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Subscription 一个 SSE 连接的订阅。OrderID 为 0 时订阅用户的所有订单。
+// Wake 只是通知信号，连接收到后从状态历史表读取新的记录
+type Subscription struct {
+	UserID  int
+	OrderID int
+	Wake    chan struct{}
+}
+
+// Hub 按用户和订单将订单事件分发给本实例上的 SSE 连接
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe 注册订阅，调用方结束时必须调用 Unsubscribe
+func (h *Hub) Subscribe(userID, orderID int) *Subscription {
+	sub := &Subscription{UserID: userID, OrderID: orderID, Wake: make(chan struct{}, 1)}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	h.mu.Unlock()
+}
+
+// Notify 唤醒与事件相关的订阅。userID 为 0 时（如延迟事件）唤醒所有订阅该订单的连接
+// 和所有用户级连接，由连接查询时按用户过滤
+func (h *Hub) Notify(userID, orderID int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subs {
+		if userID != 0 && sub.UserID != userID {
+			continue
+		}
+		if sub.OrderID != 0 && sub.OrderID != orderID {
+			continue
+		}
+		// 缓冲为 1，连接尚未处理上一次通知时合并
+		select {
+		case sub.Wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// StartFanout 声明绑定到订单交换机的独占队列（服务端命名、连接断开后自动删除），
+// 每个实例各自接收全部订单事件并唤醒本实例的连接
+func (h *Hub) StartFanout(conn *amqp.Connection, exchange string) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	queue, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return err
+	}
+	if err := ch.QueueBind(queue.Name, "", exchange, false, nil); err != nil {
+		return err
+	}
+	msgs, err := ch.Consume(queue.Name, "order-service-stream", true, true, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for msg := range msgs {
+			var event models.OrderEvent
+			if err := json.Unmarshal(msg.Body, &event); err != nil || event.OrderID <= 0 {
+				continue
+			}
+			h.Notify(event.UserID, event.OrderID)
+		}
+		log.Printf("Order event stream fan-out stopped")
+	}()
+	return nil
+}
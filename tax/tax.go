@@ -0,0 +1,144 @@
+package tax
+
+import (
+	"context"
+	"encoding/json"
+	"order-service/validation"
+	"os"
+	"strings"
+)
+
+// DefaultTaxClass 未配置税类的商品使用的税类
+const DefaultTaxClass = "standard"
+
+// Destination 计税地区，取自收货地址
+type Destination struct {
+	Country string
+	State   string
+}
+
+// Line 待计税的商品行，Amount 为扣除优惠后的行金额
+type Line struct {
+	ProductID int
+	TaxClass  string
+	Amount    float64
+}
+
+// LineTax 商品行的税额。Inclusive 为 true 时税额已包含在价格中，不计入应付总额
+type LineTax struct {
+	ProductID     int     `json:"product_id"`
+	TaxClass      string  `json:"tax_class"`
+	Jurisdiction  string  `json:"jurisdiction"`
+	Rate          float64 `json:"rate"`
+	TaxableAmount float64 `json:"taxable_amount"`
+	Amount        float64 `json:"amount"`
+	Inclusive     bool    `json:"inclusive"`
+}
+
+// Calculator 税费计算接口，返回的税行与 lines 一一对应
+type Calculator interface {
+	Calculate(ctx context.Context, dest Destination, lines []Line) ([]LineTax, error)
+}
+
+// Rule 税率规则。State 和 TaxClass 为空表示匹配所有
+type Rule struct {
+	Name      string  `json:"name"`
+	Country   string  `json:"country"`
+	State     string  `json:"state,omitempty"`
+	TaxClass  string  `json:"tax_class,omitempty"`
+	Rate      float64 `json:"rate"` // 如 0.13 表示 13%
+	Inclusive bool    `json:"inclusive"`
+}
+
+// TableCalculator 基于规则表的税费计算，按地区和税类选择最具体的规则
+type TableCalculator struct {
+	rules []Rule
+}
+
+// NewTableCalculator 创建规则表计算器
+func NewTableCalculator(rules []Rule) *TableCalculator {
+	return &TableCalculator{rules: rules}
+}
+
+// LoadTableCalculator 从 JSON 文件加载规则表
+func LoadTableCalculator(path string) (*TableCalculator, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(content, &rules); err != nil {
+		return nil, err
+	}
+	return NewTableCalculator(rules), nil
+}
+
+// DefaultRules 默认税率表：中国大陆增值税价内含税，美国各州销售税价外计税
+var DefaultRules = []Rule{
+	{Name: "CN VAT", Country: "CN", Rate: 0.13, Inclusive: true},
+	{Name: "CN VAT (reduced)", Country: "CN", TaxClass: "reduced", Rate: 0.09, Inclusive: true},
+	{Name: "CN VAT (exempt)", Country: "CN", TaxClass: "exempt", Rate: 0, Inclusive: true},
+	{Name: "DE VAT", Country: "DE", Rate: 0.19, Inclusive: true},
+	{Name: "DE VAT (reduced)", Country: "DE", TaxClass: "reduced", Rate: 0.07, Inclusive: true},
+	{Name: "FR VAT", Country: "FR", Rate: 0.20, Inclusive: true},
+	{Name: "GB VAT", Country: "GB", Rate: 0.20, Inclusive: true},
+	{Name: "JP consumption tax", Country: "JP", Rate: 0.10, Inclusive: true},
+	{Name: "CA sales tax", Country: "CA", Rate: 0.05},
+	{Name: "CA-ON HST", Country: "CA", State: "ON", Rate: 0.13},
+	{Name: "US-CA sales tax", Country: "US", State: "CA", Rate: 0.0725},
+	{Name: "US-NY sales tax", Country: "US", State: "NY", Rate: 0.04},
+	{Name: "US-WA sales tax", Country: "US", State: "WA", Rate: 0.065},
+}
+
+func (t *TableCalculator) Calculate(_ context.Context, dest Destination, lines []Line) ([]LineTax, error) {
+	taxes := make([]LineTax, len(lines))
+	for i, line := range lines {
+		taxClass := line.TaxClass
+		if taxClass == "" {
+			taxClass = DefaultTaxClass
+		}
+		taxes[i] = LineTax{ProductID: line.ProductID, TaxClass: taxClass, TaxableAmount: line.Amount}
+
+		rule, ok := t.match(dest, taxClass)
+		if !ok {
+			continue
+		}
+		taxes[i].Jurisdiction = rule.Name
+		taxes[i].Rate = rule.Rate
+		taxes[i].Inclusive = rule.Inclusive
+		if rule.Inclusive {
+			// 价内税：从含税金额中拆出税额
+			taxes[i].Amount = validation.RoundMoney(line.Amount - line.Amount/(1+rule.Rate))
+		} else {
+			taxes[i].Amount = validation.RoundMoney(line.Amount * rule.Rate)
+		}
+	}
+	return taxes, nil
+}
+
+// match 选择匹配度最高的规则：州和税类精确匹配优先于通配
+func (t *TableCalculator) match(dest Destination, taxClass string) (Rule, bool) {
+	best, bestScore := Rule{}, -1
+	for _, rule := range t.rules {
+		if !strings.EqualFold(rule.Country, dest.Country) {
+			continue
+		}
+		score := 0
+		if rule.State != "" {
+			if !strings.EqualFold(rule.State, dest.State) {
+				continue
+			}
+			score += 2
+		}
+		if rule.TaxClass != "" {
+			if rule.TaxClass != taxClass {
+				continue
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = rule, score
+		}
+	}
+	return best, bestScore >= 0
+}
@@ -0,0 +1,90 @@
+package tax
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTableCalculatorCalculate(t *testing.T) {
+	calc := NewTableCalculator(DefaultRules)
+
+	tests := []struct {
+		name string
+		dest Destination
+		line Line
+		want LineTax
+	}{
+		{
+			name: "inclusive vat split out of the price",
+			dest: Destination{Country: "CN"},
+			line: Line{ProductID: 1, Amount: 113},
+			want: LineTax{ProductID: 1, TaxClass: DefaultTaxClass, Jurisdiction: "CN VAT", Rate: 0.13, TaxableAmount: 113, Amount: 13, Inclusive: true},
+		},
+		{
+			name: "tax class beats country default",
+			dest: Destination{Country: "cn"},
+			line: Line{ProductID: 2, TaxClass: "reduced", Amount: 109},
+			want: LineTax{ProductID: 2, TaxClass: "reduced", Jurisdiction: "CN VAT (reduced)", Rate: 0.09, TaxableAmount: 109, Amount: 9, Inclusive: true},
+		},
+		{
+			name: "exclusive state sales tax",
+			dest: Destination{Country: "US", State: "ca"},
+			line: Line{ProductID: 3, Amount: 100},
+			want: LineTax{ProductID: 3, TaxClass: DefaultTaxClass, Jurisdiction: "US-CA sales tax", Rate: 0.0725, TaxableAmount: 100, Amount: 7.25},
+		},
+		{
+			name: "state rule beats country rule",
+			dest: Destination{Country: "CA", State: "ON"},
+			line: Line{ProductID: 4, Amount: 10},
+			want: LineTax{ProductID: 4, TaxClass: DefaultTaxClass, Jurisdiction: "CA-ON HST", Rate: 0.13, TaxableAmount: 10, Amount: 1.3},
+		},
+		{
+			name: "country rule for other states",
+			dest: Destination{Country: "CA", State: "BC"},
+			line: Line{ProductID: 5, Amount: 10},
+			want: LineTax{ProductID: 5, TaxClass: DefaultTaxClass, Jurisdiction: "CA sales tax", Rate: 0.05, TaxableAmount: 10, Amount: 0.5},
+		},
+		{
+			name: "no rule",
+			dest: Destination{Country: "US", State: "OR"},
+			line: Line{ProductID: 6, Amount: 10},
+			want: LineTax{ProductID: 6, TaxClass: DefaultTaxClass, TaxableAmount: 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := calc.Calculate(context.Background(), tt.dest, []Line{tt.line})
+			if err != nil {
+				t.Fatalf("Calculate: %v", err)
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("Calculate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTableCalculator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	rules := `[{"name":"Test","country":"XX","rate":0.1}]`
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	calc, err := LoadTableCalculator(path)
+	if err != nil {
+		t.Fatalf("LoadTableCalculator: %v", err)
+	}
+	got, _ := calc.Calculate(context.Background(), Destination{Country: "XX"}, []Line{{Amount: 20}})
+	if got[0].Amount != 2 || got[0].Jurisdiction != "Test" {
+		t.Errorf("Calculate() = %+v, want 2.00 from Test", got[0])
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTableCalculator(path); err == nil {
+		t.Error("expected error for malformed rules")
+	}
+}
@@ -1,24 +1,38 @@
 package utils
 
 import (
+	"errors"
+
 	"github.com/golang-jwt/jwt/v5"
-	"order-service/config"
 )
 
+var jwtSecret []byte
+
+// SetJWTSecret 设置校验令牌的密钥，启动时由配置注入
+func SetJWTSecret(secret string) {
+	jwtSecret = []byte(secret)
+}
+
 func ParseToken(tokenString string) (int, error) {
-	cfg := config.LoadConfig()
+	if len(jwtSecret) == 0 {
+		return 0, errors.New("JWT secret not configured")
+	}
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(cfg.JWTSecret), nil
+		return jwtSecret, nil
 	})
 
 	if err != nil || !token.Valid {
 		return 0, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
-		userID := int(claims["user_id"].(float64))
-		return userID, nil
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, errors.New("unexpected token claims")
 	}
-
-	return 0, err
+	// JSON 数字解码为 float64，缺少或类型不对的 user_id 视为无效令牌
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, errors.New("token has no numeric user_id")
+	}
+	return int(userID), nil
 }
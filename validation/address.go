@@ -0,0 +1,106 @@
+package validation
+
+import (
+	"order-service/apperrors"
+	"order-service/models"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// countryFormat 各国家/地区的地址格式要求
+type countryFormat struct {
+	postalCode    *regexp.Regexp // 为 nil 表示不要求邮编
+	stateRequired bool
+}
+
+var countryFormats = map[string]countryFormat{
+	"CN": {postalCode: regexp.MustCompile(`^\d{6}$`), stateRequired: true},
+	"US": {postalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`), stateRequired: true},
+	"CA": {postalCode: regexp.MustCompile(`^[A-Za-z]\d[A-Za-z] ?\d[A-Za-z]\d$`), stateRequired: true},
+	"GB": {postalCode: regexp.MustCompile(`^[A-Za-z]{1,2}\d[A-Za-z\d]? ?\d[A-Za-z]{2}$`)},
+	"DE": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"FR": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"JP": {postalCode: regexp.MustCompile(`^\d{3}-?\d{4}$`), stateRequired: true},
+	"SG": {postalCode: regexp.MustCompile(`^\d{6}$`)},
+	"HK": {},
+}
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9][0-9 \-]{5,19}$`)
+
+const (
+	maxAddressFieldLength       = 200
+	maxDeliveryInstructionsSize = 500
+)
+
+// ValidateAddress 按国家格式校验地址，返回以 prefix 为前缀的字段错误
+func ValidateAddress(prefix string, addr *models.Address) []apperrors.FieldError {
+	var fields []apperrors.FieldError
+	addError := func(field, message string) {
+		fields = append(fields, apperrors.FieldError{Field: prefix + "." + field, Message: message})
+	}
+
+	trimAddress(addr)
+	addr.Country = strings.ToUpper(addr.Country)
+
+	for _, f := range []struct {
+		name     string
+		value    string
+		required bool
+	}{
+		{"recipient_name", addr.RecipientName, true},
+		{"phone", addr.Phone, true},
+		{"line1", addr.Line1, true},
+		{"line2", addr.Line2, false},
+		{"city", addr.City, true},
+		{"state", addr.State, false},
+		{"country", addr.Country, true},
+	} {
+		if f.required && f.value == "" {
+			addError(f.name, "is required")
+		} else if utf8.RuneCountInString(f.value) > maxAddressFieldLength {
+			addError(f.name, "is too long")
+		}
+	}
+
+	if addr.Phone != "" && !phonePattern.MatchString(addr.Phone) {
+		addError("phone", "is not a valid phone number")
+	}
+
+	if addr.Country == "" {
+		return fields
+	}
+	format, ok := countryFormats[addr.Country]
+	if !ok {
+		addError("country", "is not a supported shipping country")
+		return fields
+	}
+	if format.stateRequired && addr.State == "" {
+		addError("state", "is required for "+addr.Country)
+	}
+	if format.postalCode != nil {
+		if addr.PostalCode == "" {
+			addError("postal_code", "is required for "+addr.Country)
+		} else if !format.postalCode.MatchString(addr.PostalCode) {
+			addError("postal_code", "is not a valid postal code for "+addr.Country)
+		}
+	}
+	return fields
+}
+
+// ValidateDeliveryInstructions 校验配送备注长度
+func ValidateDeliveryInstructions(instructions string) []apperrors.FieldError {
+	if utf8.RuneCountInString(instructions) > maxDeliveryInstructionsSize {
+		return []apperrors.FieldError{{Field: "delivery_instructions", Message: "is too long"}}
+	}
+	return nil
+}
+
+func trimAddress(addr *models.Address) {
+	for _, field := range []*string{
+		&addr.RecipientName, &addr.Phone, &addr.Line1, &addr.Line2,
+		&addr.City, &addr.State, &addr.PostalCode, &addr.Country,
+	} {
+		*field = strings.TrimSpace(*field)
+	}
+}
@@ -0,0 +1,115 @@
+package validation
+
+import (
+	"order-service/apperrors"
+	"order-service/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateAddress(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(a *models.Address)
+		want   []apperrors.FieldError
+	}{
+		{
+			name:   "valid",
+			modify: func(a *models.Address) {},
+		},
+		{
+			name: "required fields",
+			modify: func(a *models.Address) {
+				a.RecipientName, a.Phone, a.Line1, a.City = " ", "", "", ""
+			},
+			want: []apperrors.FieldError{
+				{Field: "shipping_address.recipient_name", Message: "is required"},
+				{Field: "shipping_address.phone", Message: "is required"},
+				{Field: "shipping_address.line1", Message: "is required"},
+				{Field: "shipping_address.city", Message: "is required"},
+			},
+		},
+		{
+			name:   "field too long",
+			modify: func(a *models.Address) { a.Line2 = strings.Repeat("路", maxAddressFieldLength+1) },
+			want:   []apperrors.FieldError{{Field: "shipping_address.line2", Message: "is too long"}},
+		},
+		{
+			name:   "invalid phone",
+			modify: func(a *models.Address) { a.Phone = "call me" },
+			want:   []apperrors.FieldError{{Field: "shipping_address.phone", Message: "is not a valid phone number"}},
+		},
+		{
+			name:   "unsupported country",
+			modify: func(a *models.Address) { a.Country = "ZZ" },
+			want:   []apperrors.FieldError{{Field: "shipping_address.country", Message: "is not a supported shipping country"}},
+		},
+		{
+			name:   "state required",
+			modify: func(a *models.Address) { a.State = "" },
+			want:   []apperrors.FieldError{{Field: "shipping_address.state", Message: "is required for CN"}},
+		},
+		{
+			name:   "postal code required",
+			modify: func(a *models.Address) { a.PostalCode = "" },
+			want:   []apperrors.FieldError{{Field: "shipping_address.postal_code", Message: "is required for CN"}},
+		},
+		{
+			name:   "postal code format",
+			modify: func(a *models.Address) { a.PostalCode = "2000" },
+			want:   []apperrors.FieldError{{Field: "shipping_address.postal_code", Message: "is not a valid postal code for CN"}},
+		},
+		{
+			name: "country without postal code or state",
+			modify: func(a *models.Address) {
+				a.Country, a.State, a.PostalCode = "hk", "", ""
+			},
+		},
+		{
+			name: "us zip+4",
+			modify: func(a *models.Address) {
+				a.Country, a.State, a.PostalCode = "US", "CA", "94105-1234"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := testAddress()
+			tt.modify(addr)
+			got := ValidateAddress("shipping_address", addr)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateAddress() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAddressNormalizes(t *testing.T) {
+	addr := testAddress()
+	addr.City = "  上海 "
+	addr.Country = " cn"
+	if fields := ValidateAddress("a", addr); len(fields) != 0 {
+		t.Fatalf("unexpected errors: %+v", fields)
+	}
+	if addr.City != "上海" || addr.Country != "CN" {
+		t.Errorf("address not normalized: city=%q country=%q", addr.City, addr.Country)
+	}
+}
+
+func TestValidateDeliveryInstructions(t *testing.T) {
+	tests := []struct {
+		instructions string
+		wantErr      bool
+	}{
+		{"", false},
+		{"放门口", false},
+		{strings.Repeat("放", maxDeliveryInstructionsSize), false},
+		{strings.Repeat("放", maxDeliveryInstructionsSize+1), true},
+	}
+	for _, tt := range tests {
+		if got := ValidateDeliveryInstructions(tt.instructions); (got != nil) != tt.wantErr {
+			t.Errorf("ValidateDeliveryInstructions(%d runes) = %v, want error %v", len([]rune(tt.instructions)), got, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"order-service/apperrors"
+	"order-service/models"
+	"order-service/shipping"
+	"strings"
+	"unicode/utf8"
+)
+
+// OrderRules 订单创建的校验规则
+type OrderRules struct {
+	MaxLines             int  // 每个订单最多的商品行数
+	MaxLineQuantity      int  // 每行最大购买数量
+	MaxProductNameLength int  // 商品名称最大长度（字符）
+	MergeDuplicateLines  bool // 为 true 时合并相同商品的行，否则拒绝
+	DefaultCurrency      string
+	Currencies           []string // 支持的下单货币
+	// 为 true 时非自提订单必须提供收货地址。v1 接口保持可选以兼容旧客户端，只有 v2 要求
+	RequireShippingAddress bool
+}
+
+// DefaultOrderRules 默认校验规则
+var DefaultOrderRules = OrderRules{
+	MaxLines:             50,
+	MaxLineQuantity:      100,
+	MaxProductNameLength: 200,
+	MergeDuplicateLines:  true,
+	DefaultCurrency:      "CNY",
+	Currencies:           []string{"CNY"},
+}
+
+// WithDefaults 未设置（为零）的限制使用 DefaultOrderRules 中的值，
+// 避免漏配时所有订单都无法通过校验
+func (r OrderRules) WithDefaults() OrderRules {
+	if r.MaxLines <= 0 {
+		r.MaxLines = DefaultOrderRules.MaxLines
+	}
+	if r.MaxLineQuantity <= 0 {
+		r.MaxLineQuantity = DefaultOrderRules.MaxLineQuantity
+	}
+	if r.MaxProductNameLength <= 0 {
+		r.MaxProductNameLength = DefaultOrderRules.MaxProductNameLength
+	}
+	if r.DefaultCurrency == "" {
+		r.DefaultCurrency = DefaultOrderRules.DefaultCurrency
+	}
+	if len(r.Currencies) == 0 {
+		r.Currencies = []string{r.DefaultCurrency}
+	}
+	return r
+}
+
+// NormalizeOrder 校验并规范化新订单：合并重复商品行，校验数量、价格、名称、总价和地址，
+// 所有错误一次性按字段路径返回
+func NormalizeOrder(order *models.Order, rules OrderRules) error {
+	var fields []apperrors.FieldError
+	addError := func(field, format string, args ...interface{}) {
+		fields = append(fields, apperrors.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(order.Items) == 0 {
+		addError("items", "must contain at least one item")
+	}
+
+	for i, item := range order.Items {
+		path := fmt.Sprintf("items[%d]", i)
+		if item.ProductID <= 0 {
+			addError(path+".product_id", "must be a positive integer")
+		}
+		name := strings.TrimSpace(item.ProductName)
+		if name == "" {
+			addError(path+".product_name", "is required")
+		} else if utf8.RuneCountInString(name) > rules.MaxProductNameLength {
+			addError(path+".product_name", "must be at most %d characters", rules.MaxProductNameLength)
+		}
+		if item.Quantity <= 0 {
+			addError(path+".quantity", "must be greater than 0")
+		} else if item.Quantity > rules.MaxLineQuantity {
+			addError(path+".quantity", "must be at most %d", rules.MaxLineQuantity)
+		}
+		// 价格以商品目录为准，客户端可以不提供（0），提供时必须与目录一致
+		if item.Price < 0 || math.IsNaN(item.Price) || math.IsInf(item.Price, 0) {
+			addError(path+".price", "must not be negative")
+		}
+		order.Items[i].ProductName = name
+	}
+
+	// 处理重复商品行
+	merged := make([]models.OrderItem, 0, len(order.Items))
+	seen := make(map[int]int) // product_id -> merged 下标
+	for i, item := range order.Items {
+		idx, exists := seen[item.ProductID]
+		if !exists {
+			seen[item.ProductID] = len(merged)
+			merged = append(merged, item)
+			continue
+		}
+		path := fmt.Sprintf("items[%d].product_id", i)
+		if !rules.MergeDuplicateLines {
+			addError(path, "duplicates a previous line")
+			continue
+		}
+		first := merged[idx]
+		if first.Price != item.Price || first.ProductName != item.ProductName {
+			addError(path, "duplicates a previous line with a different name or price")
+			continue
+		}
+		merged[idx].Quantity += item.Quantity
+		if merged[idx].Quantity > rules.MaxLineQuantity {
+			addError(path, "merged quantity must be at most %d", rules.MaxLineQuantity)
+		}
+	}
+
+	fields = append(fields, validateOrderAddresses(order, rules.RequireShippingAddress)...)
+
+	order.Currency = strings.ToUpper(strings.TrimSpace(order.Currency))
+	if order.Currency == "" {
+		order.Currency = rules.DefaultCurrency
+	}
+	if !containsString(rules.Currencies, order.Currency) {
+		addError("currency", "must be one of: %s", strings.Join(rules.Currencies, " "))
+	}
+
+	if len(merged) > rules.MaxLines {
+		addError("items", "must contain at most %d lines", rules.MaxLines)
+	}
+
+	// 客户端提供的总价必须与商品行一致
+	var total float64
+	allPriced := true
+	for _, item := range merged {
+		total += item.Price * float64(item.Quantity)
+		allPriced = allPriced && item.Price > 0
+	}
+	if order.Total != 0 && allPriced && RoundMoney(order.Total) != RoundMoney(total) {
+		addError("total", "does not match the sum of item lines (%.2f)", RoundMoney(total))
+	}
+
+	if len(fields) > 0 {
+		return apperrors.Validation("validation_failed", "Order validation failed", fields...)
+	}
+
+	order.Items = merged
+	order.Total = RoundMoney(total)
+	order.CouponCode = strings.TrimSpace(order.CouponCode)
+	return nil
+}
+
+// ApplyItemChanges 将商品行修改应用到订单现有商品行，返回新的商品行和数量变化。
+// 修改后的商品行价格清零，由调用方按商品目录重新定价
+func ApplyItemChanges(items []models.OrderItem, updates []models.OrderItemUpdate, rules OrderRules) ([]models.OrderItem, []models.OrderItemChange, error) {
+	var fields []apperrors.FieldError
+	addError := func(field, format string, args ...interface{}) {
+		fields = append(fields, apperrors.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	result := make([]models.OrderItem, len(items))
+	copy(result, items)
+	index := make(map[int]int, len(result)) // product_id -> result 下标
+	for i, item := range result {
+		index[item.ProductID] = i
+	}
+
+	var changes []models.OrderItemChange
+	seen := make(map[int]bool)
+	for i, update := range updates {
+		path := fmt.Sprintf("items[%d]", i)
+		if seen[update.ProductID] {
+			addError(path+".product_id", "duplicates a previous line")
+			continue
+		}
+		seen[update.ProductID] = true
+
+		quantity := 0
+		if update.Quantity != nil {
+			quantity = *update.Quantity
+		}
+		if quantity > rules.MaxLineQuantity {
+			addError(path+".quantity", "must be at most %d", rules.MaxLineQuantity)
+			continue
+		}
+
+		idx, exists := index[update.ProductID]
+		if !exists {
+			if quantity == 0 {
+				addError(path+".product_id", "is not part of this order")
+				continue
+			}
+			name := strings.TrimSpace(update.ProductName)
+			if name == "" {
+				addError(path+".product_name", "is required for a new item")
+				continue
+			}
+			if utf8.RuneCountInString(name) > rules.MaxProductNameLength {
+				addError(path+".product_name", "must be at most %d characters", rules.MaxProductNameLength)
+				continue
+			}
+			index[update.ProductID] = len(result)
+			result = append(result, models.OrderItem{ProductID: update.ProductID, ProductName: name, Quantity: quantity})
+			changes = append(changes, models.OrderItemChange{ProductID: update.ProductID, ProductName: name, NewQuantity: quantity})
+			continue
+		}
+
+		current := result[idx]
+		if current.Quantity == quantity {
+			continue
+		}
+		changes = append(changes, models.OrderItemChange{
+			ProductID:   current.ProductID,
+			ProductName: current.ProductName,
+			OldQuantity: current.Quantity,
+			NewQuantity: quantity,
+		})
+		result[idx].Quantity = quantity
+	}
+
+	remaining := make([]models.OrderItem, 0, len(result))
+	for _, item := range result {
+		if item.Quantity > 0 {
+			item.Price = 0
+			remaining = append(remaining, item)
+		}
+	}
+	if len(fields) == 0 {
+		switch {
+		case len(changes) == 0:
+			addError("items", "does not change the order")
+		case len(remaining) == 0:
+			addError("items", "must leave at least one item, cancel the order instead")
+		case len(remaining) > rules.MaxLines:
+			addError("items", "must contain at most %d lines", rules.MaxLines)
+		}
+	}
+
+	if len(fields) > 0 {
+		return nil, nil, apperrors.Validation("validation_failed", "Order item changes are invalid", fields...)
+	}
+	return remaining, changes, nil
+}
+
+// RoundMoney 金额保留两位小数
+func RoundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// validateOrderAddresses 收货地址为地址快照或地址簿引用二选一，requireShipping 为 true 时
+// 非自提订单必须提供；账单地址可选
+func validateOrderAddresses(order *models.Order, requireShipping bool) []apperrors.FieldError {
+	var fields []apperrors.FieldError
+
+	order.DeliveryOption = strings.TrimSpace(order.DeliveryOption)
+	if order.DeliveryOption == "" {
+		order.DeliveryOption = shipping.OptionStandard
+	}
+	if !shipping.ValidOption(order.DeliveryOption) {
+		fields = append(fields, apperrors.FieldError{Field: "delivery_option", Message: "must be one of: standard express pickup"})
+	}
+
+	switch {
+	case order.ShippingAddress != nil && order.ShippingAddressID != nil:
+		fields = append(fields, apperrors.FieldError{Field: "shipping_address_id", Message: "cannot be combined with shipping_address"})
+	case order.ShippingAddress != nil:
+		fields = append(fields, ValidateAddress("shipping_address", order.ShippingAddress)...)
+	case requireShipping && order.ShippingAddressID == nil && order.DeliveryOption != shipping.OptionPickup:
+		fields = append(fields, apperrors.FieldError{Field: "shipping_address", Message: "is required"})
+	}
+
+	switch {
+	case order.BillingAddress != nil && order.BillingAddressID != nil:
+		fields = append(fields, apperrors.FieldError{Field: "billing_address_id", Message: "cannot be combined with billing_address"})
+	case order.BillingAddress != nil:
+		fields = append(fields, ValidateAddress("billing_address", order.BillingAddress)...)
+	}
+
+	order.DeliveryInstructions = strings.TrimSpace(order.DeliveryInstructions)
+	fields = append(fields, ValidateDeliveryInstructions(order.DeliveryInstructions)...)
+	return fields
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,336 @@
+package validation
+
+import (
+	"errors"
+	"order-service/apperrors"
+	"order-service/models"
+	"reflect"
+	"testing"
+)
+
+func testAddress() *models.Address {
+	return &models.Address{
+		RecipientName: "张三",
+		Phone:         "13800000000",
+		Line1:         "人民路 1 号",
+		City:          "上海",
+		State:         "上海",
+		PostalCode:    "200000",
+		Country:       "cn",
+	}
+}
+
+func testOrder(items ...models.OrderItem) *models.Order {
+	return &models.Order{Items: items, ShippingAddress: testAddress()}
+}
+
+// fieldsOf 返回校验错误中的字段路径，err 为 nil 时返回 nil
+func fieldsOf(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) || appErr.Kind != apperrors.KindValidation {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	fields := make([]string, 0, len(appErr.Fields))
+	for _, f := range appErr.Fields {
+		fields = append(fields, f.Field)
+	}
+	return fields
+}
+
+func TestNormalizeOrder(t *testing.T) {
+	strict := DefaultOrderRules
+	strict.MergeDuplicateLines = false
+	small := DefaultOrderRules
+	small.MaxLines = 1
+	small.MaxLineQuantity = 3
+	small.MaxProductNameLength = 4
+	v2 := DefaultOrderRules
+	v2.RequireShippingAddress = true
+
+	tests := []struct {
+		name       string
+		order      *models.Order
+		rules      OrderRules
+		wantFields []string
+		wantItems  []models.OrderItem
+		wantTotal  float64
+	}{
+		{
+			name:      "valid order",
+			order:     testOrder(models.OrderItem{ProductID: 1, ProductName: " Pen ", Quantity: 2, Price: 1.5}),
+			rules:     DefaultOrderRules,
+			wantItems: []models.OrderItem{{ProductID: 1, ProductName: "Pen", Quantity: 2, Price: 1.5}},
+			wantTotal: 3,
+		},
+		{
+			name:       "no items",
+			order:      testOrder(),
+			rules:      DefaultOrderRules,
+			wantFields: []string{"items"},
+		},
+		{
+			name: "invalid line fields",
+			order: testOrder(
+				models.OrderItem{ProductID: 0, ProductName: " ", Quantity: 0, Price: -1},
+			),
+			rules:      DefaultOrderRules,
+			wantFields: []string{"items[0].product_id", "items[0].product_name", "items[0].quantity", "items[0].price"},
+		},
+		{
+			name: "limits",
+			order: testOrder(
+				models.OrderItem{ProductID: 1, ProductName: "Notebook", Quantity: 4, Price: 1},
+				models.OrderItem{ProductID: 2, ProductName: "Pen", Quantity: 1, Price: 1},
+			),
+			rules:      small,
+			wantFields: []string{"items[0].product_name", "items[0].quantity", "items"},
+		},
+		{
+			name: "duplicate lines merged",
+			order: testOrder(
+				models.OrderItem{ProductID: 1, ProductName: "Pen", Quantity: 2, Price: 1.5},
+				models.OrderItem{ProductID: 1, ProductName: "Pen", Quantity: 3, Price: 1.5},
+			),
+			rules:     DefaultOrderRules,
+			wantItems: []models.OrderItem{{ProductID: 1, ProductName: "Pen", Quantity: 5, Price: 1.5}},
+			wantTotal: 7.5,
+		},
+		{
+			name: "duplicate lines with different price",
+			order: testOrder(
+				models.OrderItem{ProductID: 1, ProductName: "Pen", Quantity: 2, Price: 1.5},
+				models.OrderItem{ProductID: 1, ProductName: "Pen", Quantity: 3, Price: 2},
+			),
+			rules:      DefaultOrderRules,
+			wantFields: []string{"items[1].product_id"},
+		},
+		{
+			name: "duplicate lines rejected",
+			order: testOrder(
+				models.OrderItem{ProductID: 1, ProductName: "Pen", Quantity: 2},
+				models.OrderItem{ProductID: 1, ProductName: "Pen", Quantity: 3},
+			),
+			rules:      strict,
+			wantFields: []string{"items[1].product_id"},
+		},
+		{
+			name: "merged quantity over limit",
+			order: testOrder(
+				models.OrderItem{ProductID: 1, ProductName: "Pen", Quantity: 2},
+				models.OrderItem{ProductID: 1, ProductName: "Pen", Quantity: 2},
+			),
+			rules:      small,
+			wantFields: []string{"items[1].product_id"},
+		},
+		{
+			name: "total mismatch",
+			order: &models.Order{
+				Items:           []models.OrderItem{{ProductID: 1, ProductName: "Pen", Quantity: 2, Price: 1.5}},
+				Total:           4,
+				ShippingAddress: testAddress(),
+			},
+			rules:      DefaultOrderRules,
+			wantFields: []string{"total"},
+		},
+		{
+			name: "unsupported currency",
+			order: &models.Order{
+				Items:           []models.OrderItem{{ProductID: 1, ProductName: "Pen", Quantity: 1}},
+				Currency:        "usd",
+				ShippingAddress: testAddress(),
+			},
+			rules:      DefaultOrderRules,
+			wantFields: []string{"currency"},
+		},
+		{
+			name: "v1 create without shipping address",
+			order: &models.Order{
+				Items: []models.OrderItem{{ProductID: 1, ProductName: "Pen", Quantity: 1, Price: 2}},
+			},
+			rules:     DefaultOrderRules,
+			wantItems: []models.OrderItem{{ProductID: 1, ProductName: "Pen", Quantity: 1, Price: 2}},
+			wantTotal: 2,
+		},
+		{
+			name: "v2 create without shipping address",
+			order: &models.Order{
+				Items: []models.OrderItem{{ProductID: 1, ProductName: "Pen", Quantity: 1}},
+			},
+			rules:      v2,
+			wantFields: []string{"shipping_address"},
+		},
+		{
+			name: "v2 pickup without shipping address",
+			order: &models.Order{
+				Items:          []models.OrderItem{{ProductID: 1, ProductName: "Pen", Quantity: 1, Price: 2}},
+				DeliveryOption: "pickup",
+			},
+			rules:     v2,
+			wantItems: []models.OrderItem{{ProductID: 1, ProductName: "Pen", Quantity: 1, Price: 2}},
+			wantTotal: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NormalizeOrder(tt.order, tt.rules)
+			if got := fieldsOf(t, err); !reflect.DeepEqual(got, tt.wantFields) {
+				t.Fatalf("error fields = %v, want %v", got, tt.wantFields)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(tt.order.Items, tt.wantItems) {
+				t.Errorf("items = %+v, want %+v", tt.order.Items, tt.wantItems)
+			}
+			if tt.order.Total != tt.wantTotal {
+				t.Errorf("total = %v, want %v", tt.order.Total, tt.wantTotal)
+			}
+			if tt.order.Currency != tt.rules.DefaultCurrency {
+				t.Errorf("currency = %q, want default %q", tt.order.Currency, tt.rules.DefaultCurrency)
+			}
+		})
+	}
+}
+
+func TestOrderRulesWithDefaults(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules OrderRules
+		want  OrderRules
+	}{
+		{
+			name:  "zero value",
+			rules: OrderRules{},
+			want: OrderRules{
+				MaxLines:             DefaultOrderRules.MaxLines,
+				MaxLineQuantity:      DefaultOrderRules.MaxLineQuantity,
+				MaxProductNameLength: DefaultOrderRules.MaxProductNameLength,
+				DefaultCurrency:      DefaultOrderRules.DefaultCurrency,
+				Currencies:           []string{DefaultOrderRules.DefaultCurrency},
+			},
+		},
+		{
+			name:  "configured values kept",
+			rules: OrderRules{MaxLines: 5, MaxLineQuantity: 6, MaxProductNameLength: 7, MergeDuplicateLines: true, DefaultCurrency: "USD", Currencies: []string{"USD", "EUR"}},
+			want:  OrderRules{MaxLines: 5, MaxLineQuantity: 6, MaxProductNameLength: 7, MergeDuplicateLines: true, DefaultCurrency: "USD", Currencies: []string{"USD", "EUR"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.WithDefaults(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WithDefaults() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// 未配置的规则不能拒绝正常订单
+	order := testOrder(models.OrderItem{ProductID: 1, ProductName: "Pen", Quantity: 1})
+	if err := NormalizeOrder(order, OrderRules{}.WithDefaults()); err != nil {
+		t.Errorf("NormalizeOrder with zero rules: %v", err)
+	}
+}
+
+func TestApplyItemChanges(t *testing.T) {
+	qty := func(n int) *int { return &n }
+	current := []models.OrderItem{
+		{ProductID: 1, ProductName: "Pen", Quantity: 2, Price: 3},
+		{ProductID: 2, ProductName: "Ink", Quantity: 1, Price: 5},
+	}
+	small := DefaultOrderRules
+	small.MaxLines = 2
+	small.MaxLineQuantity = 5
+	small.MaxProductNameLength = 4
+
+	tests := []struct {
+		name        string
+		updates     []models.OrderItemUpdate
+		rules       OrderRules
+		wantFields  []string
+		wantItems   []models.OrderItem
+		wantChanges []models.OrderItemChange
+	}{
+		{
+			name:        "change quantity and reset prices",
+			updates:     []models.OrderItemUpdate{{ProductID: 1, Quantity: qty(4)}},
+			rules:       DefaultOrderRules,
+			wantItems:   []models.OrderItem{{ProductID: 1, ProductName: "Pen", Quantity: 4}, {ProductID: 2, ProductName: "Ink", Quantity: 1}},
+			wantChanges: []models.OrderItemChange{{ProductID: 1, ProductName: "Pen", OldQuantity: 2, NewQuantity: 4}},
+		},
+		{
+			name:        "zero quantity removes the line",
+			updates:     []models.OrderItemUpdate{{ProductID: 2, Quantity: qty(0)}},
+			rules:       DefaultOrderRules,
+			wantItems:   []models.OrderItem{{ProductID: 1, ProductName: "Pen", Quantity: 2}},
+			wantChanges: []models.OrderItemChange{{ProductID: 2, ProductName: "Ink", OldQuantity: 1}},
+		},
+		{
+			name:    "add a new line",
+			updates: []models.OrderItemUpdate{{ProductID: 3, ProductName: " Pad ", Quantity: qty(1)}},
+			rules:   DefaultOrderRules,
+			wantItems: []models.OrderItem{
+				{ProductID: 1, ProductName: "Pen", Quantity: 2},
+				{ProductID: 2, ProductName: "Ink", Quantity: 1},
+				{ProductID: 3, ProductName: "Pad", Quantity: 1},
+			},
+			wantChanges: []models.OrderItemChange{{ProductID: 3, ProductName: "Pad", NewQuantity: 1}},
+		},
+		{
+			name:       "unchanged quantity",
+			updates:    []models.OrderItemUpdate{{ProductID: 1, Quantity: qty(2)}},
+			rules:      DefaultOrderRules,
+			wantFields: []string{"items"},
+		},
+		{
+			name:       "removing every line",
+			updates:    []models.OrderItemUpdate{{ProductID: 1, Quantity: qty(0)}, {ProductID: 2, Quantity: qty(0)}},
+			rules:      DefaultOrderRules,
+			wantFields: []string{"items"},
+		},
+		{
+			name:       "too many lines",
+			updates:    []models.OrderItemUpdate{{ProductID: 3, ProductName: "Pad", Quantity: qty(1)}},
+			rules:      small,
+			wantFields: []string{"items"},
+		},
+		{
+			name: "per line errors",
+			updates: []models.OrderItemUpdate{
+				{ProductID: 1, Quantity: qty(6)},
+				{ProductID: 2, Quantity: qty(2)},
+				{ProductID: 2, Quantity: qty(3)},
+				{ProductID: 4, Quantity: qty(0)},
+				{ProductID: 5, Quantity: qty(1)},
+				{ProductID: 6, ProductName: "Notebook", Quantity: qty(1)},
+			},
+			rules: small,
+			wantFields: []string{
+				"items[0].quantity",
+				"items[2].product_id",
+				"items[3].product_id",
+				"items[4].product_name",
+				"items[5].product_name",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, changes, err := ApplyItemChanges(current, tt.updates, tt.rules)
+			if got := fieldsOf(t, err); !reflect.DeepEqual(got, tt.wantFields) {
+				t.Fatalf("error fields = %v, want %v", got, tt.wantFields)
+			}
+			if !reflect.DeepEqual(items, tt.wantItems) {
+				t.Errorf("items = %+v, want %+v", items, tt.wantItems)
+			}
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("changes = %+v, want %+v", changes, tt.wantChanges)
+			}
+		})
+	}
+	if current[0].Quantity != 2 || current[0].Price != 3 {
+		t.Errorf("ApplyItemChanges modified its input: %+v", current[0])
+	}
+}
@@ -0,0 +1,226 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"order-service/middlewares"
+	"order-service/models"
+	"strconv"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Options 投递参数
+type Options struct {
+	Timeout      time.Duration // 单次请求超时
+	MaxAttempts  int           // 超过后投递标记为失败
+	BaseBackoff  time.Duration // 第 n 次失败后等待 BaseBackoff * 2^(n-1)
+	MaxBackoff   time.Duration
+	DisableAfter int           // 连续失败次数达到后停用订阅
+	PollInterval time.Duration // 检查到期重试的间隔
+}
+
+// DefaultOptions 默认投递参数
+var DefaultOptions = Options{
+	Timeout:      10 * time.Second,
+	MaxAttempts:  8,
+	BaseBackoff:  30 * time.Second,
+	MaxBackoff:   time.Hour,
+	DisableAfter: 20,
+	PollInterval: 5 * time.Second,
+}
+
+// claimBatchSize 每次领取的投递数量
+const claimBatchSize = 20
+
+// internalEventTypes 只在服务内部使用、不对外投递的事件
+var internalEventTypes = map[string]bool{
+	"payment_check": true,
+}
+
+// Dispatcher 从订单交换机接收事件，为匹配的订阅写入投递记录，
+// 再由后台循环投递并按指数退避重试
+type Dispatcher struct {
+	db     *sql.DB
+	client *http.Client
+	opts   Options
+}
+
+func NewDispatcher(db *sql.DB, opts Options) *Dispatcher {
+	return &Dispatcher{
+		db:     db,
+		client: &http.Client{Timeout: opts.Timeout},
+		opts:   opts,
+	}
+}
+
+// Start 声明绑定到订单交换机的 webhook 队列并开始消费，同时启动重试循环
+func (d *Dispatcher) Start(ctx context.Context, conn *amqp.Connection, exchange, queue string) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		return err
+	}
+	if err := ch.QueueBind(queue, "", exchange, false, nil); err != nil {
+		return err
+	}
+	msgs, err := ch.Consume(queue, "order-service-webhooks", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for msg := range msgs {
+			d.handleMessage(msg)
+		}
+	}()
+	go d.run(ctx)
+	return nil
+}
+
+// handleMessage 为匹配的订阅写入投递记录后确认消息，写入失败时重新入队
+func (d *Dispatcher) handleMessage(msg amqp.Delivery) {
+	var event models.OrderEvent
+	if err := json.Unmarshal(msg.Body, &event); err != nil || event.Type == "" {
+		log.Printf("Webhook dispatcher: invalid message (%d bytes): %v", len(msg.Body), err)
+		_ = msg.Nack(false, false)
+		return
+	}
+	if internalEventTypes[event.Type] {
+		_ = msg.Ack(false)
+		return
+	}
+
+	subs, err := activeSubscriptions(d.db)
+	if err != nil {
+		log.Printf("Webhook dispatcher: failed to load subscriptions: %v", err)
+		_ = msg.Nack(false, true)
+		return
+	}
+	var matched []Subscription
+	for _, s := range subs {
+		if s.Matches(event.Type) {
+			matched = append(matched, s)
+		}
+	}
+	if len(matched) > 0 {
+		if err := enqueueDeliveries(d.db, matched, event.Type, event.OrderID, msg.Body); err != nil {
+			log.Printf("Webhook dispatcher: failed to enqueue deliveries for order %d: %v", event.OrderID, err)
+			_ = msg.Nack(false, true)
+			return
+		}
+	}
+	_ = msg.Ack(false)
+}
+
+// run 定期领取到期的投递并发送
+func (d *Dispatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			due, err := claimDueDeliveries(d.db, claimBatchSize, d.lease())
+			if err != nil {
+				log.Printf("Webhook dispatcher: failed to claim deliveries: %v", err)
+				break
+			}
+			for i := range due {
+				d.deliver(ctx, &due[i])
+			}
+			if len(due) < claimBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// lease 领取后的占用时间。一批投递按顺序发送，占用必须覆盖整批都超时的情况，
+// 再多留一个超时时间用于写回结果，否则其他实例会在本批完成前重复领取
+func (d *Dispatcher) lease() time.Duration {
+	return time.Duration(claimBatchSize+1) * d.opts.Timeout
+}
+
+// deliver 发送一次投递并记录结果，2xx 视为成功
+func (d *Dispatcher) deliver(ctx context.Context, pd *pendingDelivery) {
+	attempt := Attempt{Attempt: pd.Attempts + 1, AttemptedAt: time.Now()}
+	body := []byte(pd.Payload)
+	timestamp := strconv.FormatInt(attempt.AttemptedAt.Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pd.url, bytes.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "order-service-webhooks")
+		req.Header.Set(EventHeader, pd.EventType)
+		req.Header.Set(DeliveryHeader, strconv.Itoa(pd.ID))
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(pd.secret, timestamp, body))
+
+		var resp *http.Response
+		resp, err = d.client.Do(req)
+		if err == nil {
+			attempt.StatusCode = resp.StatusCode
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+			}
+		}
+	}
+	attempt.DurationMs = time.Since(attempt.AttemptedAt).Milliseconds()
+
+	status := DeliverySucceeded
+	var next *time.Time
+	outcome := "success"
+	if err != nil {
+		attempt.Error = err.Error()
+		if len(attempt.Error) > 500 {
+			attempt.Error = attempt.Error[:500]
+		}
+		outcome = "retry"
+		status = DeliveryPending
+		if attempt.Attempt >= d.opts.MaxAttempts {
+			outcome = "failed"
+			status = DeliveryFailed
+		} else {
+			t := time.Now().Add(d.backoff(attempt.Attempt))
+			next = &t
+		}
+	}
+	middlewares.RecordWebhookDelivery(pd.EventType, outcome)
+
+	disabled, err := recordAttempt(d.db, pd, attempt, status, next, d.opts.DisableAfter)
+	if err != nil {
+		log.Printf("Webhook dispatcher: failed to record delivery %d: %v", pd.ID, err)
+		return
+	}
+	if disabled {
+		log.Printf("Webhook subscription %d disabled after %d consecutive failures", pd.SubscriptionID, d.opts.DisableAfter)
+	}
+}
+
+// backoff 第 attempt 次失败后的等待时间
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.opts.BaseBackoff
+	for i := 1; i < attempt && wait < d.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.opts.MaxBackoff {
+		wait = d.opts.MaxBackoff
+	}
+	return wait
+}
@@ -0,0 +1,356 @@
+package webhooks
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNotFound 订阅或投递不存在
+var ErrNotFound = errors.New("webhooks: not found")
+
+const subscriptionColumns = `id, url, event_types, active, consecutive_failures, disabled_at, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSubscription(row scanner, withSecret bool) (*Subscription, error) {
+	var s Subscription
+	var eventTypes string
+	var disabledAt sql.NullTime
+	dest := []interface{}{&s.ID, &s.URL, &eventTypes, &s.Active, &s.ConsecutiveFailures, &disabledAt, &s.CreatedAt, &s.UpdatedAt}
+	if withSecret {
+		dest = append(dest, &s.Secret)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	s.EventTypes = []string{}
+	if eventTypes != "" {
+		s.EventTypes = strings.Split(eventTypes, ",")
+	}
+	if disabledAt.Valid {
+		s.DisabledAt = &disabledAt.Time
+	}
+	return &s, nil
+}
+
+// CreateSubscription 新建订阅
+func CreateSubscription(db *sql.DB, s *Subscription) error {
+	now := time.Now()
+	result, err := db.Exec(`
+		INSERT INTO webhook_subscriptions (url, secret, event_types, active, consecutive_failures, created_at, updated_at)
+		VALUES (?, ?, ?, ?, 0, ?, ?)
+	`, s.URL, s.Secret, strings.Join(s.EventTypes, ","), s.Active, now, now)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	s.ID = int(id)
+	s.CreatedAt, s.UpdatedAt = now, now
+	return nil
+}
+
+// ListSubscriptions 列出所有订阅，不包含密钥
+func ListSubscriptions(db *sql.DB) ([]Subscription, error) {
+	rows, err := db.Query("SELECT " + subscriptionColumns + " FROM webhook_subscriptions ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []Subscription{}
+	for rows.Next() {
+		s, err := scanSubscription(rows, false)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *s)
+	}
+	return subs, rows.Err()
+}
+
+// GetSubscription 读取订阅，不包含密钥
+func GetSubscription(db *sql.DB, id int) (*Subscription, error) {
+	s, err := scanSubscription(db.QueryRow("SELECT "+subscriptionColumns+" FROM webhook_subscriptions WHERE id = ?", id), false)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return s, err
+}
+
+// UpdateSubscription 修改订阅地址、事件类型和启用状态。重新启用时清零连续失败次数
+func UpdateSubscription(db *sql.DB, s *Subscription) error {
+	result, err := db.Exec(`
+		UPDATE webhook_subscriptions
+		SET url = ?, event_types = ?, active = ?,
+		    consecutive_failures = IF(?, 0, consecutive_failures),
+		    disabled_at = IF(?, NULL, disabled_at),
+		    updated_at = NOW()
+		WHERE id = ?
+	`, s.URL, strings.Join(s.EventTypes, ","), s.Active, s.Active, s.Active, s.ID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		if _, err := GetSubscription(db, s.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteSubscription 删除订阅及其投递记录
+func DeleteSubscription(db *sql.DB, id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		DELETE a FROM webhook_delivery_attempts a
+		JOIN webhook_deliveries d ON d.id = a.delivery_id
+		WHERE d.subscription_id = ?
+	`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE subscription_id = ?", id); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM webhook_subscriptions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// activeSubscriptions 读取启用中的订阅，包含密钥
+func activeSubscriptions(db *sql.DB) ([]Subscription, error) {
+	rows, err := db.Query("SELECT " + subscriptionColumns + ", secret FROM webhook_subscriptions WHERE active = TRUE")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		s, err := scanSubscription(rows, true)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *s)
+	}
+	return subs, rows.Err()
+}
+
+// enqueueDeliveries 为匹配的订阅各创建一条待投递记录
+func enqueueDeliveries(db *sql.DB, subs []Subscription, eventType string, orderID int, payload []byte) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, s := range subs {
+		if _, err := tx.Exec(`
+			INSERT INTO webhook_deliveries (subscription_id, event_type, order_id, payload, status, attempts,
+			                                next_attempt_at, last_error, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, 0, ?, '', ?, ?)
+		`, s.ID, eventType, orderID, payload, DeliveryPending, now, now, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// pendingDelivery 待投递记录及订阅的地址和密钥
+type pendingDelivery struct {
+	Delivery
+	url    string
+	secret string
+}
+
+// claimDueDeliveries 领取到期的待投递记录，并将下次尝试时间推迟 lease，
+// 避免多个实例重复投递
+func claimDueDeliveries(db *sql.DB, limit int, lease time.Duration) ([]pendingDelivery, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT d.id, d.subscription_id, d.event_type, d.order_id, d.payload, d.attempts, s.url, s.secret
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.status = ? AND d.next_attempt_at <= ? AND s.active = TRUE
+		ORDER BY d.next_attempt_at
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED
+	`, DeliveryPending, time.Now(), limit)
+	if err != nil {
+		return nil, err
+	}
+	var due []pendingDelivery
+	for rows.Next() {
+		var d pendingDelivery
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventType, &d.OrderID, &d.Payload, &d.Attempts, &d.url, &d.secret); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	leaseUntil := time.Now().Add(lease)
+	for _, d := range due {
+		if _, err := tx.Exec("UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?", leaseUntil, d.ID); err != nil {
+			return nil, err
+		}
+	}
+	return due, tx.Commit()
+}
+
+// recordAttempt 保存一次投递尝试并更新投递状态和订阅的连续失败次数。
+// 连续失败次数达到 disableAfter 时停用订阅（MySQL 按顺序计算 SET 中的赋值）
+func recordAttempt(db *sql.DB, d *pendingDelivery, a Attempt, status string, next *time.Time, disableAfter int) (disabled bool, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, duration_ms, attempted_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, d.ID, a.Attempt, a.StatusCode, a.Error, a.DurationMs, a.AttemptedAt); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?, updated_at = NOW()
+		WHERE id = ?
+	`, status, a.Attempt, next, a.Error, d.ID); err != nil {
+		return false, err
+	}
+
+	if a.Error == "" {
+		_, err = tx.Exec("UPDATE webhook_subscriptions SET consecutive_failures = 0 WHERE id = ?", d.SubscriptionID)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE webhook_subscriptions
+			SET consecutive_failures = consecutive_failures + 1,
+			    active = active AND consecutive_failures < ?,
+			    disabled_at = IF(active, disabled_at, COALESCE(disabled_at, NOW()))
+			WHERE id = ?
+		`, disableAfter, d.SubscriptionID)
+		if err == nil {
+			var active bool
+			err = tx.QueryRow("SELECT active FROM webhook_subscriptions WHERE id = ?", d.SubscriptionID).Scan(&active)
+			disabled = !active
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	return disabled, tx.Commit()
+}
+
+const deliveryColumns = `id, subscription_id, event_type, order_id, status, attempts, next_attempt_at, last_error, created_at, updated_at`
+
+func scanDelivery(row scanner, extra ...interface{}) (*Delivery, error) {
+	var d Delivery
+	var next sql.NullTime
+	dest := append([]interface{}{&d.ID, &d.SubscriptionID, &d.EventType, &d.OrderID, &d.Status, &d.Attempts,
+		&next, &d.LastError, &d.CreatedAt, &d.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if next.Valid && d.Status == DeliveryPending {
+		d.NextAttemptAt = &next.Time
+	}
+	return &d, nil
+}
+
+// ListDeliveries 按时间倒序列出订阅的投递记录
+func ListDeliveries(db *sql.DB, subscriptionID, limit int) ([]Delivery, error) {
+	rows, err := db.Query(
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE subscription_id = ? ORDER BY id DESC LIMIT ?",
+		subscriptionID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetDelivery 读取投递记录、消息体和每次尝试的记录
+func GetDelivery(db *sql.DB, id int) (*Delivery, error) {
+	var payload string
+	d, err := scanDelivery(db.QueryRow("SELECT "+deliveryColumns+", payload FROM webhook_deliveries WHERE id = ?", id), &payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+
+	rows, err := db.Query(`
+		SELECT attempt, status_code, error, duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = ?
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a Attempt
+		if err := rows.Scan(&a.Attempt, &a.StatusCode, &a.Error, &a.DurationMs, &a.AttemptedAt); err != nil {
+			return nil, err
+		}
+		d.AttemptLog = append(d.AttemptLog, a)
+	}
+	return d, rows.Err()
+}
+
+// Redeliver 将投递重新置为待投递，立即重试。尝试次数继续累计
+func Redeliver(db *sql.DB, id int) error {
+	result, err := db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?, next_attempt_at = ?, updated_at = NOW()
+		WHERE id = ?
+	`, DeliveryPending, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"order-service/apperrors"
+	"strings"
+	"time"
+)
+
+// 投递请求头
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// 投递状态
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Subscription webhook 订阅。EventTypes 为空时接收所有事件，
+// 以 * 结尾的类型按前缀匹配，如 return_*
+type Subscription struct {
+	ID                  int        `json:"id"`
+	URL                 string     `json:"url"`
+	Secret              string     `json:"secret,omitempty"` // 只在创建时返回
+	EventTypes          []string   `json:"event_types"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// Delivery 一个事件对一个订阅的投递，失败后按退避时间重试
+type Delivery struct {
+	ID             int        `json:"id"`
+	SubscriptionID int        `json:"subscription_id"`
+	EventType      string     `json:"event_type"`
+	OrderID        int        `json:"order_id"`
+	Payload        string     `json:"payload,omitempty"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	AttemptLog     []Attempt  `json:"attempt_log,omitempty"`
+}
+
+// Attempt 单次投递尝试记录
+type Attempt struct {
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int64     `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// Sign 计算投递签名：hex(HMAC-SHA256(secret, timestamp + "." + body))，
+// 请求头格式为 sha256=<hex>
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateSecret 生成随机签名密钥
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Matches 判断订阅是否接收该类型的事件
+func (s *Subscription) Matches(eventType string) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType || t == "*" {
+			return true
+		}
+		if strings.HasSuffix(t, "*") && strings.HasPrefix(eventType, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateURL 订阅地址必须是绝对的 http 或 https 地址
+func ValidateURL(raw string) []apperrors.FieldError {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return []apperrors.FieldError{{Field: "url", Message: "must be an absolute http or https URL"}}
+	}
+	return nil
+}
+
+// NormalizeEventTypes 去除空白和重复的事件类型
+func NormalizeEventTypes(types []string) []string {
+	result := []string{}
+	seen := make(map[string]bool)
+	for _, t := range types {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		result = append(result, t)
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package webhooks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// printf '1700000000.{"id":1}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=3dd1b9aef568d75f6790a84bd2e5dfa1f44409eef3cbdbd3f10b837376100c11"
+	got := Sign("secret", "1700000000", []byte(`{"id":1}`))
+	if got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+	if Sign("other", "1700000000", []byte(`{"id":1}`)) == got {
+		t.Error("signature does not depend on the secret")
+	}
+	if Sign("secret", "1700000001", []byte(`{"id":1}`)) == got {
+		t.Error("signature does not depend on the timestamp")
+	}
+}
+
+func TestSubscriptionMatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		eventTypes []string
+		eventType  string
+		want       bool
+	}{
+		{"no filter", nil, "created", true},
+		{"exact", []string{"created", "status_updated"}, "status_updated", true},
+		{"not listed", []string{"created"}, "status_updated", false},
+		{"wildcard", []string{"*"}, "return_refunded", true},
+		{"prefix", []string{"return_*"}, "return_refunded", true},
+		{"prefix mismatch", []string{"return_*"}, "shipment_created", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Subscription{EventTypes: tt.eventTypes}
+			if got := s.Matches(tt.eventType); got != tt.want {
+				t.Errorf("Matches(%q) = %v, want %v", tt.eventType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeEventTypes(t *testing.T) {
+	got := NormalizeEventTypes([]string{" created ", "", "return_*", "created", "  "})
+	want := []string{"created", "return_*"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeEventTypes() = %v, want %v", got, want)
+	}
+	if got := NormalizeEventTypes(nil); got == nil || len(got) != 0 {
+		t.Errorf("NormalizeEventTypes(nil) = %#v, want empty slice", got)
+	}
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	d := NewDispatcher(nil, Options{BaseBackoff: 30 * time.Second, MaxBackoff: 5 * time.Minute})
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{5, 5 * time.Minute},
+		{20, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+// 占用时间必须覆盖一整批投递全部超时
+func TestDispatcherLeaseCoversBatch(t *testing.T) {
+	d := NewDispatcher(nil, DefaultOptions)
+	if worst := claimBatchSize * DefaultOptions.Timeout; d.lease() <= worst {
+		t.Errorf("lease() = %v, want more than %v", d.lease(), worst)
+	}
+}